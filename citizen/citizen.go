@@ -0,0 +1,54 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+
+// Package citizen is the registry of the GophersLand citizens, each one identified by its public key.
+package citizen
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const maxNameLength = 128
+
+type Citizen struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// PublicKey is the PEM encoded PKIX public key of the citizen.
+	PublicKey string    `json:"public_key"`
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+// ValidationError rejects the citizen data, e.g. a blank name or a malformed public key.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("citizen %s %s", e.Field, e.Reason)
+}
+
+func validate(name string, publicKey string) error {
+	if len(strings.TrimSpace(name)) == 0 {
+		return &ValidationError{Field: "name", Reason: "must not be blank"}
+	}
+	if len(name) > maxNameLength {
+		return &ValidationError{Field: "name", Reason: fmt.Sprintf("must be at most %d chars", maxNameLength)}
+	}
+
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return &ValidationError{Field: "public_key", Reason: "must be a PEM encoded PUBLIC KEY block"}
+	}
+	_, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return &ValidationError{Field: "public_key", Reason: fmt.Sprintf("is not a valid PKIX public key. %s", err.Error())}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package citizen
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gophersland/citizen/storage"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ListQuery selects a page of citizens. Sort is one of id, the default, name or joined_at, prefixed with -
+// for the descending order, the ties are ordered by ID.
+type ListQuery struct {
+	NamePrefix  string
+	JoinedAfter time.Time
+	Sort        string
+	// Limit is DefaultListLimit when 0, it can't exceed MaxListLimit.
+	Limit  int
+	Offset int
+}
+
+// Page is the selected citizens, Total counts every citizen matching the filters.
+type Page struct {
+	Citizens []Citizen
+	Limit    int
+	Offset   int
+	Total    int
+}
+
+// HasNext tells whether more citizens match past the page.
+func (page Page) HasNext() bool {
+	return page.Offset+len(page.Citizens) < page.Total
+}
+
+var citizenOrders = map[string]func(a, b Citizen) bool{
+	"id":        func(a, b Citizen) bool { return a.ID < b.ID },
+	"name":      func(a, b Citizen) bool { return a.Name < b.Name },
+	"joined_at": func(a, b Citizen) bool { return a.JoinedAt.Before(b.JoinedAt) },
+}
+
+func (query ListQuery) validate() error {
+	if query.Limit < 0 || query.Limit > MaxListLimit {
+		return &ValidationError{Field: "limit", Reason: fmt.Sprintf("must be within 0 and %d", MaxListLimit)}
+	}
+	if query.Offset < 0 {
+		return &ValidationError{Field: "offset", Reason: "must not be negative"}
+	}
+	if _, ok := citizenOrders[strings.TrimPrefix(query.Sort, "-")]; !ok && len(query.Sort) != 0 {
+		return &ValidationError{Field: "sort", Reason: "must be id, name or joined_at, optionally prefixed with -"}
+	}
+
+	return nil
+}
+
+// storageQuery names the Citizen JSON fields the storage selects on.
+func (query ListQuery) storageQuery() storage.Query {
+	field := strings.TrimPrefix(query.Sort, "-")
+	storageQuery := storage.Query{
+		OrderBy: storage.Order{Field: field, Time: field == "joined_at", Descending: strings.HasPrefix(query.Sort, "-")},
+		Limit:   query.Limit,
+		Offset:  query.Offset,
+	}
+	if len(query.NamePrefix) != 0 {
+		storageQuery.PrefixField, storageQuery.Prefix = "name", query.NamePrefix
+	}
+	if !query.JoinedAfter.IsZero() {
+		storageQuery.AfterField, storageQuery.After = "joined_at", query.JoinedAfter
+	}
+
+	return storageQuery
+}
+
+// Query filters, sorts and pages the citizens in the storage when it is a storage.Querier, e.g. PostgreSQL,
+// out of the listed ones otherwise.
+func (reg *Registry) Query(ctx context.Context, query ListQuery) (Page, error) {
+	err := query.validate()
+	if err != nil {
+		return Page{}, err
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultListLimit
+	}
+
+	if querier, ok := reg.repo.(storage.Querier[Citizen]); ok {
+		citizens, total, err := querier.Query(ctx, query.storageQuery())
+		if err != nil {
+			return Page{}, err
+		}
+		return Page{Limit: query.Limit, Offset: query.Offset, Total: total, Citizens: citizens}, nil
+	}
+
+	all, err := reg.repo.List(ctx)
+	if err != nil {
+		return Page{}, err
+	}
+
+	matching := []Citizen{}
+	for _, citizen := range all {
+		if strings.HasPrefix(citizen.Name, query.NamePrefix) && (query.JoinedAfter.IsZero() || citizen.JoinedAt.After(query.JoinedAfter)) {
+			matching = append(matching, citizen)
+		}
+	}
+
+	sortCitizens(matching, query.Sort)
+	page := Page{Limit: query.Limit, Offset: query.Offset, Total: len(matching), Citizens: []Citizen{}}
+	if query.Offset < len(matching) {
+		end := min(query.Offset+query.Limit, len(matching))
+		page.Citizens = matching[query.Offset:end]
+	}
+
+	return page, nil
+}
+
+// sortCitizens relies on the storage listing the citizens by ID, the stable sort keeps it for the ties.
+func sortCitizens(citizens []Citizen, order string) {
+	less, ok := citizenOrders[strings.TrimPrefix(order, "-")]
+	if !ok || order == "id" {
+		return
+	}
+
+	if strings.HasPrefix(order, "-") {
+		sort.SliceStable(citizens, func(i, j int) bool { return less(citizens[j], citizens[i]) })
+		return
+	}
+	sort.SliceStable(citizens, func(i, j int) bool { return less(citizens[i], citizens[j]) })
+}
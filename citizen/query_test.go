@@ -0,0 +1,112 @@
+package citizen
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gophersland/citizen/storage"
+)
+
+func TestRegistryQuery(t *testing.T) {
+	ctx := context.Background()
+	repo := storage.NewMemory[Citizen]()
+	joinedAt := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range []string{"gopher", "alice", "gordon", "bob"} {
+		err := repo.Put(ctx, string(rune('a'+i)), Citizen{ID: string(rune('a' + i)), Name: name, JoinedAt: joinedAt.AddDate(0, 0, i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry := NewRegistry(repo)
+
+	tests := []struct {
+		name  string
+		query ListQuery
+		ids   []string
+		total int
+	}{
+		{"defaults to ID order", ListQuery{}, []string{"a", "b", "c", "d"}, 4},
+		{"filters by name prefix", ListQuery{NamePrefix: "go"}, []string{"a", "c"}, 2},
+		{"filters by joining date", ListQuery{JoinedAfter: joinedAt.AddDate(0, 0, 1)}, []string{"c", "d"}, 2},
+		{"sorts by name", ListQuery{Sort: "name"}, []string{"b", "d", "a", "c"}, 4},
+		{"sorts descending", ListQuery{Sort: "-joined_at"}, []string{"d", "c", "b", "a"}, 4},
+		{"pages", ListQuery{Sort: "name", Limit: 2, Offset: 1}, []string{"d", "a"}, 4},
+		{"pages past the end", ListQuery{Offset: 10}, []string{}, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := registry.Query(ctx, test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := []string{}
+			for _, citizen := range page.Citizens {
+				ids = append(ids, citizen.ID)
+			}
+			if len(ids) != len(test.ids) || page.Total != test.total {
+				t.Fatalf("queried citizens '%v' of '%v' are not as expected ones '%v' of '%v'", ids, page.Total, test.ids, test.total)
+			}
+			for i := range ids {
+				if ids[i] != test.ids[i] {
+					t.Fatalf("queried citizens '%v' are not as expected ones '%v'", ids, test.ids)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryQueryRejectsInvalidQueries(t *testing.T) {
+	registry := NewRegistry(storage.NewMemory[Citizen]())
+
+	for _, query := range []ListQuery{{Limit: MaxListLimit + 1}, {Limit: -1}, {Offset: -1}, {Sort: "public_key"}} {
+		_, err := registry.Query(context.Background(), query)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("query '%+v' returned error '%v', expected a validation error", query, err)
+		}
+	}
+}
+
+// queryingRepo fails the listing, the registry is supposed to leave the query to the storage.
+type queryingRepo struct {
+	*storage.Memory[Citizen]
+	query storage.Query
+}
+
+func (repo *queryingRepo) List(ctx context.Context) ([]Citizen, error) {
+	return nil, errors.New("listing every citizen is not supposed to be needed")
+}
+
+func (repo *queryingRepo) Query(ctx context.Context, query storage.Query) ([]Citizen, int, error) {
+	repo.query = query
+	return []Citizen{{ID: "a"}}, 3, nil
+}
+
+func TestRegistryQueryDelegatesToTheStorage(t *testing.T) {
+	repo := &queryingRepo{Memory: storage.NewMemory[Citizen]()}
+	joinedAfter := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	page, err := NewRegistry(repo).Query(context.Background(), ListQuery{NamePrefix: "go", JoinedAfter: joinedAfter, Sort: "-joined_at", Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := storage.Query{
+		PrefixField: "name",
+		Prefix:      "go",
+		AfterField:  "joined_at",
+		After:       joinedAfter,
+		OrderBy:     storage.Order{Field: "joined_at", Time: true, Descending: true},
+		Limit:       DefaultListLimit,
+		Offset:      1,
+	}
+	if repo.query != expected {
+		t.Fatalf("storage query '%+v' is not as expected one '%+v'", repo.query, expected)
+	}
+	if len(page.Citizens) != 1 || page.Total != 3 || page.Limit != DefaultListLimit || page.Offset != 1 {
+		t.Fatalf("returned page '%+v' is not the storage one", page)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package citizen
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gophersland/citizen/storage"
+)
+
+// ErrNotFound matches, through errors.Is, the lookups of an unknown citizen ID.
+var ErrNotFound = errors.New("citizen not found")
+
+// Registry registers the citizens into the repository, it is safe for concurrent use.
+type Registry struct {
+	repo storage.Repository[Citizen]
+	now  func() time.Time
+	// updates serializes the read-modify-write of Update.
+	updates sync.Mutex
+}
+
+func NewRegistry(repo storage.Repository[Citizen]) *Registry {
+	return &Registry{repo: repo, now: time.Now}
+}
+
+// Register validates the citizen and gives it a new ID, it joins now.
+func (reg *Registry) Register(ctx context.Context, name string, publicKey string) (Citizen, error) {
+	err := validate(name, publicKey)
+	if err != nil {
+		return Citizen{}, err
+	}
+
+	citizen := Citizen{ID: newID(), Name: name, PublicKey: publicKey, JoinedAt: reg.now().UTC()}
+	err = reg.repo.Put(ctx, citizen.ID, citizen)
+	if err != nil {
+		return Citizen{}, err
+	}
+
+	return citizen, nil
+}
+
+func (reg *Registry) Get(ctx context.Context, id string) (Citizen, error) {
+	citizen, err := reg.repo.Get(ctx, id)
+	if err != nil {
+		return Citizen{}, notFound(err)
+	}
+
+	return citizen, nil
+}
+
+// List returns every citizen ordered by ID.
+func (reg *Registry) List(ctx context.Context) ([]Citizen, error) {
+	return reg.repo.List(ctx)
+}
+
+// Update replaces the name and public key of the citizen, its ID and joining date are kept.
+func (reg *Registry) Update(ctx context.Context, id string, name string, publicKey string) (Citizen, error) {
+	err := validate(name, publicKey)
+	if err != nil {
+		return Citizen{}, err
+	}
+
+	reg.updates.Lock()
+	defer reg.updates.Unlock()
+	citizen, err := reg.repo.Get(ctx, id)
+	if err != nil {
+		return Citizen{}, notFound(err)
+	}
+
+	citizen.Name = name
+	citizen.PublicKey = publicKey
+	err = reg.repo.Put(ctx, id, citizen)
+	if err != nil {
+		return Citizen{}, err
+	}
+
+	return citizen, nil
+}
+
+func (reg *Registry) Delete(ctx context.Context, id string) error {
+	reg.updates.Lock()
+	defer reg.updates.Unlock()
+
+	return notFound(reg.repo.Delete(ctx, id))
+}
+
+// Ping tells whether the storage answers, always true for the ones living in the process.
+func (reg *Registry) Ping(ctx context.Context) error {
+	pinger, ok := reg.repo.(storage.Pinger)
+	if !ok {
+		return nil
+	}
+
+	return pinger.Ping(ctx)
+}
+
+// Close releases the storage of the registry.
+func (reg *Registry) Close() error {
+	return reg.repo.Close()
+}
+
+// notFound turns the storage ErrNotFound into the citizen one.
+func notFound(err error) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
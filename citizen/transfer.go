@@ -0,0 +1,274 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package citizen
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/gophersland/citizen/storage"
+)
+
+// Format is the encoding of the exported citizens.
+type Format string
+
+const (
+	// FormatNDJSON writes a JSON citizen per line.
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV writes a header line, then a line per citizen with its joining date in RFC 3339.
+	FormatCSV Format = "csv"
+)
+
+// ConflictPolicy decides what Import does with a citizen whose ID is already registered.
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictFail aborts the import before any citizen is stored.
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// ErrConflict matches, through errors.Is, the imports of an already registered citizen under ConflictFail.
+var ErrConflict = errors.New("citizen already registered")
+
+var (
+	csvHeader = []string{"id", "name", "public_key", "joined_at"}
+	importID  = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	// reservedIDs are the /citizens/ routes shadowing the /citizens/{id} ones.
+	reservedIDs = map[string]bool{"export": true, "import": true}
+)
+
+// ParseFormat reads ndjson or csv, the empty string is ndjson.
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case "", FormatNDJSON:
+		return FormatNDJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	default:
+		return "", &ValidationError{Field: "format", Reason: "must be ndjson or csv"}
+	}
+}
+
+// ParseConflictPolicy reads skip, overwrite or fail, the empty string is fail.
+func ParseConflictPolicy(policy string) (ConflictPolicy, error) {
+	switch ConflictPolicy(policy) {
+	case "", ConflictFail:
+		return ConflictFail, nil
+	case ConflictSkip, ConflictOverwrite:
+		return ConflictPolicy(policy), nil
+	default:
+		return "", &ValidationError{Field: "conflict", Reason: "must be skip, overwrite or fail"}
+	}
+}
+
+// ImportResult counts what Import did with the citizens it read.
+type ImportResult struct {
+	Imported    int
+	Overwritten int
+	Skipped     int
+}
+
+// Export writes every citizen ordered by ID and returns how many were written.
+func (reg *Registry) Export(ctx context.Context, w io.Writer, format Format) (int, error) {
+	citizens, err := reg.repo.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	switch format {
+	case FormatNDJSON:
+		err = writeNDJSON(w, citizens)
+	case FormatCSV:
+		err = writeCSV(w, citizens)
+	default:
+		err = &ValidationError{Field: "format", Reason: "must be ndjson or csv"}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return len(citizens), nil
+}
+
+// Import reads every citizen before storing any, so an invalid one or a conflict under ConflictFail leaves
+// the registry untouched. The imported citizens keep their ID and joining date. A storage failure midway
+// keeps the citizens stored until then, importing again with ConflictSkip resumes.
+func (reg *Registry) Import(ctx context.Context, r io.Reader, format Format, policy ConflictPolicy) (ImportResult, error) {
+	policy, err := ParseConflictPolicy(string(policy))
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	var citizens []Citizen
+	switch format {
+	case FormatNDJSON:
+		citizens, err = readNDJSON(r)
+	case FormatCSV:
+		citizens, err = readCSV(r)
+	default:
+		err = &ValidationError{Field: "format", Reason: "must be ndjson or csv"}
+	}
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	reg.updates.Lock()
+	defer reg.updates.Unlock()
+	// existing tells whether the citizens met earlier in the import are already registered.
+	existing := map[string]bool{}
+	for _, citizen := range citizens {
+		if _, met := existing[citizen.ID]; met {
+			if policy == ConflictFail {
+				return ImportResult{}, fmt.Errorf("unable to import citizen %s twice. %w", citizen.ID, ErrConflict)
+			}
+			continue
+		}
+
+		_, err := reg.repo.Get(ctx, citizen.ID)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return ImportResult{}, err
+		}
+		if err == nil && policy == ConflictFail {
+			return ImportResult{}, fmt.Errorf("unable to import citizen %s. %w", citizen.ID, ErrConflict)
+		}
+		existing[citizen.ID] = err == nil
+	}
+
+	result := ImportResult{}
+	stored := map[string]bool{}
+	for _, citizen := range citizens {
+		if (existing[citizen.ID] || stored[citizen.ID]) && policy == ConflictSkip {
+			result.Skipped++
+			continue
+		}
+
+		err = reg.repo.Put(ctx, citizen.ID, citizen)
+		if err != nil {
+			return result, err
+		}
+		switch {
+		case stored[citizen.ID]:
+			// A later line of a citizen replaces the earlier one, the citizen is already counted.
+		case existing[citizen.ID]:
+			result.Overwritten++
+		default:
+			result.Imported++
+		}
+		stored[citizen.ID] = true
+	}
+
+	return result, nil
+}
+
+func writeNDJSON(w io.Writer, citizens []Citizen) error {
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	for _, citizen := range citizens {
+		err := encoder.Encode(citizen)
+		if err != nil {
+			return fmt.Errorf("unable to export citizen %s. %w", citizen.ID, err)
+		}
+	}
+
+	err := buffered.Flush()
+	if err != nil {
+		return fmt.Errorf("unable to export citizens. %w", err)
+	}
+
+	return nil
+}
+
+// writeCSV leaves the write errors, sticky in the csv.Writer buffer, to the final Error check.
+func writeCSV(w io.Writer, citizens []Citizen) error {
+	writer := csv.NewWriter(w)
+	writer.Write(csvHeader)
+	for _, citizen := range citizens {
+		writer.Write([]string{citizen.ID, citizen.Name, citizen.PublicKey, citizen.JoinedAt.Format(time.RFC3339Nano)})
+	}
+
+	writer.Flush()
+	err := writer.Error()
+	if err != nil {
+		return fmt.Errorf("unable to export citizens. %w", err)
+	}
+
+	return nil
+}
+
+func readNDJSON(r io.Reader) ([]Citizen, error) {
+	citizens := []Citizen{}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	for line := 1; ; line++ {
+		citizen := Citizen{}
+		err := decoder.Decode(&citizen)
+		if err == io.EOF {
+			return citizens, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read citizen %d. %w", line, &ValidationError{Field: "record", Reason: err.Error()})
+		}
+
+		err = validateImported(citizen)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read citizen %d. %w", line, err)
+		}
+		citizens = append(citizens, citizen)
+	}
+}
+
+func readCSV(r io.Reader) ([]Citizen, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(csvHeader)
+	header, err := reader.Read()
+	if err != nil || fmt.Sprint(header) != fmt.Sprint(csvHeader) {
+		return nil, &ValidationError{Field: "header", Reason: fmt.Sprintf("must be the %v CSV line", csvHeader)}
+	}
+
+	citizens := []Citizen{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return citizens, nil
+		}
+		if err != nil {
+			return nil, &ValidationError{Field: "record", Reason: err.Error()}
+		}
+
+		joinedAt, err := time.Parse(time.RFC3339Nano, record[3])
+		if err != nil {
+			return nil, fmt.Errorf("unable to read line %d. %w", line, &ValidationError{Field: "joined_at", Reason: "must be an RFC 3339 time"})
+		}
+		citizen := Citizen{ID: record[0], Name: record[1], PublicKey: record[2], JoinedAt: joinedAt}
+		err = validateImported(citizen)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read line %d. %w", line, err)
+		}
+		citizens = append(citizens, citizen)
+	}
+}
+
+// validateImported also checks what Register generates, the imported ID ends up in the /citizens/{id} paths.
+func validateImported(citizen Citizen) error {
+	if !importID.MatchString(citizen.ID) {
+		return &ValidationError{Field: "id", Reason: "must be 1 to 64 letters, digits, - or _"}
+	}
+	if reservedIDs[citizen.ID] {
+		return &ValidationError{Field: "id", Reason: "must not be export or import, reserved by the routes"}
+	}
+	if citizen.JoinedAt.IsZero() {
+		return &ValidationError{Field: "joined_at", Reason: "must be set"}
+	}
+
+	return validate(citizen.Name, citizen.PublicKey)
+}
@@ -0,0 +1,160 @@
+package citizen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophersland/citizen/storage"
+)
+
+func newTestRegistry(t *testing.T, names ...string) *Registry {
+	registry := NewRegistry(storage.NewMemory[Citizen]())
+	publicKey := newTestPublicKey(t)
+	for _, name := range names {
+		_, err := registry.Register(context.Background(), name, publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return registry
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatNDJSON, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			ctx := context.Background()
+			source := newTestRegistry(t, "gopher", "alice, the \"first\"")
+			exported := bytes.Buffer{}
+			count, err := source.Export(ctx, &exported, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Fatalf("exported '%v' citizens, expected 2", count)
+			}
+
+			target := newTestRegistry(t)
+			result, err := target.Import(ctx, bytes.NewReader(exported.Bytes()), format, ConflictFail)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != (ImportResult{Imported: 2}) {
+				t.Fatalf("import result '%+v' is not as expected", result)
+			}
+
+			expected, _ := source.List(ctx)
+			imported, _ := target.List(ctx)
+			for i := range expected {
+				if imported[i].ID != expected[i].ID || imported[i].Name != expected[i].Name || !imported[i].JoinedAt.Equal(expected[i].JoinedAt) {
+					t.Fatalf("imported citizen '%+v' is not as expected one '%+v'", imported[i], expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestImportConflictPolicies(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry(t, "gopher")
+	existing, _ := registry.List(ctx)
+	renamed := existing[0]
+	renamed.Name = "renamed"
+	added := Citizen{ID: "added", Name: "alice", PublicKey: renamed.PublicKey, JoinedAt: time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)}
+	export := bytes.Buffer{}
+	err := writeNDJSON(&export, []Citizen{renamed, added})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = registry.Import(ctx, bytes.NewReader(export.Bytes()), FormatNDJSON, ConflictFail)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("returned error '%v' is not as expected one '%v'", err, ErrConflict)
+	}
+	if _, err = registry.Get(ctx, added.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatal("failed import is not supposed to store any citizen")
+	}
+
+	result, err := registry.Import(ctx, bytes.NewReader(export.Bytes()), FormatNDJSON, ConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, _ := registry.Get(ctx, renamed.ID)
+	if result != (ImportResult{Imported: 1, Skipped: 1}) || found.Name != "gopher" {
+		t.Fatalf("import result '%+v' is not as expected, skipped citizen is '%+v'", result, found)
+	}
+
+	result, err = registry.Import(ctx, bytes.NewReader(export.Bytes()), FormatNDJSON, ConflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, _ = registry.Get(ctx, renamed.ID)
+	if result != (ImportResult{Overwritten: 2}) || found.Name != "renamed" {
+		t.Fatalf("import result '%+v' is not as expected, overwritten citizen is '%+v'", result, found)
+	}
+}
+
+func TestImportCountsDuplicatesOnce(t *testing.T) {
+	ctx := context.Background()
+	publicKey := newTestPublicKey(t)
+	first := Citizen{ID: "added", Name: "alice", PublicKey: publicKey, JoinedAt: time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)}
+	second := first
+	second.Name = "renamed"
+	export := bytes.Buffer{}
+	err := writeNDJSON(&export, []Citizen{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		policy       ConflictPolicy
+		expected     ImportResult
+		expectedName string
+	}{
+		{ConflictSkip, ImportResult{Imported: 1, Skipped: 1}, "alice"},
+		{ConflictOverwrite, ImportResult{Imported: 1}, "renamed"},
+	}
+
+	for _, testCase := range testCases {
+		registry := newTestRegistry(t)
+		result, err := registry.Import(ctx, bytes.NewReader(export.Bytes()), FormatNDJSON, testCase.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found, _ := registry.Get(ctx, first.ID)
+		if result != testCase.expected || found.Name != testCase.expectedName {
+			t.Fatalf("%v: import result '%+v' is not as expected one '%+v', imported citizen is '%+v'", testCase.policy, result, testCase.expected, found)
+		}
+	}
+}
+
+func TestImportRejectsInvalidCitizens(t *testing.T) {
+	publicKey := strings.ReplaceAll(newTestPublicKey(t), "\n", `\n`)
+	tests := []struct {
+		name   string
+		format Format
+		body   string
+	}{
+		{"malformed json", FormatNDJSON, `{"id": `},
+		{"unknown field", FormatNDJSON, `{"id": "a", "admin": true}`},
+		{"reserved id", FormatNDJSON, `{"id": "export", "name": "gopher", "public_key": "` + publicKey + `", "joined_at": "2018-06-01T12:00:00Z"}`},
+		{"path in the id", FormatNDJSON, `{"id": "../a", "name": "gopher", "public_key": "` + publicKey + `", "joined_at": "2018-06-01T12:00:00Z"}`},
+		{"missing joining date", FormatNDJSON, `{"id": "a", "name": "gopher", "public_key": "` + publicKey + `"}`},
+		{"invalid key", FormatNDJSON, `{"id": "a", "name": "gopher", "public_key": "key", "joined_at": "2018-06-01T12:00:00Z"}`},
+		{"missing csv header", FormatCSV, "a,gopher,key,2018-06-01T12:00:00Z\n"},
+		{"invalid csv date", FormatCSV, "id,name,public_key,joined_at\na,gopher,key,yesterday\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newTestRegistry(t).Import(context.Background(), strings.NewReader(test.body), test.format, ConflictFail)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("returned error '%v' is not a validation error", err)
+			}
+		})
+	}
+}
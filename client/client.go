@@ -0,0 +1,167 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+
+// Package client calls the citizen HTTP API, answering with the citizen types and, for the failed calls,
+// an *Error decoded from the error envelope of the server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gophersland/citizen/citizen"
+)
+
+// Client is safe for concurrent use, it reuses the connections to the server.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+}
+
+func New(cfg Config) (*Client, error) {
+	baseURL, err := url.Parse(cfg.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid citizen server URL. %s", err.Error())
+	}
+	if (baseURL.Scheme != "https" && baseURL.Scheme != "http") || len(baseURL.Host) == 0 {
+		return nil, fmt.Errorf("citizen server URL '%s' must be an absolute http or https URL", cfg.baseURL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg.buildTLSConfig()
+
+	return &Client{baseURL: baseURL, httpClient: &http.Client{Transport: transport, Timeout: cfg.timeout}}, nil
+}
+
+type pingReq struct {
+	Value string `json:"value"`
+}
+
+type pingRes struct {
+	Message string `json:"message"`
+}
+
+type citizenReq struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+}
+
+type citizenListRes struct {
+	Citizens   []citizen.Citizen `json:"citizens"`
+	Pagination struct {
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+		Total  int `json:"total"`
+	} `json:"pagination"`
+}
+
+// Ping has the server echo the value and returns its message.
+func (c *Client) Ping(ctx context.Context, value string) (string, error) {
+	res := pingRes{}
+	err := c.do(ctx, http.MethodPost, "/ping", nil, pingReq{Value: value}, &res)
+	if err != nil {
+		return "", err
+	}
+
+	return res.Message, nil
+}
+
+// RegisterCitizen returns the citizen with the ID and joining date given by the server.
+func (c *Client) RegisterCitizen(ctx context.Context, name string, publicKey string) (citizen.Citizen, error) {
+	registered := citizen.Citizen{}
+	err := c.do(ctx, http.MethodPost, "/citizens", nil, citizenReq{Name: name, PublicKey: publicKey}, &registered)
+	if err != nil {
+		return citizen.Citizen{}, err
+	}
+
+	return registered, nil
+}
+
+// GetCitizen fails with an error matching citizen.ErrNotFound for an unknown ID.
+func (c *Client) GetCitizen(ctx context.Context, id string) (citizen.Citizen, error) {
+	found := citizen.Citizen{}
+	err := c.do(ctx, http.MethodGet, "/citizens/"+url.PathEscape(id), nil, nil, &found)
+	if err != nil {
+		return citizen.Citizen{}, err
+	}
+
+	return found, nil
+}
+
+// ListCitizens returns a page of the citizens selected by the query, the zero values are left to the server
+// defaults. Page.HasNext tells whether to ask for the next one at Offset + len(Citizens).
+func (c *Client) ListCitizens(ctx context.Context, query citizen.ListQuery) (citizen.Page, error) {
+	params := url.Values{}
+	if len(query.NamePrefix) != 0 {
+		params.Set("name_prefix", query.NamePrefix)
+	}
+	if !query.JoinedAfter.IsZero() {
+		params.Set("joined_after", query.JoinedAfter.Format(time.RFC3339Nano))
+	}
+	if len(query.Sort) != 0 {
+		params.Set("sort", query.Sort)
+	}
+	if query.Limit != 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Offset != 0 {
+		params.Set("offset", strconv.Itoa(query.Offset))
+	}
+
+	res := citizenListRes{}
+	err := c.do(ctx, http.MethodGet, "/citizens", params, nil, &res)
+	if err != nil {
+		return citizen.Page{}, err
+	}
+
+	return citizen.Page{Citizens: res.Citizens, Limit: res.Pagination.Limit, Offset: res.Pagination.Offset, Total: res.Pagination.Total}, nil
+}
+
+// do sends the JSON reqBody, when not nil, and decodes the JSON answer into resBody.
+func (c *Client) do(ctx context.Context, method string, path string, params url.Values, reqBody interface{}, resBody interface{}) error {
+	reqURL := c.baseURL.JoinPath(path)
+	reqURL.RawQuery = params.Encode()
+
+	var body io.Reader
+	if reqBody != nil {
+		reqBodyJson, err := json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("unable to marshal request body. %s", err.Error())
+		}
+		body = bytes.NewReader(reqBodyJson)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL.String(), body)
+	if err != nil {
+		return fmt.Errorf("unable to create the %s %s request. %s", method, path, err.Error())
+	}
+	req.Header.Set("Accept", "application/json")
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to call the citizen server. %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return newError(res)
+	}
+
+	err = json.NewDecoder(res.Body).Decode(resBody)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal the %s %s response. %s", method, path, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophersland/citizen/citizen"
+	"github.com/gophersland/citizen/httpserver"
+	"github.com/gophersland/citizen/storage"
+)
+
+func newTestPublicKey(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// newTestClient calls a plaintext server on an ephemeral port, shut down with the test.
+func newTestClient(t *testing.T) *Client {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := citizen.NewRegistry(storage.NewMemory[citizen.Citizen]())
+	deps := httpserver.NewReqHandlersDependencies("test pong").WithLogger(httpserver.NoopLogger).WithCitizenRegistry(registry)
+	server, err := httpserver.New(httpserver.NewConfig(0, "", "").WithPlaintext().WithListener(listener), deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Shutdown() })
+
+	client, err := New(NewConfig("http://" + listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	message, err := client.Ping(ctx, "ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message != "request: ping; response: test pong" {
+		t.Fatalf("returned ping message '%v' is not as expected", message)
+	}
+
+	publicKey := newTestPublicKey(t)
+	registered, err := client.RegisterCitizen(ctx, "gopher", publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.RegisterCitizen(ctx, "alice", publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := client.GetCitizen(ctx, registered.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != registered.ID || found.Name != "gopher" || !found.JoinedAt.Equal(registered.JoinedAt) {
+		t.Fatalf("found citizen '%+v' is not as expected one '%+v'", found, registered)
+	}
+
+	page, err := client.ListCitizens(ctx, citizen.ListQuery{Sort: "name", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Citizens) != 1 || page.Citizens[0].Name != "alice" || page.Total != 2 || !page.HasNext() {
+		t.Fatalf("listed page '%+v' is not as expected", page)
+	}
+}
+
+func TestClientDecodesErrorEnvelope(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	_, err := client.GetCitizen(ctx, "unknown")
+	var clientErr *Error
+	if !errors.As(err, &clientErr) || clientErr.StatusCode != http.StatusNotFound || clientErr.Code != "not_found" {
+		t.Fatalf("returned error '%v' is not the not found envelope", err)
+	}
+	if !errors.Is(err, citizen.ErrNotFound) {
+		t.Fatalf("returned error '%v' is supposed to match '%v'", err, citizen.ErrNotFound)
+	}
+
+	_, err = client.RegisterCitizen(ctx, "gopher", "not a key")
+	if !errors.As(err, &clientErr) || clientErr.StatusCode != http.StatusBadRequest || clientErr.Code != "invalid_request" {
+		t.Fatalf("returned error '%v' is not the invalid request envelope", err)
+	}
+
+	_, err = client.ListCitizens(ctx, citizen.ListQuery{Sort: "public_key"})
+	if !errors.As(err, &clientErr) || clientErr.Code != "invalid_request" {
+		t.Fatalf("returned error '%v' is not the invalid request envelope", err)
+	}
+}
+
+func TestClientTrustsRootCAs(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message": "pong"}`))
+	}))
+	defer server.Close()
+
+	untrusting, err := New(NewConfig(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = untrusting.Ping(context.Background(), "ping")
+	if err == nil {
+		t.Fatal("client is not supposed to trust the test server certificate by default")
+	}
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	trusting, err := New(NewConfig(server.URL).WithRootCAs(rootCAs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := trusting.Ping(context.Background(), "ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message != "pong" {
+		t.Fatalf("returned ping message '%v' is not as expected one '%v'", message, "pong")
+	}
+}
+
+func TestClientKeepsNonEnvelopeAnswers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client, err := New(NewConfig(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Ping(context.Background(), "ping")
+	var clientErr *Error
+	if !errors.As(err, &clientErr) || clientErr.StatusCode != http.StatusBadGateway || len(clientErr.Code) != 0 {
+		t.Fatalf("returned error '%v' is not the proxy answer", err)
+	}
+}
+
+func TestNewRejectsInvalidBaseURLs(t *testing.T) {
+	for _, baseURL := range []string{"", "localhost:9093", "ftp://localhost", "https://"} {
+		_, err := New(NewConfig(baseURL))
+		if err == nil {
+			t.Fatalf("base URL '%v' is supposed to be rejected", baseURL)
+		}
+	}
+}
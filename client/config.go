@@ -0,0 +1,71 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Config locates the server and sets the TLS counterparts of the httpserver.Config ones.
+type Config struct {
+	baseURL    string
+	tlsConfig  *tls.Config
+	rootCAs    *x509.CertPool
+	clientCert *tls.Certificate
+	timeout    time.Duration
+}
+
+// NewConfig targets the server at baseURL, e.g. https://citizen.gophersland.com:9093, a path is kept as
+// the prefix of the routes, e.g. behind a proxy.
+func NewConfig(baseURL string) Config {
+	return Config{baseURL: baseURL, timeout: defaultTimeout}
+}
+
+// WithTLSConfig sets the base TLS settings, MinVersion defaults to TLS 1.2 like on the server.
+func (cfg Config) WithTLSConfig(tlsConfig *tls.Config) Config {
+	cfg.tlsConfig = tlsConfig
+	return cfg
+}
+
+// WithRootCAs trusts the server certificates signed by the given CAs instead of the system ones, e.g. a
+// self-signed localhost.crt.
+func (cfg Config) WithRootCAs(rootCAs *x509.CertPool) Config {
+	cfg.rootCAs = rootCAs
+	return cfg
+}
+
+// WithClientCert presents the certificate to the servers requiring mTLS through httpserver.Config.WithClientCAs.
+func (cfg Config) WithClientCert(cert tls.Certificate) Config {
+	cfg.clientCert = &cert
+	return cfg
+}
+
+// WithTimeout bounds every call, 30s by default, 0 leaves the calls to their context deadline.
+func (cfg Config) WithTimeout(timeout time.Duration) Config {
+	cfg.timeout = timeout
+	return cfg
+}
+
+func (cfg Config) buildTLSConfig() *tls.Config {
+	tlsConfig := &tls.Config{}
+	if cfg.tlsConfig != nil {
+		tlsConfig = cfg.tlsConfig.Clone()
+	}
+
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+	if cfg.rootCAs != nil {
+		tlsConfig.RootCAs = cfg.rootCAs
+	}
+	if cfg.clientCert != nil {
+		tlsConfig.Certificates = append(tlsConfig.Certificates, *cfg.clientCert)
+	}
+
+	return tlsConfig
+}
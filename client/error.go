@@ -0,0 +1,89 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gophersland/citizen/citizen"
+)
+
+// maxErrorBodyBytes bounds the error bodies read back, the envelopes are far smaller.
+const maxErrorBodyBytes = 64 << 10
+
+// Error is a non 2xx answer of the server, decoded from its error envelope. Code is the stable kind of
+// the error, e.g. invalid_request or not_found, empty when the answer came from a proxy rather than the
+// server. A not_found or conflict Error matches citizen.ErrNotFound or citizen.ErrConflict through errors.Is.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    []ErrorDetail
+	RequestID  string
+}
+
+// ErrorDetail locates a rejected request field.
+type ErrorDetail struct {
+	Field string `json:"field"`
+	Issue string `json:"issue"`
+}
+
+type errorRes struct {
+	Error struct {
+		Code      string        `json:"code"`
+		Message   string        `json:"message"`
+		Details   []ErrorDetail `json:"details"`
+		RequestID string        `json:"request_id"`
+	} `json:"error"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Code) == 0 {
+		return fmt.Sprintf("citizen server answered %d. %s", e.StatusCode, e.Message)
+	}
+	if len(e.RequestID) == 0 {
+		return fmt.Sprintf("citizen server answered %d %s. %s", e.StatusCode, e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("citizen server answered %d %s. %s, request %s", e.StatusCode, e.Code, e.Message, e.RequestID)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case citizen.ErrNotFound:
+		return e.Code == "not_found"
+	case citizen.ErrConflict:
+		return e.Code == "conflict"
+	default:
+		return false
+	}
+}
+
+// newError reads the envelope of res, or keeps the start of the body as the message of another answer.
+func newError(res *http.Response) error {
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodyBytes))
+	if err != nil {
+		return fmt.Errorf("unable to read the %d answer of the citizen server. %w", res.StatusCode, err)
+	}
+
+	envelope := errorRes{}
+	if json.Unmarshal(body, &envelope) != nil || len(envelope.Error.Code) == 0 {
+		message := http.StatusText(res.StatusCode)
+		if len(body) != 0 {
+			message = string(body)
+		}
+		return &Error{StatusCode: res.StatusCode, Message: message}
+	}
+
+	return &Error{
+		StatusCode: res.StatusCode,
+		Code:       envelope.Error.Code,
+		Message:    envelope.Error.Message,
+		Details:    envelope.Error.Details,
+		RequestID:  envelope.Error.RequestID,
+	}
+}
@@ -4,25 +4,59 @@
 package main
 
 import (
-	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"github.com/gophersland/citizen/httpserver"
 	"os"
+	"strings"
 )
 
+const usage = `Usage: httpserver <command> [flags]
+
+Commands:
+  serve    runs the server, the default command
+  ping     pings a running server
+  migrate  applies the storage schema migrations
+  export   exports the stored citizens as NDJSON or CSV
+  import   imports the citizens of an export
+  version  prints the build info
+
+Run 'httpserver <command> -h' for the command flags.
+`
+
 func main() {
-	cfg := httpserver.NewConfig(
-		9093,
-		fmt.Sprintf("%s/src/github.com/gophersland/citizen/httpserver/localhost.crt", os.Getenv("GOPATH")),
-		fmt.Sprintf("%s/src/github.com/gophersland/citizen/httpserver/localhost.key", os.Getenv("GOPATH")),
-	)
-	reqHandlersDependencies := httpserver.NewReqHandlersDependencies("pong")
-
-	err := httpserver.RunServerImpl(context.Background(), cfg, httpserver.ServeReqsImpl, reqHandlersDependencies)
-	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+	command, args := "serve", os.Args[1:]
+	if len(args) != 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		os.Exit(serve(args))
+	case "ping":
+		os.Exit(ping(args))
+	case "migrate":
+		os.Exit(migrate(args))
+	case "export":
+		os.Exit(exportCitizens(args))
+	case "import":
+		os.Exit(importCitizens(args))
+	case "version":
+		os.Exit(version(args))
+	case "help":
+		fmt.Print(usage)
+		os.Exit(0)
+	default:
+		fmt.Printf("Unknown command '%s'.\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
+
+// flagsErrCode is 0 for -h, the usage is already printed, and 2 for invalid flags.
+func flagsErrCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
 	}
 
-	os.Exit(0)
+	return 2
 }
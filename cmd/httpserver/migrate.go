@@ -0,0 +1,54 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gophersland/citizen/httpserver"
+	"github.com/gophersland/citizen/storage"
+)
+
+// migrate applies the schema migrations of the configured storage, e.g. before rolling out a release
+// whose servers don't auto migrate.
+func migrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	configPath := flags.String("config", "", "YAML or TOML config file, replaces the CITIZEN_* environment variables")
+	err := flags.Parse(args)
+	if err != nil {
+		return flagsErrCode(err)
+	}
+
+	storageCfg, err := loadStorageConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	applied, version, err := storage.Migrate(ctx, storageCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	fmt.Printf("Applied %d migrations, the schema is at version %d.\n", applied, version)
+	return 0
+}
+
+// loadStorageConfig reads only the storage part of the config, the storage tools don't need the server
+// certificate.
+func loadStorageConfig(configPath string) (storage.Config, error) {
+	if len(configPath) != 0 {
+		return httpserver.LoadStorageConfig(configPath)
+	}
+
+	return httpserver.NewStorageConfigFromEnv()
+}
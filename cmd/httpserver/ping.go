@@ -0,0 +1,73 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ping posts a ping request to a running server, or sends it as a GET query with --get, and prints its
+// response. It fails on any non 200 answer.
+func ping(args []string) int {
+	flags := flag.NewFlagSet("ping", flag.ContinueOnError)
+	url := flags.String("url", "https://localhost:9093/ping", "ping route of the server")
+	value := flags.String("value", "ping", "value echoed back by the server")
+	insecure := flags.Bool("insecure", false, "skips the server certificate verification, e.g. for a self-signed one")
+	timeout := flags.Duration("timeout", 5*time.Second, "request timeout")
+	get := flags.Bool("get", false, "sends the value as a GET query parameter instead of a POST body")
+	err := flags.Parse(args)
+	if err != nil {
+		return flagsErrCode(err)
+	}
+
+	reqBody, _ := json.Marshal(map[string]string{"value": *value})
+	client := &http.Client{
+		Timeout:   *timeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: *insecure}},
+	}
+	var res *http.Response
+	if *get {
+		res, err = client.Get(pingQueryURL(*url, *value))
+	} else {
+		res, err = client.Post(*url, "application/json", bytes.NewReader(reqBody))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to read response body. %s\n", err.Error())
+		return 1
+	}
+	fmt.Print(string(resBody))
+
+	if res.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "server answered with status %d.\n", res.StatusCode)
+		return 1
+	}
+
+	return 0
+}
+
+func pingQueryURL(rawURL string, value string) string {
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+
+	return rawURL + separator + "value=" + url.QueryEscape(value)
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gophersland/citizen/httpserver"
+)
+
+const tracerShutdownTimeout = 5 * time.Second
+
+// serve reads the config file when given, the CITIZEN_* environment variables otherwise. The --port, --cert
+// and --key flags take precedence over their environment variables.
+func serve(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := flags.String("config", "", "YAML or TOML config file, replaces the CITIZEN_* environment variables")
+	port := flags.Int("port", 0, "TCP port, overrides CITIZEN_PORT")
+	certFile := flags.String("cert", "", "PEM certificate file, overrides CITIZEN_TLS_CERT")
+	keyFile := flags.String("key", "", "PEM private key file, overrides CITIZEN_TLS_KEY")
+	logLevel := flags.String("log-level", "info", "lowest level of the JSON log lines written to stderr: debug, info or error")
+	err := flags.Parse(args)
+	if err != nil {
+		return flagsErrCode(err)
+	}
+
+	level, err := httpserver.ParseLogLevel(*logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+	logger := httpserver.NewJSONLogger(os.Stderr, level)
+
+	if len(*configPath) != 0 && (*port != 0 || len(*certFile) != 0 || len(*keyFile) != 0) {
+		logger.Error("--port, --cert and --key can't be combined with --config, set them in the config file.")
+		return 2
+	}
+	flagOverrides := func(cfg httpserver.Config) httpserver.Config {
+		if *port != 0 {
+			cfg = cfg.WithPort(*port)
+		}
+		if len(*certFile) != 0 || len(*keyFile) != 0 {
+			cfg = cfg.WithKeyPair(*certFile, *keyFile)
+		}
+		return cfg
+	}
+	cfg, err := loadConfig(*configPath, flagOverrides)
+	if err != nil {
+		logger.Error("Invalid server configuration.", "error", err)
+		return 1
+	}
+	registry, err := httpserver.NewCitizenRegistry(cfg)
+	if err != nil {
+		logger.Error("Unable to open the citizen registry.", "error", err)
+		return 1
+	}
+	defer registry.Close()
+	reqHandlersDependencies := httpserver.NewReqHandlersDependencies("pong").
+		WithLogger(logger).
+		WithCitizenRegistry(registry)
+
+	// The tracing is on once an OTLP collector is configured, the exporter reads the other OTEL_* variables.
+	if len(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")) != 0 || len(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")) != 0 {
+		tracerProvider, err := httpserver.NewOTLPTracerProvider(context.Background())
+		if err != nil {
+			logger.Error("Unable to set up the tracing.", "error", err)
+			return 1
+		}
+		// Flushes the spans of the last requests once the server is drained.
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+			defer cancel()
+			err := tracerProvider.Shutdown(ctx)
+			if err != nil {
+				logger.Error("Unable to flush the last spans.", "error", err)
+			}
+		}()
+		reqHandlersDependencies = reqHandlersDependencies.WithTracerProvider(tracerProvider)
+	}
+
+	// SIGINT and SIGTERM start the graceful shutdown, a second signal kills the process as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	return run(ctx, cfg, reqHandlersDependencies, logger)
+}
+
+func run(ctx context.Context, cfg httpserver.Config, deps httpserver.ReqHandlersDependencies, logger httpserver.Logger) int {
+	server, err := httpserver.New(cfg, deps)
+	if err != nil {
+		logger.Error("Invalid server configuration.", "error", err)
+		return 1
+	}
+
+	err = server.Start(ctx)
+	var addrInUseErr *httpserver.AddrInUseError
+	if errors.As(err, &addrInUseErr) {
+		logger.Error("Another process is already listening on the port, stop it or pick a different port.", "port", addrInUseErr.Port)
+		return 1
+	}
+	if err != nil {
+		logger.Error("Unable to start the server.", "error", err)
+		return 1
+	}
+
+	<-server.Done()
+	err = server.Shutdown()
+	if err != nil {
+		logger.Error("Server stopped with an error.", "error", err)
+		return 1
+	}
+	logger.Info("Server stopped.")
+
+	return 0
+}
+
+// loadConfig reads the config file when given, the CITIZEN_* environment variables otherwise, the overrides
+// are then applied to the environment one.
+func loadConfig(configPath string, overrides ...func(httpserver.Config) httpserver.Config) (httpserver.Config, error) {
+	if len(configPath) != 0 {
+		return httpserver.LoadConfig(configPath)
+	}
+
+	return httpserver.NewConfigFromEnv(overrides...)
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gophersland/citizen/citizen"
+	"github.com/gophersland/citizen/httpserver"
+)
+
+// exportCitizens writes the citizens of the configured storage to a file, or to stdout, e.g. to back it up or to
+// import them into another backend. The file and bolt backends must not be in use by a running server.
+func exportCitizens(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	configPath := flags.String("config", "", "YAML or TOML config file, replaces the CITIZEN_* environment variables")
+	format := flags.String("format", string(citizen.FormatNDJSON), "export format, ndjson or csv")
+	outPath := flags.String("out", "", "export file, stdout by default")
+	err := flags.Parse(args)
+	if err != nil {
+		return flagsErrCode(err)
+	}
+
+	// The messages go to stderr, stdout may be the export itself.
+	exportFormat, err := citizen.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+	registry, err := openRegistry(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer registry.Close()
+
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if len(*outPath) != 0 {
+		file, err = os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to create the export file. %s\n", err.Error())
+			return 1
+		}
+		out = file
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	exported, err := registry.Export(ctx, out, exportFormat)
+	// The last written bytes may only reach the disk on close, a failed close leaves the export incomplete.
+	if file != nil {
+		closeErr := file.Close()
+		if closeErr != nil && err == nil {
+			err = fmt.Errorf("unable to write the export file. %s", closeErr.Error())
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d citizens.\n", exported)
+	return 0
+}
+
+// importCitizens stores the citizens of an export file, or of stdin, into the configured storage.
+func importCitizens(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	configPath := flags.String("config", "", "YAML or TOML config file, replaces the CITIZEN_* environment variables")
+	format := flags.String("format", string(citizen.FormatNDJSON), "import format, ndjson or csv")
+	conflict := flags.String("conflict", string(citizen.ConflictFail), "policy for the already registered citizens, skip, overwrite or fail")
+	inPath := flags.String("in", "", "export file to import, stdin by default")
+	err := flags.Parse(args)
+	if err != nil {
+		return flagsErrCode(err)
+	}
+
+	importFormat, err := citizen.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+	policy, err := citizen.ParseConflictPolicy(*conflict)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+
+	var in io.Reader = os.Stdin
+	if len(*inPath) != 0 {
+		file, err := os.Open(*inPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to open the import file. %s\n", err.Error())
+			return 1
+		}
+		defer file.Close()
+		in = file
+	}
+
+	registry, err := openRegistry(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer registry.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	result, err := registry.Import(ctx, in, importFormat, policy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	fmt.Printf("Imported %d citizens, overwrote %d and skipped %d.\n", result.Imported, result.Overwritten, result.Skipped)
+	return 0
+}
+
+func openRegistry(configPath string) (*citizen.Registry, error) {
+	storageCfg, err := loadStorageConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return httpserver.NewCitizenRegistryFromStorage(storageCfg)
+}
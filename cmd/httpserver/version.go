@@ -0,0 +1,22 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/gophersland/citizen/httpserver"
+)
+
+func version(args []string) int {
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	err := flags.Parse(args)
+	if err != nil {
+		return flagsErrCode(err)
+	}
+
+	fmt.Printf("version: %s, commit: %s, built: %s\n", httpserver.Version, httpserver.Commit, httpserver.BuildTime)
+	return 0
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const combinedLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLog writes every request to out in the NCSA Combined Log Format:
+// host ident user [time] "request line" status bytes "referer" "user agent". The request line holds the
+// URL with the values of the redacted query parameters replaced by ***.
+func accessLog(out io.Writer, now func() time.Time, redactedParams []string) httpResDecorator {
+	mu := sync.Mutex{}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := now()
+			rec := newStatusRecorder(w)
+			handler.ServeHTTP(rec, r)
+
+			line := combinedLogLine(r, redactedParams, start, rec.statusCode, rec.bytesWritten)
+			mu.Lock()
+			defer mu.Unlock()
+			io.WriteString(out, line)
+		})
+	}
+}
+
+func combinedLogLine(r *http.Request, redactedParams []string, start time.Time, statusCode int, bytesWritten int64) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+
+	user := "-"
+	if username, _, ok := r.BasicAuth(); ok && len(username) != 0 {
+		user = username
+	}
+
+	size := "-"
+	if bytesWritten > 0 {
+		size = strconv.FormatInt(bytesWritten, 10)
+	}
+
+	return fmt.Sprintf("%s - %s [%s] %q %d %s %q %q\n",
+		host, user, start.Format(combinedLogTimeFormat),
+		fmt.Sprintf("%s %s %s", r.Method, redactURL(r.URL, redactedParams), r.Proto),
+		statusCode, size, orDash(r.Referer()), orDash(r.UserAgent()))
+}
+
+func orDash(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+	return value
+}
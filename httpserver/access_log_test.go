@@ -0,0 +1,61 @@
+package httpserver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogCombinedFormat(t *testing.T) {
+	out := &bytes.Buffer{}
+	now := func() time.Time {
+		return time.Date(2018, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+	}
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), accessLog(out, now, defaultRedactedQueryParams), addJsonHeader(""))
+
+	req := httptest.NewRequest("POST", pingRoute+"?trace=1", createPingReq())
+	req.RemoteAddr = "192.0.2.10:53211"
+	req.SetBasicAuth("frank", "secret")
+	req.Header.Set("Referer", "https://citizen.gophersland.com/")
+	req.Header.Set("User-Agent", "curl/7.61.0")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	expected := fmt.Sprintf(`192.0.2.10 - frank [10/Oct/2018:13:55:36 -0700] "POST /ping?trace=1 HTTP/1.1" 200 %d "https://citizen.gophersland.com/" "curl/7.61.0"`+"\n", res.Body.Len())
+	if out.String() != expected {
+		t.Fatalf("logged line '%v' is not as expected one '%v'", out.String(), expected)
+	}
+}
+
+func TestAccessLogDefaultsToDashes(t *testing.T) {
+	out := &bytes.Buffer{}
+	handler := newHandler(newTestConfig(9093).WithAccessLog(out), NewReqHandlersDependencies("test pong"))
+
+	req := httptest.NewRequest("GET", "/missing", nil)
+	req.RemoteAddr = "192.0.2.10:53211"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var host, user, timestamp, zone, requestLine, size, referer, userAgent string
+	var status int
+	_, err := fmt.Sscanf(out.String(), "%s - %s %s %s %q %d %s %q %q\n", &host, &user, &timestamp, &zone, &requestLine, &status, &size, &referer, &userAgent)
+	if err != nil {
+		t.Fatalf("logged line '%v' does not match the combined log format. %v", out.String(), err)
+	}
+	if host != "192.0.2.10" || user != "-" || requestLine != "GET /missing HTTP/1.1" || status != 404 || referer != "-" || userAgent != "-" {
+		t.Fatalf("logged line '%v' is not as expected", out.String())
+	}
+}
+
+func TestAccessLogRedactsQueryParams(t *testing.T) {
+	out := &bytes.Buffer{}
+	handler := newHandler(newTestConfig(9093).WithAccessLog(out).WithRedactedQueryParams("session"), NewReqHandlersDependencies("test pong"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", pingRoute+"?value=ok&session=secret", nil))
+
+	if strings.Contains(out.String(), "secret") || !strings.Contains(out.String(), `"GET /ping?value=ok&session=*** HTTP/1.1"`) {
+		t.Fatalf("logged line '%v' is supposed to hold the redacted session", out.String())
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// authTimeHeader carries the unix time the caller last authenticated at, i.e. the auth_time claim
+// of its token, as forwarded by the authenticating proxy. It is ignored unless the proxy is trusted.
+const authTimeHeader = "X-Auth-Time"
+
+func hasBearerToken(r *http.Request, token string) bool {
+	const prefix = "bearer "
+
+	authorization := r.Header.Get("Authorization")
+	if len(token) == 0 || len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(authorization[len(prefix):]), []byte(token)) == 1
+}
+
+// requireFreshAuth answers with 401, and the RFC 9470 step-up hint, the requests whose authentication
+// is older than maxAge even if their token is still valid. The auth time is only read from the requests
+// forwarded by a Config.WithTrustedProxies proxy, the clients could send any, and must not be ahead of now.
+func requireFreshAuth(maxAge time.Duration, now func() time.Time) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endAuthPhase := timePhase(r.Context(), "auth")
+			authTime, err := strconv.ParseInt(r.Header.Get(authTimeHeader), 10, 64)
+			age := now().Sub(time.Unix(authTime, 0))
+			fresh := fromTrustedProxy(r) && err == nil && age >= 0 && age <= maxAge
+			endAuthPhase()
+			reportAuth(r, fresh)
+			if !fresh {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="citizen", error="insufficient_user_authentication", max_age=%d`, int(maxAge.Seconds())))
+				WriteError(w, CodeUnauthorized, fmt.Errorf("authentication older than %v, please re-authenticate", maxAge))
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireAdmin guards the admin and debug routes, the caller needs the admin token or a verified client
+// certificate whose common name is allowed.
+func requireAdmin(token string, allowedCNs []string) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endAuthPhase := timePhase(r.Context(), "auth")
+			authenticated := hasBearerToken(r, token) || hasAllowedClientCN(r, allowedCNs)
+			endAuthPhase()
+			reportAuth(r, authenticated)
+			if !authenticated {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="citizen-admin"`)
+				WriteError(w, CodeUnauthorized, errors.New("admin credentials required"))
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+func hasAllowedClientCN(r *http.Request, allowedCNs []string) bool {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		return false
+	}
+
+	commonName := r.TLS.PeerCertificates[0].Subject.CommonName
+	for _, allowed := range allowedCNs {
+		if commonName == allowed {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// failedAuthStore counts the failed authentications per client, a client reaching maxFailures within
+// the cooldown is locked out for the cooldown. Entries expire once their cooldown is over.
+type failedAuthStore struct {
+	mu          sync.Mutex
+	maxFailures int
+	cooldown    time.Duration
+	now         func() time.Time
+	clients     map[string]*failedAuths
+	lastSweep   time.Time
+}
+
+type failedAuths struct {
+	count       int
+	expiresAt   time.Time
+	lockedUntil time.Time
+}
+
+func newFailedAuthStore(maxFailures int, cooldown time.Duration, now func() time.Time) *failedAuthStore {
+	return &failedAuthStore{maxFailures: maxFailures, cooldown: cooldown, now: now, clients: map[string]*failedAuths{}}
+}
+
+// lockedFor tells how much longer the client is locked out, zero when it isn't.
+func (s *failedAuthStore) lockedFor(client string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	failures, ok := s.entry(client, now)
+	if !ok {
+		return 0
+	}
+
+	if remaining := failures.lockedUntil.Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+func (s *failedAuthStore) recordFailure(client string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	failures, ok := s.entry(client, now)
+	if !ok {
+		failures = &failedAuths{}
+		s.clients[client] = failures
+	}
+
+	failures.count++
+	failures.expiresAt = now.Add(s.cooldown)
+	if failures.count >= s.maxFailures {
+		failures.lockedUntil = now.Add(s.cooldown)
+	}
+}
+
+func (s *failedAuthStore) reset(client string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.clients, client)
+}
+
+// entry returns the unexpired failures of the client, the expired ones are dropped on the way.
+func (s *failedAuthStore) entry(client string, now time.Time) (*failedAuths, bool) {
+	s.sweep(now)
+
+	failures, ok := s.clients[client]
+	if ok && !now.Before(failures.expiresAt) {
+		delete(s.clients, client)
+		return nil, false
+	}
+
+	return failures, ok
+}
+
+// sweep drops the entries of the clients that never came back, at most once per cooldown so the requests
+// don't pay for a scan of every client.
+func (s *failedAuthStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.cooldown {
+		return
+	}
+
+	s.lastSweep = now
+	for client, failures := range s.clients {
+		if !now.Before(failures.expiresAt) {
+			delete(s.clients, client)
+		}
+	}
+}
+
+type authAttemptCtxKey struct{}
+
+// authAttempt is filled by the auth decorators, through reportAuth, so authLockout only counts the
+// requests that actually tried to authenticate.
+type authAttempt struct {
+	authenticated bool
+	failed        bool
+}
+
+// reportAuth tells authLockout the outcome of an authentication, it does nothing outside of it.
+func reportAuth(r *http.Request, authenticated bool) {
+	attempt, ok := r.Context().Value(authAttemptCtxKey{}).(*authAttempt)
+	if !ok {
+		return
+	}
+
+	if authenticated {
+		attempt.authenticated = true
+	} else {
+		attempt.failed = true
+	}
+}
+
+// authLockout answers with 429 the clients that failed to authenticate too many times. The failures and
+// successes are the ones reported by the auth decorators after it, a successful authentication clears the
+// failures of the client while the requests authenticating nothing leave them untouched.
+func authLockout(store *failedAuthStore) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := clientIP(r)
+			if lockedFor := store.lockedFor(client); lockedFor > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedFor.Seconds()))))
+				WriteError(w, CodeRateLimited, fmt.Errorf("too many failed authentication attempts, retry in %v", lockedFor.Round(time.Second)))
+				return
+			}
+
+			attempt := &authAttempt{}
+			handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authAttemptCtxKey{}, attempt)))
+
+			if attempt.failed {
+				store.recordFailure(client)
+			} else if attempt.authenticated {
+				store.reset(client)
+			}
+		})
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,99 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthLockout(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	store := newFailedAuthStore(3, time.Minute, func() time.Time { return now })
+	handler := decorateHttpRes(decoratorParamsHandler(newLiveParams(DecoratorParams{})), addJsonHeader(""), authLockout(store), requireAdmin("secret", nil))
+
+	send := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", decoratorParamsRoute, nil)
+		req.RemoteAddr = "203.0.113.7:51234"
+		req.Header.Set("Authorization", "Bearer "+token)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		if res := send("wrong"); res.Code != http.StatusUnauthorized {
+			t.Fatalf("failed attempt %v returned response code '%v', expected '%v'", attempt, res.Code, http.StatusUnauthorized)
+		}
+	}
+
+	res := send("secret")
+	if res.Code != http.StatusTooManyRequests {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusTooManyRequests)
+	}
+	if res.Header().Get("Retry-After") != "60" {
+		t.Fatalf("returned Retry-After header '%v' is not as expected one '60'", res.Header().Get("Retry-After"))
+	}
+
+	now = now.Add(61 * time.Second)
+	if res := send("secret"); res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' after the cooldown is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+}
+
+func TestAuthLockoutResetsOnSuccess(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	store := newFailedAuthStore(2, time.Minute, func() time.Time { return now })
+
+	store.recordFailure("203.0.113.7")
+	store.reset("203.0.113.7")
+	store.recordFailure("203.0.113.7")
+
+	if lockedFor := store.lockedFor("203.0.113.7"); lockedFor != 0 {
+		t.Fatalf("client is locked out for %v despite authenticating successfully in between", lockedFor)
+	}
+}
+
+func TestAuthLockoutIgnoresUnauthenticatedRoutes(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithAdminToken("secret").WithAuthLockout(2, time.Minute), NewReqHandlersDependencies("test pong"))
+
+	send := func(method string, path string, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if method == "POST" {
+			req = httptest.NewRequest(method, path, createPingReq())
+		}
+		req.RemoteAddr = "203.0.113.7:51234"
+		req.Header.Set("Authorization", "Bearer "+token)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		send("GET", decoratorParamsRoute, "wrong")
+		if code := send("POST", pingRoute, ""); code != http.StatusOK {
+			t.Fatalf("ping returned response code '%v', expected '%v'", code, http.StatusOK)
+		}
+	}
+
+	if code := send("GET", decoratorParamsRoute, "secret"); code != http.StatusTooManyRequests {
+		t.Fatalf("returned response code '%v' is not as expected one '%v', the pings are not supposed to clear the failures", code, http.StatusTooManyRequests)
+	}
+	if code := send("POST", pingRoute, ""); code != http.StatusOK {
+		t.Fatalf("ping of a locked out client returned response code '%v', expected '%v'", code, http.StatusOK)
+	}
+}
+
+func TestFailedAuthStoreExpiresEntries(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	store := newFailedAuthStore(2, time.Minute, func() time.Time { return now })
+
+	store.recordFailure("203.0.113.7")
+	store.recordFailure("203.0.113.8")
+	now = now.Add(2 * time.Minute)
+	store.lockedFor("203.0.113.9")
+
+	if len(store.clients) != 0 {
+		t.Fatalf("store holds '%v' clients, the expired ones are supposed to be dropped", len(store.clients))
+	}
+}
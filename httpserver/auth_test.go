@@ -0,0 +1,107 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequireFreshAuth(t *testing.T) {
+	now := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), realIP([]string{"192.0.2.1"}), addJsonHeader(""), requireFreshAuth(15*time.Minute, clock))
+
+	testCases := []struct {
+		authTime     string
+		remoteAddr   string
+		expectedCode int
+	}{
+		{strconv.FormatInt(now.Add(-5*time.Minute).Unix(), 10), "192.0.2.1:51234", http.StatusOK},
+		{strconv.FormatInt(now.Add(-16*time.Minute).Unix(), 10), "192.0.2.1:51234", http.StatusUnauthorized},
+		{strconv.FormatInt(now.Add(5*time.Minute).Unix(), 10), "192.0.2.1:51234", http.StatusUnauthorized},
+		{strconv.FormatInt(now.Add(-5*time.Minute).Unix(), 10), "203.0.113.7:51234", http.StatusUnauthorized},
+		{"", "192.0.2.1:51234", http.StatusUnauthorized},
+		{"yesterday", "192.0.2.1:51234", http.StatusUnauthorized},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("POST", pingRoute, createPingReq())
+		req.RemoteAddr = testCase.remoteAddr
+		req.Header.Set(authTimeHeader, testCase.authTime)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != testCase.expectedCode {
+			t.Fatalf("auth time '%v' from '%v' returned response code '%v', expected '%v'", testCase.authTime, testCase.remoteAddr, res.Code, testCase.expectedCode)
+		}
+
+		if testCase.expectedCode == http.StatusUnauthorized && !strings.Contains(res.Header().Get("WWW-Authenticate"), "max_age=900") {
+			t.Fatalf("returned WWW-Authenticate header '%v' is missing the re-authentication hint", res.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestFreshAuthRoutesAnswerCORSPreflights(t *testing.T) {
+	group := RouteGroup{Prefix: "/sensitive", Routes: []Route{{Path: pingRoute, Handler: pingHandlerImpl("test pong"), Methods: []string{http.MethodPost}, MaxAuthAge: 15 * time.Minute}}}
+	handler := newHandler(newTestConfig(9093).WithCORS(10*time.Minute, "https://app.example.com"), NewReqHandlersDependencies("test pong").WithRouteGroups(group))
+
+	req := httptest.NewRequest("OPTIONS", "/sensitive"+pingRoute, nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusNoContent)
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	cfg := newTestConfig(9093).WithAdminToken("admin-secret").WithAdminClientCNs("ops.citizen")
+	handler := newHandler(cfg, NewReqHandlersDependencies("test pong"))
+
+	testCases := []struct {
+		name         string
+		route        string
+		token        string
+		clientCN     string
+		expectedCode int
+	}{
+		{"metrics without credentials", metricsRoute, "", "", http.StatusUnauthorized},
+		{"params without credentials", decoratorParamsRoute, "", "", http.StatusUnauthorized},
+		{"metrics with wrong token", metricsRoute, "wrong", "", http.StatusUnauthorized},
+		{"metrics with disallowed client CN", metricsRoute, "", "someone.else", http.StatusUnauthorized},
+		{"metrics with admin token", metricsRoute, "admin-secret", "", http.StatusOK},
+		{"params with admin token", decoratorParamsRoute, "admin-secret", "", http.StatusOK},
+		{"metrics with allowed client CN", metricsRoute, "", "ops.citizen", http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("GET", testCase.route, nil)
+		if len(testCase.token) != 0 {
+			req.Header.Set("Authorization", "Bearer "+testCase.token)
+		}
+		if len(testCase.clientCN) != 0 {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: testCase.clientCN}}
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != testCase.expectedCode {
+			t.Fatalf("%v returned response code '%v', expected '%v'", testCase.name, res.Code, testCase.expectedCode)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if res.Code != http.StatusOK {
+		t.Fatalf("public ping returned response code '%v', expected '%v'", res.Code, http.StatusOK)
+	}
+}
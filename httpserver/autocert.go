@@ -0,0 +1,55 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	acmeChallengeAddr = ":80"
+)
+
+func newAutocertManager(cfg Config) *autocert.Manager {
+	certManager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.autocertHosts...),
+	}
+	if len(cfg.autocertCacheDir) != 0 {
+		certManager.Cache = autocert.DirCache(cfg.autocertCacheDir)
+	}
+
+	return certManager
+}
+
+// serveAcmeChallenge answers the Let's Encrypt HTTP-01 challenges and redirects everything else to HTTPS.
+// It returns once the ctx is done and the challenge server is drained within drainTimeout.
+func serveAcmeChallenge(ctx context.Context, certManager *autocert.Manager, drainTimeout time.Duration, logger Logger) {
+	challengeServer := &http.Server{Addr: acmeChallengeAddr, Handler: certManager.HTTPHandler(nil)}
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+
+		// ctx is already done, the drain gets its own deadline.
+		drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+		err := challengeServer.Shutdown(drainCtx)
+		if err != nil {
+			logger.Error("ACME HTTP-01 challenge server didn't drain in time.", "error", err)
+			challengeServer.Close()
+		}
+	}()
+
+	err := challengeServer.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		logger.Error("ACME HTTP-01 challenge server stopped.", "error", err)
+	}
+	<-shutdownDone
+}
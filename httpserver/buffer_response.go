@@ -0,0 +1,58 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import "net/http"
+
+// bufferResponse holds the status, headers and body until the handler returns, so a handler discovering
+// an error late can still replace what it wrote, see DiscardBufferedResponse.
+func bufferResponse() httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			buffered := &discardableResponse{
+				bufferedResponse: &bufferedResponse{header: w.Header().Clone(), statusCode: http.StatusOK, indent: wantsIndentedJSON(w)},
+				initialHeader:    w.Header().Clone(),
+			}
+			handler.ServeHTTP(buffered, r)
+
+			for key, values := range buffered.header {
+				w.Header()[key] = values
+			}
+			for key := range w.Header() {
+				if _, ok := buffered.header[key]; !ok {
+					w.Header().Del(key)
+				}
+			}
+			w.WriteHeader(buffered.statusCode)
+			w.Write(buffered.body.Bytes())
+		})
+	}
+}
+
+// discardableResponse remembers the headers set before the handler ran, a discard restores them.
+type discardableResponse struct {
+	*bufferedResponse
+	initialHeader http.Header
+}
+
+// DiscardBufferedResponse drops the status, headers and body written so far on a Route.BufferResponse route,
+// the handler can then write its error response as if nothing was written. It returns false once the
+// response reached the client and can't be replaced anymore.
+func DiscardBufferedResponse(w http.ResponseWriter) bool {
+	for {
+		if buffered, ok := w.(*discardableResponse); ok {
+			buffered.header = buffered.initialHeader.Clone()
+			buffered.body.Reset()
+			buffered.statusCode = http.StatusOK
+			buffered.wroteHeader = false
+			return true
+		}
+
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = unwrapper.Unwrap()
+	}
+}
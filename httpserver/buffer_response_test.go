@@ -0,0 +1,73 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBufferResponseLateError(t *testing.T) {
+	lateFailure := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, pingRes{"partial"}, http.StatusOK)
+
+		if !DiscardBufferedResponse(w) {
+			t.Fatal("buffered response is supposed to be discardable")
+		}
+		WriteError(w, CodeInternal, errors.New("late failure"))
+	})
+	group := RouteGroup{Prefix: "/buffered", Routes: []Route{{Path: pingRoute, Handler: lateFailure, BufferResponse: true}}}
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithRouteGroups(group))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", "/buffered"+pingRoute, nil))
+
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusInternalServerError)
+	}
+
+	var errRes errorRes
+	err := json.Unmarshal(res.Body.Bytes(), &errRes)
+	if err != nil {
+		t.Fatalf("returned response '%v' holds more than the late error. %v", res.Body.String(), err)
+	}
+	if errRes.Error.Message != "late failure" {
+		t.Fatalf("returned error '%v' is not as expected one '%v'", errRes.Error, "late failure")
+	}
+}
+
+func TestDiscardBufferedResponseResetsHeaders(t *testing.T) {
+	lateFailure := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Location", "/buffered/partial")
+		writeResponse(w, pingRes{"partial"}, http.StatusCreated)
+
+		DiscardBufferedResponse(w)
+		WriteError(w, CodeInternal, errors.New("late failure"))
+	})
+	group := RouteGroup{Prefix: "/buffered", Routes: []Route{{Path: pingRoute, Handler: lateFailure, BufferResponse: true}}}
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithRouteGroups(group))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", "/buffered"+pingRoute, nil))
+
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusInternalServerError)
+	}
+	for _, header := range []string{"Cache-Control", "Location"} {
+		if value := res.Header().Get(header); len(value) != 0 {
+			t.Fatalf("discarded header '%v' is still sent with '%v'", header, value)
+		}
+	}
+	if res.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("returned response header '%v' is not '%v'", res.Header().Get("Content-Type"), "application/json")
+	}
+}
+
+func TestDiscardUnbufferedResponse(t *testing.T) {
+	res := httptest.NewRecorder()
+	if DiscardBufferedResponse(newStatusRecorder(res)) {
+		t.Fatal("unbuffered response is not supposed to be discardable")
+	}
+}
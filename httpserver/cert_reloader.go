@@ -0,0 +1,104 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// certReloader serves the key pair files through tls.Config.GetCertificate and loads them again on reload,
+// the new connections get the fresh certificates while the established ones keep theirs.
+type certReloader struct {
+	mu       sync.RWMutex
+	keyPairs []keyPairFiles
+	certs    []tls.Certificate
+	leafs    []*x509.Certificate
+}
+
+func newCertReloader(keyPairs []keyPairFiles) (*certReloader, error) {
+	reloader := &certReloader{keyPairs: keyPairs}
+	err := reloader.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// reload swaps the certificates only once every key pair loaded, a half rotated set is never served.
+func (reloader *certReloader) reload() error {
+	certs := make([]tls.Certificate, 0, len(reloader.keyPairs))
+	for _, keyPair := range reloader.keyPairs {
+		cert, err := tls.LoadX509KeyPair(keyPair.certificatePemFilePath, keyPair.certificatePemPrivKeyFilePath)
+		if err != nil {
+			return fmt.Errorf("unable to load TLS key pair %s. %s", keyPair.certificatePemFilePath, err.Error())
+		}
+		certs = append(certs, cert)
+	}
+	leafs, err := certificateLeaves(&tls.Config{Certificates: certs})
+	if err != nil {
+		return err
+	}
+
+	reloader.mu.Lock()
+	reloader.certs = certs
+	reloader.leafs = leafs
+	reloader.mu.Unlock()
+
+	return nil
+}
+
+// getCertificate picks the certificate matching the client SNI, the first one is the fallback.
+func (reloader *certReloader) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+
+	for i := range reloader.certs {
+		if hello.SupportsCertificate(&reloader.certs[i]) == nil {
+			return &reloader.certs[i], nil
+		}
+	}
+
+	return &reloader.certs[0], nil
+}
+
+// leaves are the leaf certificates currently served, parsed once per reload.
+func (reloader *certReloader) leaves() []*x509.Certificate {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+
+	return reloader.leafs
+}
+
+// reloadOnSIGHUP reloads the certificates on every SIGHUP until ctx is done. The signal is already
+// subscribed to once it returns.
+func (reloader *certReloader) reloadOnSIGHUP(ctx context.Context, logger Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				err := reloader.reload()
+				if err != nil {
+					logger.Error("Unable to reload the TLS certificates, keeping the current ones.", "error", err)
+					continue
+				}
+				logger.Info("Reloaded the TLS certificates.")
+			}
+		}
+	}()
+}
@@ -0,0 +1,57 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCertReloadOnSIGHUP(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeSelfSignedKeyPair(t, dir, "localhost")
+	cfg := NewConfig(0, certPath, keyPath).WithCertReloadOnSIGHUP()
+	baseURL, cleanup := newTestServer(t, cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	defer cleanup()
+	addr := strings.TrimPrefix(baseURL, "https://")
+
+	initialSerial := servedCertSerial(t, addr)
+
+	rotatedCert, rotatedKey := writeSelfSignedKeyPair(t, t.TempDir(), "localhost")
+	for source, destination := range map[string]string{rotatedCert: certPath, rotatedKey: keyPath} {
+		content, err := ioutil.ReadFile(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(destination, content, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if servedCertSerial(t, addr) != initialSerial {
+		t.Fatal("certificate was rotated before SIGHUP")
+	}
+
+	err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for start := time.Now(); servedCertSerial(t, addr) == initialSerial; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("certificate served after SIGHUP is still the initial one")
+		}
+	}
+}
+
+func servedCertSerial(t *testing.T, addr string) string {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: "localhost", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.String()
+}
@@ -0,0 +1,26 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import "net/http"
+
+// Chain is an ordered list of decorators captured once and applied to many handlers, the first one is the outermost.
+type Chain struct {
+	decorators []httpResDecorator
+}
+
+// NewChain captures the middlewares in order, the first one is the outermost.
+func NewChain(decorators ...Middleware) Chain {
+	return Chain{append([]httpResDecorator(nil), decorators...)}
+}
+
+// Then decorates the handler with the chain.
+func (c Chain) Then(handler http.Handler) http.Handler {
+	return decorateHttpRes(handler, c.decorators...)
+}
+
+// Append returns a copy of the chain with the decorators added as the innermost ones, the chain itself is left untouched.
+func (c Chain) Append(decorators ...Middleware) Chain {
+	return NewChain(append(append([]httpResDecorator(nil), c.decorators...), decorators...)...)
+}
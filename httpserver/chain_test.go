@@ -0,0 +1,69 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChainAppliesIdenticallyToHandlers(t *testing.T) {
+	tag := func(name string) httpResDecorator {
+		return func(handler http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Order", name)
+				handler.ServeHTTP(w, r)
+			})
+		}
+	}
+	chain := NewChain(tag("outer"), tag("inner"))
+
+	handlers := []http.Handler{
+		chain.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("first")) })),
+		chain.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("second")) })),
+	}
+
+	for _, handler := range handlers {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+
+		order := strings.Join(res.Header()["X-Order"], ",")
+		if order != "outer,inner" {
+			t.Fatalf("returned decorator order '%v' is not as expected one '%v'", order, "outer,inner")
+		}
+	}
+}
+
+func TestChainAppendReturnsCopy(t *testing.T) {
+	tag := func(name string) httpResDecorator {
+		return func(handler http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Order", name)
+				handler.ServeHTTP(w, r)
+			})
+		}
+	}
+	base := NewChain(tag("base"))
+	extended := base.Append(tag("extended"))
+	other := base.Append(tag("other"))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	testCases := []struct {
+		chain         Chain
+		expectedOrder string
+	}{
+		{base, "base"},
+		{extended, "base,extended"},
+		{other, "base,other"},
+	}
+
+	for _, testCase := range testCases {
+		res := httptest.NewRecorder()
+		testCase.chain.Then(handler).ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+
+		order := strings.Join(res.Header()["X-Order"], ",")
+		if order != testCase.expectedOrder {
+			t.Fatalf("returned decorator order '%v' is not as expected one '%v'", order, testCase.expectedOrder)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gophersland/citizen/citizen"
+	"github.com/gophersland/citizen/storage"
+)
+
+const (
+	citizensRoute       = "/citizens"
+	citizenRoute        = "/citizens/{id}"
+	citizensExportRoute = "/citizens/export"
+	citizensImportRoute = "/citizens/import"
+	// maxImportBodyBytes bounds the import body, held in memory until every citizen of it is validated.
+	maxImportBodyBytes = 32 << 20
+	// citizensStorage names the citizen repository, e.g. its citizens.db bolt file.
+	citizensStorage          = "citizens"
+	citizensStorageCheckName = "citizen_storage"
+)
+
+// NewCitizenRegistry opens the storage selected by Config.WithStorage, pass the registry to the server
+// with ReqHandlersDependencies.WithCitizenRegistry and close it once the server is done.
+func NewCitizenRegistry(cfg Config) (*citizen.Registry, error) {
+	return NewCitizenRegistryFromStorage(cfg.storage)
+}
+
+// NewCitizenRegistryFromStorage is NewCitizenRegistry for a storage.Config read on its own, e.g. with
+// LoadStorageConfig, by the tools exporting or importing the citizens.
+func NewCitizenRegistryFromStorage(storageCfg storage.Config) (*citizen.Registry, error) {
+	repo, err := storage.Open[citizen.Citizen](storageCfg, citizensStorage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the citizen storage. %s", err.Error())
+	}
+
+	return citizen.NewRegistry(repo), nil
+}
+
+// MigrateStorage applies the schema migrations to the storage selected by Config.WithStorage, see storage.Migrate.
+func MigrateStorage(ctx context.Context, cfg Config) (applied int, version int, err error) {
+	return storage.Migrate(ctx, cfg.storage)
+}
+
+type citizenReq struct {
+	Name      string `json:"name" xml:"name"`
+	PublicKey string `json:"public_key" xml:"public_key"`
+}
+
+// listCitizensReq is read from the query of GET /citizens, e.g. ?name_prefix=Al&sort=-joined_at&limit=20.
+type listCitizensReq struct {
+	Limit       int    `json:"limit"`
+	Offset      int    `json:"offset"`
+	NamePrefix  string `json:"name_prefix"`
+	JoinedAfter string `json:"joined_after"`
+	Sort        string `json:"sort"`
+}
+
+func (req listCitizensReq) Validate() error {
+	_, err := req.joinedAfter()
+	return err
+}
+
+// joinedAfter parses the RFC 3339 joined_after parameter, its zero value doesn't filter.
+func (req listCitizensReq) joinedAfter() (time.Time, error) {
+	if len(req.JoinedAfter) == 0 {
+		return time.Time{}, nil
+	}
+
+	joinedAfter, err := time.Parse(time.RFC3339, req.JoinedAfter)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query parameter 'joined_after' must be an RFC 3339 time, e.g. 2018-01-02T15:04:05Z")
+	}
+
+	return joinedAfter, nil
+}
+
+type citizenListRes struct {
+	Citizens   []citizen.Citizen `json:"citizens" xml:"citizens>citizen"`
+	Pagination paginationRes     `json:"pagination" xml:"pagination"`
+}
+
+// paginationRes has a NextOffset only when more citizens match past the page.
+type paginationRes struct {
+	Limit      int  `json:"limit" xml:"limit"`
+	Offset     int  `json:"offset" xml:"offset"`
+	Total      int  `json:"total" xml:"total"`
+	NextOffset *int `json:"next_offset,omitempty" xml:"next_offset,omitempty"`
+}
+
+type importCitizensRes struct {
+	Imported    int `json:"imported" xml:"imported"`
+	Overwritten int `json:"overwritten" xml:"overwritten"`
+	Skipped     int `json:"skipped" xml:"skipped"`
+}
+
+// citizenRoutes lists or registers the citizens with a GET or POST on /citizens, and fetches, updates or
+// deletes one with a GET, PUT or DELETE on /citizens/{id}.
+func citizenRoutes(registry *citizen.Registry, logger Logger) []Route {
+	collectionByMethod := map[string]http.Handler{
+		http.MethodGet:  listCitizensHandler(registry, logger),
+		http.MethodPost: registerCitizenHandler(registry, logger),
+	}
+	byMethod := map[string]http.Handler{
+		http.MethodGet:    getCitizenHandler(registry, logger),
+		http.MethodPut:    updateCitizenHandler(registry, logger),
+		http.MethodDelete: deleteCitizenHandler(registry, logger),
+	}
+
+	return []Route{
+		{Path: citizensRoute, Handler: dispatchByMethod(collectionByMethod), Methods: sortedMethods(collectionByMethod)},
+		{Path: citizenRoute, Handler: dispatchByMethod(byMethod), Methods: sortedMethods(byMethod)},
+	}
+}
+
+// citizenAdminRoutes back the registry up with a GET on /citizens/export and restore it with a POST on
+// /citizens/import. They dump or replace every citizen, so they are mounted behind the admin auth.
+func citizenAdminRoutes(registry *citizen.Registry, logger Logger) []Route {
+	return []Route{
+		{Path: citizensExportRoute, Handler: exportCitizensHandler(registry, logger), Methods: []string{http.MethodGet}},
+		{Path: citizensImportRoute, Handler: importCitizensHandler(registry, logger), Methods: []string{http.MethodPost}},
+	}
+}
+
+func listCitizensHandler(registry *citizen.Registry, logger Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := listCitizensReq{}
+		err := readRequest(r, &req)
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, readRequestErrCode(err), err))
+			return
+		}
+
+		joinedAfter, _ := req.joinedAfter()
+		page, err := registry.Query(r.Context(), citizen.ListQuery{
+			NamePrefix:  req.NamePrefix,
+			JoinedAfter: joinedAfter,
+			Sort:        req.Sort,
+			Limit:       req.Limit,
+			Offset:      req.Offset,
+		})
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, citizenErrCode(err), err))
+			return
+		}
+
+		res := citizenListRes{
+			Citizens:   page.Citizens,
+			Pagination: paginationRes{Limit: page.Limit, Offset: page.Offset, Total: page.Total},
+		}
+		if page.HasNext() {
+			nextOffset := page.Offset + len(page.Citizens)
+			res.Pagination.NextOffset = &nextOffset
+		}
+		logWriteErr(logger, r, writeNegotiated(w, r, res, http.StatusOK))
+	})
+}
+
+func registerCitizenHandler(registry *citizen.Registry, logger Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := citizenReq{}
+		err := readRequest(r, &req)
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, readRequestErrCode(err), err))
+			return
+		}
+
+		registered, err := registry.Register(r.Context(), req.Name, req.PublicKey)
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, citizenErrCode(err), err))
+			return
+		}
+
+		w.Header().Set("Location", citizensRoute+"/"+registered.ID)
+		logWriteErr(logger, r, writeNegotiated(w, r, registered, http.StatusCreated))
+	})
+}
+
+func getCitizenHandler(registry *citizen.Registry, logger Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		found, err := registry.Get(r.Context(), r.PathValue("id"))
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, citizenErrCode(err), err))
+			return
+		}
+
+		logWriteErr(logger, r, writeNegotiated(w, r, found, http.StatusOK))
+	})
+}
+
+func updateCitizenHandler(registry *citizen.Registry, logger Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := citizenReq{}
+		err := readRequest(r, &req)
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, readRequestErrCode(err), err))
+			return
+		}
+
+		updated, err := registry.Update(r.Context(), r.PathValue("id"), req.Name, req.PublicKey)
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, citizenErrCode(err), err))
+			return
+		}
+
+		logWriteErr(logger, r, writeNegotiated(w, r, updated, http.StatusOK))
+	})
+}
+
+func deleteCitizenHandler(registry *citizen.Registry, logger Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := registry.Delete(r.Context(), r.PathValue("id"))
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, citizenErrCode(err), err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// exportCitizensHandler streams every citizen as NDJSON, the default, or as CSV with ?format=csv.
+func exportCitizensHandler(registry *citizen.Registry, logger Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format, err := citizen.ParseFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, citizenErrCode(err), err))
+			return
+		}
+
+		contentType := "application/x-ndjson"
+		if format == citizen.FormatCSV {
+			contentType = "text/csv; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="citizens.%s"`, format))
+
+		rec := newStatusRecorder(w)
+		_, err = registry.Export(r.Context(), rec, format)
+		if err != nil && !rec.wroteHeader {
+			w.Header().Del("Content-Disposition")
+			logWriteErr(logger, r, writeNegotiatedError(w, r, citizenErrCode(err), err))
+			return
+		}
+		// Past the first byte, the client only sees a truncated export.
+		logWriteErr(logger, r, err)
+	})
+}
+
+// importCitizensHandler stores the citizens of an export body, read as ?format=ndjson or csv. Under the
+// default ?conflict=fail, an already registered citizen aborts the import with a 409, skip and overwrite
+// keep or replace it instead. The body is bounded by maxImportBodyBytes.
+func importCitizensHandler(registry *citizen.Registry, logger Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		format, err := citizen.ParseFormat(query.Get("format"))
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, citizenErrCode(err), err))
+			return
+		}
+		policy, err := citizen.ParseConflictPolicy(query.Get("conflict"))
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, citizenErrCode(err), err))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBodyBytes))
+		if err != nil {
+			err = fmt.Errorf("unable to read request body. %w", err)
+			logWriteErr(logger, r, writeNegotiatedError(w, r, readRequestErrCode(err), err))
+			return
+		}
+
+		result, err := registry.Import(r.Context(), bytes.NewReader(body), format, policy)
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, citizenErrCode(err), err))
+			return
+		}
+
+		logWriteErr(logger, r, writeNegotiated(w, r, importCitizensRes(result), http.StatusOK))
+	})
+}
+
+func citizenErrCode(err error) ErrorCode {
+	var validationErr *citizen.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return CodeInvalidRequest
+	case errors.Is(err, citizen.ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, citizen.ErrConflict):
+		return CodeConflict
+	default:
+		return CodeInternal
+	}
+}
@@ -0,0 +1,272 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gophersland/citizen/citizen"
+	"github.com/gophersland/citizen/storage"
+)
+
+func newTestPublicKey(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func newCitizenReq(t *testing.T, name string, publicKey string) *strings.Reader {
+	body, err := json.Marshal(citizenReq{Name: name, PublicKey: publicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.NewReader(string(body))
+}
+
+func TestCitizenRoutes(t *testing.T) {
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger).WithCitizenRegistry(citizen.NewRegistry(storage.NewMemory[citizen.Citizen]())))
+	publicKey := newTestPublicKey(t)
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", citizensRoute, newCitizenReq(t, "gopher", publicKey)))
+	if res.Code != http.StatusCreated {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusCreated)
+	}
+	var registered citizen.Citizen
+	err := json.Unmarshal(res.Body.Bytes(), &registered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	location := res.Header().Get("Location")
+	if location != citizensRoute+"/"+registered.ID || registered.Name != "gopher" {
+		t.Fatalf("registered citizen '%+v' at '%v' is not as expected", registered, location)
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("PUT", location, newCitizenReq(t, "go gopher", publicKey)))
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", location, nil))
+	var found citizen.Citizen
+	err = json.Unmarshal(res.Body.Bytes(), &found)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != http.StatusOK || found.Name != "go gopher" || found.ID != registered.ID {
+		t.Fatalf("returned citizen '%+v' with response code '%v' is not the updated one", found, res.Code)
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("DELETE", location, nil))
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusNoContent)
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", location, nil))
+	if res.Code != http.StatusNotFound {
+		t.Fatalf("returned response code '%v' of a deleted citizen is not as expected one '%v'", res.Code, http.StatusNotFound)
+	}
+}
+
+func TestCitizenRoutesRejectInvalidCitizens(t *testing.T) {
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger).WithCitizenRegistry(citizen.NewRegistry(storage.NewMemory[citizen.Citizen]())))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", citizensRoute, newCitizenReq(t, "gopher", "not a key")))
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusBadRequest)
+	}
+	var errRes errorRes
+	err := json.Unmarshal(res.Body.Bytes(), &errRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errRes.Error.Code != CodeInvalidRequest || !strings.Contains(errRes.Error.Message, "public_key") {
+		t.Fatalf("returned error '%+v' is not the public key validation error", errRes.Error)
+	}
+}
+
+func TestNewCitizenRegistryOpensConfiguredStorage(t *testing.T) {
+	cfg := newTestConfig(9093).WithStorage(storage.Config{Backend: storage.BackendFile, Path: filepath.Join(t.TempDir(), "citizens.json")})
+	registry, err := NewCitizenRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered, err := registry.Register(context.Background(), "gopher", newTestPublicKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewCitizenRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := reopened.Get(context.Background(), registered.ID)
+	if err != nil || found.Name != "gopher" {
+		t.Fatalf("found citizen '%+v' with error '%v' is not the registered one", found, err)
+	}
+}
+
+type unreachableRepository struct {
+	storage.Repository[citizen.Citizen]
+}
+
+func (unreachableRepository) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestCitizenStorageReadiness(t *testing.T) {
+	registry := citizen.NewRegistry(unreachableRepository{storage.NewMemory[citizen.Citizen]()})
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger).WithCitizenRegistry(registry))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", readyzRoute, nil))
+
+	if res.Code != http.StatusServiceUnavailable {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusServiceUnavailable)
+	}
+	if !strings.Contains(res.Body.String(), citizensStorageCheckName) {
+		t.Fatalf("returned body '%v' does not name the failing storage check", res.Body.String())
+	}
+}
+
+func TestListCitizensRoute(t *testing.T) {
+	registry := citizen.NewRegistry(storage.NewMemory[citizen.Citizen]())
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger).WithCitizenRegistry(registry))
+	publicKey := newTestPublicKey(t)
+	for _, name := range []string{"gopher", "alice", "gordon"} {
+		_, err := registry.Register(context.Background(), name, publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", citizensRoute+"?name_prefix=go&sort=-name&limit=1", nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+	var list citizenListRes
+	err := json.Unmarshal(res.Body.Bytes(), &list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Citizens) != 1 || list.Citizens[0].Name != "gordon" {
+		t.Fatalf("listed citizens '%+v' are not as expected", list.Citizens)
+	}
+	if list.Pagination.Total != 2 || list.Pagination.Limit != 1 || list.Pagination.NextOffset == nil || *list.Pagination.NextOffset != 1 {
+		t.Fatalf("returned pagination '%+v' is not as expected", list.Pagination)
+	}
+
+	for _, query := range []string{"?limit=abc", "?limit=100000", "?joined_after=yesterday", "?sort=public_key"} {
+		res = httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", citizensRoute+query, nil))
+		if res.Code != http.StatusBadRequest {
+			t.Fatalf("returned response code '%v' of '%v' is not as expected one '%v'", res.Code, query, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestExportImportCitizenRoutes(t *testing.T) {
+	source := citizen.NewRegistry(storage.NewMemory[citizen.Citizen]())
+	_, err := source.Register(context.Background(), "gopher", newTestPublicKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := newTestConfig(9093).WithAdminToken("admin-secret")
+	handler := newHandler(cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger).WithCitizenRegistry(source))
+	adminReq := func(method string, target string, body string) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		return req
+	}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, adminReq("GET", citizensExportRoute+"?format=csv", ""))
+	if res.Code != http.StatusOK || res.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("returned response code '%v' of type '%v' is not the CSV export", res.Code, res.Header().Get("Content-Type"))
+	}
+	export := res.Body.String()
+
+	target := citizen.NewRegistry(storage.NewMemory[citizen.Citizen]())
+	handler = newHandler(cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger).WithCitizenRegistry(target))
+	tests := []struct {
+		conflict     string
+		expectedCode int
+		expectedRes  importCitizensRes
+	}{
+		{"", http.StatusOK, importCitizensRes{Imported: 1}},
+		{"fail", http.StatusConflict, importCitizensRes{}},
+		{"skip", http.StatusOK, importCitizensRes{Skipped: 1}},
+		{"overwrite", http.StatusOK, importCitizensRes{Overwritten: 1}},
+		{"ignore", http.StatusBadRequest, importCitizensRes{}},
+	}
+	for _, test := range tests {
+		res = httptest.NewRecorder()
+		handler.ServeHTTP(res, adminReq("POST", citizensImportRoute+"?format=csv&conflict="+test.conflict, export))
+		if res.Code != test.expectedCode {
+			t.Fatalf("returned response code '%v' of conflict '%v' is not as expected one '%v'", res.Code, test.conflict, test.expectedCode)
+		}
+		if res.Code != http.StatusOK {
+			continue
+		}
+
+		var result importCitizensRes
+		err = json.Unmarshal(res.Body.Bytes(), &result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != test.expectedRes {
+			t.Fatalf("returned import result '%+v' of conflict '%v' is not as expected one '%+v'", result, test.conflict, test.expectedRes)
+		}
+	}
+}
+
+func TestExportImportCitizenRoutesRequireAdmin(t *testing.T) {
+	registry := citizen.NewRegistry(storage.NewMemory[citizen.Citizen]())
+	deps := NewReqHandlersDependencies("test pong").WithLogger(NoopLogger).WithCitizenRegistry(registry)
+
+	testCases := []struct {
+		name         string
+		cfg          Config
+		method       string
+		route        string
+		expectedCode int
+	}{
+		{"export without admin auth", newTestConfig(9093), "GET", citizensExportRoute, http.StatusNotFound},
+		{"import without admin auth", newTestConfig(9093), "POST", citizensImportRoute, http.StatusMethodNotAllowed},
+		{"export without credentials", newTestConfig(9093).WithAdminToken("admin-secret"), "GET", citizensExportRoute, http.StatusUnauthorized},
+		{"import without credentials", newTestConfig(9093).WithAdminToken("admin-secret"), "POST", citizensImportRoute, http.StatusUnauthorized},
+	}
+
+	for _, testCase := range testCases {
+		res := httptest.NewRecorder()
+		newHandler(testCase.cfg, deps).ServeHTTP(res, httptest.NewRequest(testCase.method, testCase.route, strings.NewReader("")))
+		if res.Code != testCase.expectedCode {
+			t.Fatalf("%v returned response code '%v', expected '%v'", testCase.name, res.Code, testCase.expectedCode)
+		}
+	}
+}
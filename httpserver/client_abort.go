@@ -0,0 +1,32 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"syscall"
+)
+
+// statusClientClosedRequest is the nginx 499, the client went away before the response was written.
+const statusClientClosedRequest = 499
+
+// isClientAborted tells the errors caused by a client disconnecting mid-request, normal churn rather than failures.
+func isClientAborted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// logWriteErr logs a failed response write, at debug level when the client is the one gone.
+func logWriteErr(logger Logger, r *http.Request, err error) {
+	if err == nil {
+		return
+	}
+
+	if isClientAborted(err) {
+		logger.Debug("Client aborted the request.", "path", r.URL.Path, "status", statusClientClosedRequest, "error", err, "request_id", RequestIDFromContext(r.Context()))
+		return
+	}
+	logger.Error("Unable to write response.", "path", r.URL.Path, "error", err, "request_id", RequestIDFromContext(r.Context()))
+}
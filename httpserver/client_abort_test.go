@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// ctxBlockingReader blocks the body read until the request context is done, like a client gone mid-upload.
+type ctxBlockingReader struct {
+	ctx context.Context
+}
+
+func (r ctxBlockingReader) Read(p []byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, fmt.Errorf("connection closed")
+}
+
+func TestPingHandlerClientCancelledWhileReading(t *testing.T) {
+	out := &bytes.Buffer{}
+	handler := pingHandler(staticMessage("test pong"), NewStdDebugLogger(out))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("POST", pingRoute, ctxBlockingReader{ctx}).WithContext(ctx)
+	res := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(res, req)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	if res.Body.Len() != 0 {
+		t.Fatalf("response '%v' is not supposed to be written to an aborted client", res.Body.String())
+	}
+	if !strings.Contains(out.String(), "DEBUG Client aborted the request. path=/ping status=499") {
+		t.Fatalf("logged output '%v' does not hold the client abort at debug level", out.String())
+	}
+	if strings.Contains(out.String(), "ERROR") {
+		t.Fatalf("logged output '%v' is not supposed to hold errors", out.String())
+	}
+}
+
+func TestIsClientAborted(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{fmt.Errorf("unable to write response. %w", syscall.EPIPE), true},
+		{fmt.Errorf("unable to read request body. %w", context.Canceled), true},
+		{fmt.Errorf("request body must be a non-empty JSON object"), false},
+	}
+
+	for _, testCase := range testCases {
+		if isClientAborted(testCase.err) != testCase.expected {
+			t.Fatalf("error '%v' is expected to be client aborted: %v", testCase.err, testCase.expected)
+		}
+	}
+}
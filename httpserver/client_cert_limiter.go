@@ -0,0 +1,135 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// clientCertConns counts the open connections of every client certificate identity. A connection takes
+// its slot once it turns active, the handshake is done by then, and holds it until it's closed.
+type clientCertConns struct {
+	max      int
+	mu       sync.Mutex
+	admitted map[net.Conn]string
+	refused  map[net.Conn]string
+	active   map[string]int
+}
+
+func newClientCertConns(max int) *clientCertConns {
+	return &clientCertConns{
+		max:      max,
+		admitted: make(map[net.Conn]string),
+		refused:  make(map[net.Conn]string),
+		active:   make(map[string]int),
+	}
+}
+
+// trackConn is an http.Server ConnState callback.
+func (c *clientCertConns) trackConn(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateActive:
+		c.admit(conn)
+	case http.StateClosed, http.StateHijacked:
+		c.release(conn)
+	}
+}
+
+func (c *clientCertConns) admit(conn net.Conn) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return
+	}
+	identity, ok := clientCertIdentity(tlsConn.ConnectionState().PeerCertificates)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.admitted[conn]; ok {
+		return
+	}
+	if _, ok := c.refused[conn]; ok {
+		return
+	}
+	if c.active[identity] >= c.max {
+		c.refused[conn] = identity
+		return
+	}
+	c.admitted[conn] = identity
+	c.active[identity]++
+}
+
+func (c *clientCertConns) release(conn net.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.refused, conn)
+	identity, ok := c.admitted[conn]
+	if !ok {
+		return
+	}
+	delete(c.admitted, conn)
+	c.active[identity]--
+	if c.active[identity] <= 0 {
+		delete(c.active, identity)
+	}
+}
+
+func (c *clientCertConns) isRefused(conn net.Conn) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	identity, ok := c.refused[conn]
+	return identity, ok
+}
+
+type connCtxKey struct{}
+
+// withConn is an http.Server ConnContext callback, it lets the handlers tell which connection a request came on.
+func withConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connCtxKey{}, conn)
+}
+
+// The requests of a connection over the limit are refused and the connection closed after the response,
+// over HTTP/2 the "Connection: close" header makes the server send a GOAWAY.
+func limitConnsPerClientCert(conns *clientCertConns) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, _ := r.Context().Value(connCtxKey{}).(net.Conn)
+			if identity, refused := conns.isRefused(conn); refused {
+				w.Header().Set("Connection", "close")
+				WriteError(w, CodeRateLimited, fmt.Errorf("too many connections for client certificate '%s'", identity))
+				return
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// The identity is the certificate common name, falling back to the SPKI hash for certificates without one.
+func clientCertIdentity(peerCertificates []*x509.Certificate) (string, bool) {
+	if len(peerCertificates) == 0 {
+		return "", false
+	}
+
+	cert := peerCertificates[0]
+	if len(cert.Subject.CommonName) != 0 {
+		return cert.Subject.CommonName, true
+	}
+
+	spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "spki:" + hex.EncodeToString(spkiHash[:]), true
+}
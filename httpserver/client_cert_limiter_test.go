@@ -0,0 +1,129 @@
+package httpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLimitConnsPerClientCert(t *testing.T) {
+	caPool, issueClientCert := newTestClientCA(t)
+	cfg := newTestConfig(0).WithClientCAs(caPool).WithMaxConnsPerClientCert(1)
+	baseURL, cleanup := newTestServer(t, cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	defer cleanup()
+
+	alice, bob := issueClientCert("alice"), issueClientCert("bob")
+	url := baseURL + pingRoute + "?value=test+ping+value"
+
+	testCases := []struct {
+		name  string
+		http2 bool
+	}{
+		{"HTTP/1.1", false},
+		{"HTTP/2", true},
+	}
+
+	for _, testCase := range testCases {
+		newClient := func(cert tls.Certificate) *http.Client {
+			return &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{cert}},
+				ForceAttemptHTTP2: testCase.http2,
+			}}
+		}
+		firstAlice, secondAlice, firstBob := newClient(alice), newClient(alice), newClient(bob)
+
+		// The slot is held by the connection, its further requests are not limited.
+		for i := 0; i < 2; i++ {
+			if code := getStatusCode(t, firstAlice, url); code != http.StatusOK {
+				t.Fatalf("%v: request %v over the first connection answered '%v' is not as expected one '%v'", testCase.name, i, code, http.StatusOK)
+			}
+		}
+		if code := getStatusCode(t, secondAlice, url); code != http.StatusTooManyRequests {
+			t.Fatalf("%v: request over a second connection answered '%v' is not as expected one '%v'", testCase.name, code, http.StatusTooManyRequests)
+		}
+		if code := getStatusCode(t, firstBob, url); code != http.StatusOK {
+			t.Fatalf("%v: request of another identity answered '%v' is not as expected one '%v'", testCase.name, code, http.StatusOK)
+		}
+
+		// Closing the first connection frees its slot, the server notices it asynchronously.
+		firstAlice.CloseIdleConnections()
+		firstBob.CloseIdleConnections()
+		code := getStatusCode(t, secondAlice, url)
+		for start := time.Now(); code != http.StatusOK && time.Since(start) < 2*time.Second; time.Sleep(10 * time.Millisecond) {
+			code = getStatusCode(t, secondAlice, url)
+		}
+		if code != http.StatusOK {
+			t.Fatalf("%v: request after the first connection closed answered '%v' is not as expected one '%v'", testCase.name, code, http.StatusOK)
+		}
+		secondAlice.CloseIdleConnections()
+	}
+}
+
+func getStatusCode(t *testing.T, client *http.Client, url string) int {
+	res, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	return res.StatusCode
+}
+
+// newTestClientCA returns the pool of a fresh CA and a func issuing client certificates signed by it.
+func newTestClientCA(t *testing.T) (*x509.CertPool, func(commonName string) tls.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "test client CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+
+	issue := func(commonName string) tls.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: commonName},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+
+	return pool, issue
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressResponse gzips the responses of the clients accepting it. It wraps the writer itself, not
+// a response value, so the error responses of the handler and of the inner decorators are compressed too.
+func compressResponse() httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			gzipWriter := &gzipResponseWriter{ResponseWriter: w}
+			defer gzipWriter.Close()
+
+			handler.ServeHTTP(gzipWriter, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether gzip is listed with a non-zero quality, e.g. gzip;q=0.0 refuses it.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(encoding, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), "gzip") {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					parsed = 0
+				}
+				quality = parsed
+			}
+		}
+		return quality > 0
+	}
+
+	return false
+}
+
+// gzipResponseWriter decides in WriteHeader whether to compress, the 204 and 304 responses and the
+// ones already encoded by the handler stay untouched.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gzipWriter  *gzip.Writer
+	statusCode  int
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.statusCode = statusCode
+
+	if statusCode != http.StatusNoContent && statusCode != http.StatusNotModified && len(w.Header().Get("Content-Encoding")) == 0 {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gzipWriter = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.gzipWriter == nil {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.gzipWriter.Write(b)
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.gzipWriter != nil {
+		w.gzipWriter.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *gzipResponseWriter) Close() error {
+	if w.gzipWriter == nil {
+		return nil
+	}
+
+	return w.gzipWriter.Close()
+}
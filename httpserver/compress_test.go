@@ -0,0 +1,93 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressedErrorResponse(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithResponseCompression(), NewReqHandlersDependencies("test pong"))
+
+	req := httptest.NewRequest("POST", pingRoute, strings.NewReader(`{"value": ""}`))
+	req.Header.Set("Accept-Encoding", "gzip")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusBadRequest)
+	}
+	if res.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("returned Content-Encoding '%v' is not as expected one 'gzip'", res.Header().Get("Content-Encoding"))
+	}
+
+	gzipReader, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errRes := errorRes{}
+	err = json.NewDecoder(gzipReader).Decode(&errRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errRes.Error.Message) == 0 {
+		t.Fatal("returned error response is empty")
+	}
+}
+
+func TestUncompressedWithoutAcceptEncoding(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithResponseCompression(), NewReqHandlersDependencies("test pong"))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	if len(res.Header().Get("Content-Encoding")) != 0 {
+		t.Fatalf("returned Content-Encoding '%v' is not supposed to be set", res.Header().Get("Content-Encoding"))
+	}
+	if !strings.Contains(res.Body.String(), "test pong") {
+		t.Fatalf("returned body '%v' is not the plain ping response", res.Body.String())
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding string
+		expected       bool
+	}{
+		{"gzip", true},
+		{"deflate, GZIP;q=0.5", true},
+		{"gzip;q=0", false},
+		{"gzip;q=0.0", false},
+		{"gzip; q=0.000", false},
+		{"gzip;q=invalid", false},
+		{"br, deflate", false},
+		{"", false},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("POST", pingRoute, nil)
+		req.Header.Set("Accept-Encoding", testCase.acceptEncoding)
+		if accepted := acceptsGzip(req); accepted != testCase.expected {
+			t.Fatalf("'%v': returned acceptance '%v' is not as expected one '%v'", testCase.acceptEncoding, accepted, testCase.expected)
+		}
+	}
+}
+
+func TestUncompressedWhenGzipIsRefused(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithResponseCompression(), NewReqHandlersDependencies("test pong"))
+
+	req := httptest.NewRequest("POST", pingRoute, createPingReq())
+	req.Header.Set("Accept-Encoding", "gzip;q=0.0, identity")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if len(res.Header().Get("Content-Encoding")) != 0 {
+		t.Fatalf("returned Content-Encoding '%v' is not supposed to be set", res.Header().Get("Content-Encoding"))
+	}
+	if !strings.Contains(res.Body.String(), "test pong") {
+		t.Fatalf("returned body '%v' is not the plain ping response", res.Body.String())
+	}
+}
@@ -3,16 +3,593 @@
 // Use of this source code is governed by an Apache License that can be found in the LICENSE file.
 package httpserver
 
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gophersland/citizen/storage"
+)
+
 type Config struct {
 	port                          int
+	unixSocketPath                string
+	certificatePemFilePath        string
+	certificatePemPrivKeyFilePath string
+	certificate                   *tls.Certificate
+	tlsConfig                     *tls.Config
+	autocertHosts                 []string
+	autocertCacheDir              string
+	clientCAs                     *x509.CertPool
+	maxConnsPerClientCert         int
+	securityHeaders               bool
+	contentSecurityPolicy         string
+	hstsMaxAge                    time.Duration
+	hstsIncludeSubdomains         bool
+	hstsPreload                   bool
+	redactedQueryParams           []string
+	routeTimeouts                 map[string]time.Duration
+	extensionNegotiation          bool
+	maxDecompressionRatio         int64
+	misdirectedRequestCheck       bool
+	fairQueueCapacity             int
+	fairQueueWeights              map[string]int
+	latencyBuckets                []float64
+	maxHeaderBytes                int
+	methodOverride                bool
+	longLivedDrainTimeout         time.Duration
+	drainTimeout                  time.Duration
+	corsAllowedOrigins            []string
+	corsMaxAge                    time.Duration
+	maxRedirects                  int
+	responseCompression           bool
+	plaintext                     bool
+	disableHTTP2                  bool
+	h2c                           bool
+	authLockoutMaxFailures        int
+	authLockoutCooldown           time.Duration
+	disableKeepAlives             bool
+	connState                     func(net.Conn, http.ConnState)
+	trustedProxies                []string
+	serverTiming                  bool
+	lenientJSON                   bool
+	decoratorParams               DecoratorParams
+	adminToken                    string
+	additionalKeyPairs            []keyPairFiles
+	jwePrivateKey                 crypto.PrivateKey
+	jsonCharset                   string
+	prettyJSON                    bool
+	adminClientCNs                []string
+	certReloadOnSIGHUP            bool
+	allowedProtocolVersions       []string
+	lameDuckDuration              time.Duration
+	accessLog                     io.Writer
+	strictContentType             bool
+	listener                      net.Listener
+	pprof                         bool
+	maxConns                      int
+	disableRequestLog             bool
+	metricsRoute                  string
+	adminAddr                     string
+	adminListener                 net.Listener
+	serverTimeouts                ServerTimeouts
+	storage                       storage.Config
+}
+
+// ServerTimeouts bound how long a connection may take to send its request and receive the response, so
+// slow clients, e.g. a slowloris attack, can't hold the connections forever. A zero duration disables one.
+type ServerTimeouts struct {
+	// ReadHeader bounds the reading of the request headers.
+	ReadHeader time.Duration
+	// Read bounds the reading of the whole request, body included.
+	Read time.Duration
+	// Write bounds the writing of the response, from the end of the request headers. The long-lived routes
+	// are exempted.
+	Write time.Duration
+	// Idle bounds how long a keep-alive connection waits for the next request.
+	Idle time.Duration
+}
+
+var defaultServerTimeouts = ServerTimeouts{
+	ReadHeader: 10 * time.Second,
+	Read:       30 * time.Second,
+	Write:      60 * time.Second,
+	Idle:       120 * time.Second,
+}
+
+type keyPairFiles struct {
 	certificatePemFilePath        string
 	certificatePemPrivKeyFilePath string
 }
 
 func NewConfig(port int, certificatePemFilePath string, certificatePemPrivKeyFilePath string) Config {
 	return Config{
-		port,
-		certificatePemFilePath,
-		certificatePemPrivKeyFilePath,
+		port:                          port,
+		certificatePemFilePath:        certificatePemFilePath,
+		certificatePemPrivKeyFilePath: certificatePemPrivKeyFilePath,
+		redactedQueryParams:           defaultRedactedQueryParams,
+		longLivedDrainTimeout:         defaultLongLivedDrainTimeout,
+		drainTimeout:                  defaultDrainTimeout,
+		metricsRoute:                  metricsRoute,
+		serverTimeouts:                defaultServerTimeouts,
+	}
+}
+
+// NewConfigFromPEM is NewConfig for certificates kept in memory, e.g. fetched from a secret store.
+func NewConfigFromPEM(port int, certificatePem []byte, certificatePemPrivKey []byte) (Config, error) {
+	cert, err := tls.X509KeyPair(certificatePem, certificatePemPrivKey)
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to parse PEM encoded TLS key pair. %s", err.Error())
+	}
+
+	cfg := NewConfig(port, "", "")
+	cfg.certificate = &cert
+
+	return cfg, nil
+}
+
+// WithUnixSocket listens on the given unix domain socket instead of the TCP port.
+func (cfg Config) WithUnixSocket(socketPath string) Config {
+	cfg.unixSocketPath = socketPath
+	return cfg
+}
+
+// WithTLSConfig sets the base TLS settings like MinVersion and CipherSuites, MinVersion defaults to TLS 1.2.
+func (cfg Config) WithTLSConfig(tlsConfig *tls.Config) Config {
+	cfg.tlsConfig = tlsConfig
+	return cfg
+}
+
+// WithAutocert provisions the certificates of the given hosts from Let's Encrypt, the ACME HTTP-01 challenge is served
+// on port 80. It replaces the certificate files, leave their paths empty. An empty cacheDir keeps the certificates in memory.
+func (cfg Config) WithAutocert(cacheDir string, hosts ...string) Config {
+	cfg.autocertCacheDir = cacheDir
+	cfg.autocertHosts = hosts
+	return cfg
+}
+
+// WithClientCAs turns on mTLS, every client must present a certificate signed by one of the given CAs.
+func (cfg Config) WithClientCAs(clientCAs *x509.CertPool) Config {
+	cfg.clientCAs = clientCAs
+	return cfg
+}
+
+// WithMaxConnsPerClientCert caps how many connections a single client certificate identity can keep open, the
+// requests on the extra ones are answered with a 429 and the connection closed.
+func (cfg Config) WithMaxConnsPerClientCert(max int) Config {
+	cfg.maxConnsPerClientCert = max
+	return cfg
+}
+
+// WithSecurityHeaders adds the standard hardening headers to every response, an empty contentSecurityPolicy omits the CSP.
+func (cfg Config) WithSecurityHeaders(contentSecurityPolicy string) Config {
+	cfg.securityHeaders = true
+	cfg.contentSecurityPolicy = contentSecurityPolicy
+	return cfg
+}
+
+// WithHSTS sends Strict-Transport-Security on every TLS response.
+func (cfg Config) WithHSTS(maxAge time.Duration, includeSubdomains bool, preload bool) Config {
+	cfg.hstsMaxAge = maxAge
+	cfg.hstsIncludeSubdomains = includeSubdomains
+	cfg.hstsPreload = preload
+	return cfg
+}
+
+// WithRedactedQueryParams replaces the list of query parameters whose values never reach logs, metrics or traces.
+func (cfg Config) WithRedactedQueryParams(params ...string) Config {
+	cfg.redactedQueryParams = params
+	return cfg
+}
+
+// WithRouteTimeout answers with a 503 and cancels the request context when the route handler takes longer than d.
+func (cfg Config) WithRouteTimeout(route string, d time.Duration) Config {
+	routeTimeouts := make(map[string]time.Duration, len(cfg.routeTimeouts)+1)
+	for r, timeout := range cfg.routeTimeouts {
+		routeTimeouts[r] = timeout
+	}
+	routeTimeouts[route] = d
+	cfg.routeTimeouts = routeTimeouts
+	return cfg
+}
+
+// WithExtensionNegotiation lets clients pick the response format with a path extension, e.g. /ping.xml.
+func (cfg Config) WithExtensionNegotiation() Config {
+	cfg.extensionNegotiation = true
+	return cfg
+}
+
+// WithRequestDecompression accepts gzip request bodies as long as they don't inflate more than maxRatio times.
+func (cfg Config) WithRequestDecompression(maxRatio int64) Config {
+	cfg.maxDecompressionRatio = maxRatio
+	return cfg
+}
+
+// WithMisdirectedRequestCheck answers 421 to requests for a host the served certificate is not valid for.
+func (cfg Config) WithMisdirectedRequestCheck() Config {
+	cfg.misdirectedRequestCheck = true
+	return cfg
+}
+
+// WithFairQueue caps the concurrent requests to capacity, shared between the tenants proportionally to their weight.
+// The tenant is the verified client certificate identity, otherwise the X-Tenant-ID header set by a trusted proxy,
+// see WithTrustedProxies. The unweighted tenants share the default tenant "", of weight 1 unless weighted.
+func (cfg Config) WithFairQueue(capacity int, weights map[string]int) Config {
+	cfg.fairQueueCapacity = capacity
+	cfg.fairQueueWeights = weights
+	return cfg
+}
+
+// WithMetricsRoute serves the Prometheus metrics on path instead of /metrics, an empty path disables the endpoint.
+func (cfg Config) WithMetricsRoute(path string) Config {
+	cfg.metricsRoute = path
+	return cfg
+}
+
+// WithLatencyBuckets sets the upper bounds, in seconds, of the http_request_duration_seconds histogram buckets.
+func (cfg Config) WithLatencyBuckets(buckets []float64) Config {
+	cfg.latencyBuckets = buckets
+	return cfg
+}
+
+// WithMaxHeaderBytes caps the size of the request headers, http.DefaultMaxHeaderBytes by default.
+func (cfg Config) WithMaxHeaderBytes(maxHeaderBytes int) Config {
+	cfg.maxHeaderBytes = maxHeaderBytes
+	return cfg
+}
+
+// WithMethodOverride lets POST requests be turned into PUT, PATCH or DELETE with the X-HTTP-Method-Override header.
+func (cfg Config) WithMethodOverride() Config {
+	cfg.methodOverride = true
+	return cfg
+}
+
+// WithLongLivedDrainTimeout bounds how long the shutdown waits for the long-lived routes to close their streams.
+func (cfg Config) WithLongLivedDrainTimeout(timeout time.Duration) Config {
+	cfg.longLivedDrainTimeout = timeout
+	return cfg
+}
+
+// WithDrainTimeout bounds how long the shutdown waits for the in-flight requests before closing their connections.
+func (cfg Config) WithDrainTimeout(timeout time.Duration) Config {
+	cfg.drainTimeout = timeout
+	return cfg
+}
+
+// WithStorage selects where NewCitizenRegistry keeps the citizens, in memory by default.
+func (cfg Config) WithStorage(storageCfg storage.Config) Config {
+	cfg.storage = storageCfg
+	return cfg
+}
+
+// WithServerTimeouts replaces the connection timeouts, by default 10s to read the headers, 30s to read
+// the request, 60s to write the response and 120s of keep-alive idleness.
+func (cfg Config) WithServerTimeouts(timeouts ServerTimeouts) Config {
+	cfg.serverTimeouts = timeouts
+	return cfg
+}
+
+// WithCORS answers the CORS requests of the given origins, "*" allowing any. A positive maxAge sets
+// Access-Control-Max-Age on the preflight responses.
+func (cfg Config) WithCORS(maxAge time.Duration, allowedOrigins ...string) Config {
+	cfg.corsAllowedOrigins = allowedOrigins
+	cfg.corsMaxAge = maxAge
+	return cfg
+}
+
+// WithMaxRedirects answers with 400 the requests caught in a redirect loop or having followed more
+// than maxRedirects of the server redirects.
+func (cfg Config) WithMaxRedirects(maxRedirects int) Config {
+	cfg.maxRedirects = maxRedirects
+	return cfg
+}
+
+// WithResponseCompression gzips the responses, errors included, of the clients accepting it.
+func (cfg Config) WithResponseCompression() Config {
+	cfg.responseCompression = true
+	return cfg
+}
+
+// WithPlaintext serves cleartext HTTP, e.g. behind a TLS terminating proxy, no certificate is needed then.
+func (cfg Config) WithPlaintext() Config {
+	cfg.plaintext = true
+	return cfg
+}
+
+// WithHTTP2 toggles HTTP/2 over TLS, enabled by default.
+func (cfg Config) WithHTTP2(enabled bool) Config {
+	cfg.disableHTTP2 = !enabled
+	return cfg
+}
+
+// WithH2C toggles cleartext HTTP/2 (h2c) on a plaintext server, disabled by default.
+func (cfg Config) WithH2C(enabled bool) Config {
+	cfg.h2c = enabled
+	return cfg
+}
+
+// WithAuthLockout answers with 429, for the cooldown, the clients failing to authenticate maxFailures times.
+func (cfg Config) WithAuthLockout(maxFailures int, cooldown time.Duration) Config {
+	cfg.authLockoutMaxFailures = maxFailures
+	cfg.authLockoutCooldown = cooldown
+	return cfg
+}
+
+// WithKeepAlives toggles the HTTP keep-alives, enabled by default. Disabled, every request gets a fresh connection.
+func (cfg Config) WithKeepAlives(enabled bool) Config {
+	cfg.disableKeepAlives = !enabled
+	return cfg
+}
+
+// WithConnState is called on every connection state change, e.g. to count the new, idle and closed connections.
+func (cfg Config) WithConnState(callback func(net.Conn, http.ConnState)) Config {
+	cfg.connState = callback
+	return cfg
+}
+
+// WithTrustedProxies takes the client IP out of X-Forwarded-For or X-Real-IP for the requests coming
+// from the given proxies, CIDRs or single IPs.
+func (cfg Config) WithTrustedProxies(proxies ...string) Config {
+	cfg.trustedProxies = proxies
+	return cfg
+}
+
+// WithServerTiming exposes the auth, decode and handler phase durations in the Server-Timing header.
+func (cfg Config) WithServerTiming() Config {
+	cfg.serverTiming = true
+	return cfg
+}
+
+// WithPort replaces the NewConfig TCP port.
+func (cfg Config) WithPort(port int) Config {
+	cfg.port = port
+	return cfg
+}
+
+// WithKeyPair replaces the NewConfig certificate and private key files.
+func (cfg Config) WithKeyPair(certificatePemFilePath string, certificatePemPrivKeyFilePath string) Config {
+	cfg.certificatePemFilePath = certificatePemFilePath
+	cfg.certificatePemPrivKeyFilePath = certificatePemPrivKeyFilePath
+	return cfg
+}
+
+// WithAdditionalKeyPair serves one more certificate, picked when the client SNI matches it, e.g. for
+// another domain hosted by the same server. The NewConfig certificate stays the default one.
+func (cfg Config) WithAdditionalKeyPair(certificatePemFilePath string, certificatePemPrivKeyFilePath string) Config {
+	cfg.additionalKeyPairs = append(append([]keyPairFiles{}, cfg.additionalKeyPairs...), keyPairFiles{certificatePemFilePath, certificatePemPrivKeyFilePath})
+	return cfg
+}
+
+// WithJWEPrivateKey decrypts the JWE request bodies of the EncryptedPayload routes, RSA or ECDSA keys are supported.
+func (cfg Config) WithJWEPrivateKey(privateKey crypto.PrivateKey) Config {
+	cfg.jwePrivateKey = privateKey
+	return cfg
+}
+
+// WithJSONCharset appends the charset parameter, e.g. utf-8, to the JSON Content-Type.
+func (cfg Config) WithJSONCharset(charset string) Config {
+	cfg.jsonCharset = charset
+	return cfg
+}
+
+// WithPrettyJSON indents the JSON responses, handy while debugging.
+func (cfg Config) WithPrettyJSON() Config {
+	cfg.prettyJSON = true
+	return cfg
+}
+
+// WithAdminClientCNs lets the callers presenting a verified client certificate with one of the given
+// common names through the admin auth, next to the admin token.
+func (cfg Config) WithAdminClientCNs(commonNames ...string) Config {
+	cfg.adminClientCNs = commonNames
+	return cfg
+}
+
+func (cfg Config) hasAdminAuth() bool {
+	return len(cfg.adminToken) != 0 || len(cfg.adminClientCNs) != 0
+}
+
+// WithCertReloadOnSIGHUP loads the certificate files again on SIGHUP, rotating them without a restart.
+func (cfg Config) WithCertReloadOnSIGHUP() Config {
+	cfg.certReloadOnSIGHUP = true
+	return cfg
+}
+
+// keyPairFiles lists the NewConfig key pair first, then the additional ones.
+func (cfg Config) keyPairFiles() []keyPairFiles {
+	return append([]keyPairFiles{{cfg.certificatePemFilePath, cfg.certificatePemPrivKeyFilePath}}, cfg.additionalKeyPairs...)
+}
+
+// WithAllowedProtocolVersions rejects the requests using other protocol versions than the given ones, e.g. HTTP/1.1 or HTTP/2.0.
+func (cfg Config) WithAllowedProtocolVersions(versions ...string) Config {
+	cfg.allowedProtocolVersions = versions
+	return cfg
+}
+
+// WithLameDuckDuration keeps serving for the given duration after the shutdown signal while /ready reports
+// not ready, so the load balancer stops routing to the server before its requests are drained.
+func (cfg Config) WithLameDuckDuration(duration time.Duration) Config {
+	cfg.lameDuckDuration = duration
+	return cfg
+}
+
+// WithAccessLog writes a Combined Log Format line per request to out, e.g. for an nginx style log ingestion.
+// It is independent from the Logger.
+func (cfg Config) WithAccessLog(out io.Writer) Config {
+	cfg.accessLog = out
+	return cfg
+}
+
+// WithStrictContentType rejects the request bodies not sent as application/json with a 415.
+func (cfg Config) WithStrictContentType() Config {
+	cfg.strictContentType = true
+	return cfg
+}
+
+// WithListener serves on an already bound listener, e.g. one on an ephemeral port, instead of binding the port.
+// The server closes it on shutdown.
+func (cfg Config) WithListener(listener net.Listener) Config {
+	cfg.listener = listener
+	return cfg
+}
+
+// WithPprof exposes the net/http/pprof handlers under /debug/pprof/ behind the admin auth, which it requires
+// unless they are served on the admin listener of WithAdminAddr.
+func (cfg Config) WithPprof() Config {
+	cfg.pprof = true
+	return cfg
+}
+
+// WithAdminAddr moves the metrics, admin and pprof routes to a separate cleartext listener on addr, e.g.
+// 127.0.0.1:9094, so they are never exposed next to the public routes. The pprof endpoints don't require
+// the admin auth there, addr must be a loopback one unless WithAdminClientCNs is set, the listener then
+// serves over TLS and requires the admin auth.
+func (cfg Config) WithAdminAddr(addr string) Config {
+	cfg.adminAddr = addr
+	return cfg
+}
+
+func (cfg Config) hasAdminListener() bool {
+	return len(cfg.adminAddr) != 0 || cfg.adminListener != nil
+}
+
+// WithMaxConns caps the simultaneously accepted connections, the extra ones wait until one closes. Zero is unlimited.
+func (cfg Config) WithMaxConns(maxConns int) Config {
+	cfg.maxConns = maxConns
+	return cfg
+}
+
+// WithRequestLog toggles the Logger line written for every request with its method, path, remote address,
+// status, size, duration and request ID, enabled by default.
+func (cfg Config) WithRequestLog(enabled bool) Config {
+	cfg.disableRequestLog = !enabled
+	return cfg
+}
+
+// WithLenientJSON accepts request bodies with unknown fields, by default they are rejected.
+func (cfg Config) WithLenientJSON() Config {
+	cfg.lenientJSON = true
+	return cfg
+}
+
+// WithDecoratorParams sets the initial rate limit and body size cap, both can be changed later through the admin endpoint.
+func (cfg Config) WithDecoratorParams(params DecoratorParams) Config {
+	cfg.decoratorParams = params
+	return cfg
+}
+
+// WithAdminToken enables the admin endpoints, and guards /metrics, with the given bearer token.
+func (cfg Config) WithAdminToken(token string) Config {
+	cfg.adminToken = token
+	return cfg
+}
+
+// Validate catches configuration mistakes before the server binds, instead of a cryptic TLS error later.
+func (cfg Config) Validate() error {
+	if len(cfg.unixSocketPath) == 0 && cfg.listener == nil && (cfg.port < 1 || cfg.port > 65535) {
+		return fmt.Errorf("port %d is out of the 1-65535 range", cfg.port)
+	}
+
+	_, err := parseTrustedProxies(cfg.trustedProxies)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseProtocolVersions(cfg.allowedProtocolVersions)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.serverTimeouts.validate()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.storage.Validate()
+	if err != nil {
+		return err
+	}
+
+	if cfg.pprof && !cfg.hasAdminAuth() && !cfg.hasAdminListener() {
+		return fmt.Errorf("pprof endpoints require the admin token, the admin client CNs or the admin listener")
+	}
+
+	if len(cfg.adminAddr) != 0 && len(cfg.adminClientCNs) == 0 && !isLoopbackAddr(cfg.adminAddr) {
+		return fmt.Errorf("admin address %s must be a loopback one unless the admin client CNs are configured", cfg.adminAddr)
+	}
+
+	if cfg.plaintext {
+		if len(cfg.autocertHosts) != 0 || cfg.clientCAs != nil || cfg.misdirectedRequestCheck {
+			return fmt.Errorf("autocert, client certificates and the misdirected request check require TLS, they can't be used with a plaintext server")
+		}
+		if cfg.certReloadOnSIGHUP || len(cfg.additionalKeyPairs) != 0 || len(cfg.adminClientCNs) != 0 {
+			return fmt.Errorf("certificate reloads, additional key pairs and admin client CNs require TLS, they can't be used with a plaintext server")
+		}
+		return cfg.decoratorParams.validate()
+	}
+	if cfg.h2c {
+		return fmt.Errorf("h2c is cleartext HTTP/2, it requires a plaintext server")
 	}
+	if len(cfg.adminClientCNs) != 0 && cfg.clientCAs == nil {
+		return fmt.Errorf("admin client CNs are only verified against the client CAs, configure them too")
+	}
+	if cfg.certReloadOnSIGHUP && (len(cfg.autocertHosts) != 0 || cfg.certificate != nil) {
+		return fmt.Errorf("only certificates loaded from files can be reloaded on SIGHUP")
+	}
+
+	hasStaticCertFiles := len(cfg.certificatePemFilePath) != 0 || len(cfg.certificatePemPrivKeyFilePath) != 0 || len(cfg.additionalKeyPairs) != 0
+	if len(cfg.autocertHosts) != 0 && (hasStaticCertFiles || cfg.certificate != nil) {
+		return fmt.Errorf("autocert and static certificates are mutually exclusive, configure only one of them")
+	}
+
+	if len(cfg.autocertHosts) == 0 && cfg.certificate == nil {
+		err := validateKeyPairFiles(cfg.certificatePemFilePath, cfg.certificatePemPrivKeyFilePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, keyPair := range cfg.additionalKeyPairs {
+		err := validateKeyPairFiles(keyPair.certificatePemFilePath, keyPair.certificatePemPrivKeyFilePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cfg.decoratorParams.validate()
+}
+
+func (timeouts ServerTimeouts) validate() error {
+	if timeouts.ReadHeader < 0 || timeouts.Read < 0 || timeouts.Write < 0 || timeouts.Idle < 0 {
+		return fmt.Errorf("server timeouts must not be negative")
+	}
+
+	return nil
+}
+
+func validateKeyPairFiles(certificatePemFilePath string, certificatePemPrivKeyFilePath string) error {
+	for _, path := range []string{certificatePemFilePath, certificatePemPrivKeyFilePath} {
+		if len(path) == 0 {
+			return fmt.Errorf("TLS certificate and private key file paths are required")
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("unable to read TLS file. %s", err.Error())
+		}
+		file.Close()
+	}
+
+	_, err := tls.LoadX509KeyPair(certificatePemFilePath, certificatePemPrivKeyFilePath)
+	if err != nil {
+		return fmt.Errorf("TLS certificate and private key don't form a valid X.509 key pair. %s", err.Error())
+	}
+
+	return nil
 }
@@ -0,0 +1,204 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gophersland/citizen/storage"
+)
+
+const (
+	defaultPort = 9093
+
+	envPort         = "CITIZEN_PORT"
+	envUnixSocket   = "CITIZEN_UNIX_SOCKET"
+	envTLSCert      = "CITIZEN_TLS_CERT"
+	envTLSKey       = "CITIZEN_TLS_KEY"
+	envPlaintext    = "CITIZEN_PLAINTEXT"
+	envDrainTimeout = "CITIZEN_DRAIN_TIMEOUT"
+	envMaxConns     = "CITIZEN_MAX_CONNS"
+	envAdminToken   = "CITIZEN_ADMIN_TOKEN"
+	envAdminAddr    = "CITIZEN_ADMIN_ADDR"
+	envPprof        = "CITIZEN_PPROF"
+
+	envReadHeaderTimeout = "CITIZEN_READ_HEADER_TIMEOUT"
+	envReadTimeout       = "CITIZEN_READ_TIMEOUT"
+	envWriteTimeout      = "CITIZEN_WRITE_TIMEOUT"
+	envIdleTimeout       = "CITIZEN_IDLE_TIMEOUT"
+
+	envStorage         = "CITIZEN_STORAGE"
+	envStoragePath     = "CITIZEN_STORAGE_PATH"
+	envDataDir         = "CITIZEN_DATA_DIR"
+	envStorageDSN      = "CITIZEN_STORAGE_DSN"
+	envStorageMaxConns = "CITIZEN_STORAGE_MAX_CONNS"
+	envAutoMigrate     = "CITIZEN_STORAGE_AUTO_MIGRATE"
+)
+
+// NewConfigFromEnv builds the Config out of the CITIZEN_* environment variables:
+//
+//	CITIZEN_PORT           TCP port, 9093 by default
+//	CITIZEN_UNIX_SOCKET    unix domain socket to listen on instead of the port
+//	CITIZEN_TLS_CERT       PEM certificate file, required unless CITIZEN_PLAINTEXT is true
+//	CITIZEN_TLS_KEY        PEM private key file, required unless CITIZEN_PLAINTEXT is true
+//	CITIZEN_PLAINTEXT      serves cleartext HTTP, false by default
+//	CITIZEN_DRAIN_TIMEOUT  shutdown drain timeout as a Go duration, e.g. 30s, 0 closes the connections right away
+//	CITIZEN_MAX_CONNS      cap of the simultaneously accepted connections, unlimited by default
+//	CITIZEN_ADMIN_TOKEN    bearer token enabling the admin endpoints
+//	CITIZEN_ADMIN_ADDR     separate listener of the metrics, admin and pprof routes, e.g. 127.0.0.1:9094
+//	CITIZEN_PPROF          exposes the pprof endpoints, false by default
+//	CITIZEN_STORAGE        citizen storage backend, memory (default), file, bolt or postgres
+//	CITIZEN_STORAGE_PATH   JSON file of the file storage backend, imported into an empty bolt one
+//	CITIZEN_DATA_DIR       directory of the bolt storage backend databases
+//	CITIZEN_STORAGE_DSN    database of the postgres storage backend, e.g. postgres://citizen@db:5432/citizen
+//	CITIZEN_STORAGE_MAX_CONNS  connection pool size of the postgres storage backend, 10 by default
+//	CITIZEN_STORAGE_AUTO_MIGRATE  applies the postgres schema migrations at startup, false by default
+//
+//	CITIZEN_READ_HEADER_TIMEOUT, CITIZEN_READ_TIMEOUT, CITIZEN_WRITE_TIMEOUT and CITIZEN_IDLE_TIMEOUT
+//	override the connection timeouts as Go durations, 0 disables one
+//
+// The overrides, e.g. of command line flags, are applied to the Config before the TLS key pair is required
+// and the Config validated. The returned error lists every missing or invalid variable at once.
+func NewConfigFromEnv(overrides ...func(Config) Config) (Config, error) {
+	env := envReader{}
+
+	port := env.int(envPort, defaultPort)
+	plaintext := env.bool(envPlaintext, false)
+
+	cfg := NewConfig(port, env.string(envTLSCert), env.string(envTLSKey)).
+		WithDrainTimeout(env.duration(envDrainTimeout, defaultDrainTimeout)).
+		WithMaxConns(env.int(envMaxConns, 0)).
+		WithServerTimeouts(ServerTimeouts{
+			ReadHeader: env.duration(envReadHeaderTimeout, defaultServerTimeouts.ReadHeader),
+			Read:       env.duration(envReadTimeout, defaultServerTimeouts.Read),
+			Write:      env.duration(envWriteTimeout, defaultServerTimeouts.Write),
+			Idle:       env.duration(envIdleTimeout, defaultServerTimeouts.Idle),
+		})
+	if plaintext {
+		cfg = cfg.WithPlaintext()
+	}
+	if socketPath := env.string(envUnixSocket); len(socketPath) != 0 {
+		cfg = cfg.WithUnixSocket(socketPath)
+	}
+	if token := env.string(envAdminToken); len(token) != 0 {
+		cfg = cfg.WithAdminToken(token)
+	}
+	if adminAddr := env.string(envAdminAddr); len(adminAddr) != 0 {
+		cfg = cfg.WithAdminAddr(adminAddr)
+	}
+	if env.bool(envPprof, false) {
+		cfg = cfg.WithPprof()
+	}
+	cfg = cfg.WithStorage(env.storageConfig())
+	for _, override := range overrides {
+		cfg = override(cfg)
+	}
+	if !cfg.plaintext {
+		env.require(envTLSCert, cfg.certificatePemFilePath)
+		env.require(envTLSKey, cfg.certificatePemPrivKeyFilePath)
+	}
+
+	if len(env.errs) != 0 {
+		return Config{}, fmt.Errorf("invalid environment configuration. %w", errors.Join(env.errs...))
+	}
+
+	err := cfg.Validate()
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid environment configuration. %w", err)
+	}
+
+	return cfg, nil
+}
+
+// NewStorageConfigFromEnv reads the CITIZEN_STORAGE* and CITIZEN_DATA_DIR variables of NewConfigFromEnv
+// alone, e.g. for the tools migrating the storage on a host without the server certificate.
+func NewStorageConfigFromEnv() (storage.Config, error) {
+	env := envReader{}
+	storageCfg := env.storageConfig()
+	if len(env.errs) != 0 {
+		return storage.Config{}, fmt.Errorf("invalid environment configuration. %w", errors.Join(env.errs...))
+	}
+
+	err := storageCfg.Validate()
+	if err != nil {
+		return storage.Config{}, fmt.Errorf("invalid environment configuration. %w", err)
+	}
+
+	return storageCfg, nil
+}
+
+// envReader collects the parsing errors so they are all reported together.
+type envReader struct {
+	errs []error
+}
+
+func (env *envReader) string(name string) string {
+	return os.Getenv(name)
+}
+
+func (env *envReader) storageConfig() storage.Config {
+	return storage.Config{
+		Backend:     env.string(envStorage),
+		Path:        env.string(envStoragePath),
+		DataDir:     env.string(envDataDir),
+		DSN:         env.string(envStorageDSN),
+		MaxConns:    env.int(envStorageMaxConns, 0),
+		AutoMigrate: env.bool(envAutoMigrate, false),
+	}
+}
+
+func (env *envReader) require(name string, value string) {
+	if len(value) == 0 {
+		env.errs = append(env.errs, fmt.Errorf("%s is required", name))
+	}
+}
+
+func (env *envReader) int(name string, fallback int) int {
+	value, ok := os.LookupEnv(name)
+	if !ok || len(value) == 0 {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		env.errs = append(env.errs, fmt.Errorf("%s must be an integer, got '%s'", name, value))
+		return fallback
+	}
+
+	return parsed
+}
+
+func (env *envReader) bool(name string, fallback bool) bool {
+	value, ok := os.LookupEnv(name)
+	if !ok || len(value) == 0 {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		env.errs = append(env.errs, fmt.Errorf("%s must be a boolean, got '%s'", name, value))
+		return fallback
+	}
+
+	return parsed
+}
+
+func (env *envReader) duration(name string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(name)
+	if !ok || len(value) == 0 {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		env.errs = append(env.errs, fmt.Errorf("%s must be a positive duration like 30s, got '%s'", name, value))
+		return fallback
+	}
+
+	return parsed
+}
@@ -0,0 +1,121 @@
+package httpserver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophersland/citizen/storage"
+)
+
+func TestNewConfigFromEnv(t *testing.T) {
+	t.Setenv(envPort, "9094")
+	t.Setenv(envTLSCert, newTestConfig(9094).certificatePemFilePath)
+	t.Setenv(envTLSKey, newTestConfig(9094).certificatePemPrivKeyFilePath)
+	t.Setenv(envDrainTimeout, "30s")
+
+	cfg, err := NewConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.port != 9094 {
+		t.Fatalf("configured port '%v' is not as expected one '%v'", cfg.port, 9094)
+	}
+	if cfg.drainTimeout != 30*time.Second {
+		t.Fatalf("configured drain timeout '%v' is not as expected one '%v'", cfg.drainTimeout, 30*time.Second)
+	}
+	err = cfg.Validate()
+	if err != nil {
+		t.Fatalf("configuration built from the environment is supposed to be valid. %v", err)
+	}
+}
+
+func TestNewConfigFromEnvDefaults(t *testing.T) {
+	t.Setenv(envPlaintext, "true")
+
+	cfg, err := NewConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.port != defaultPort || !cfg.plaintext || cfg.drainTimeout != defaultDrainTimeout {
+		t.Fatalf("configuration '%+v' does not hold the defaults of a plaintext server", cfg)
+	}
+}
+
+func TestNewConfigFromEnvListsEveryError(t *testing.T) {
+	t.Setenv(envPort, "https")
+	t.Setenv(envDrainTimeout, "soon")
+
+	_, err := NewConfigFromEnv()
+	if err == nil {
+		t.Fatal("building the configuration out of invalid variables is supposed to fail")
+	}
+
+	for _, name := range []string{envPort, envDrainTimeout, envTLSCert, envTLSKey} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("returned error '%v' does not mention '%v'", err, name)
+		}
+	}
+}
+
+func TestNewConfigFromEnvZeroDisablesTimeouts(t *testing.T) {
+	t.Setenv(envPlaintext, "true")
+	t.Setenv(envReadTimeout, "0")
+
+	cfg, err := NewConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.serverTimeouts.Read != 0 || cfg.serverTimeouts.Write != defaultServerTimeouts.Write {
+		t.Fatalf("server timeouts '%+v' don't hold the disabled read timeout and the default other ones", cfg.serverTimeouts)
+	}
+}
+
+func TestNewConfigFromEnvValidates(t *testing.T) {
+	t.Setenv(envPlaintext, "true")
+	t.Setenv(envPprof, "true")
+
+	_, err := NewConfigFromEnv()
+	if err == nil || !strings.Contains(err.Error(), "pprof") {
+		t.Fatalf("returned error '%v' does not report the invalid configuration", err)
+	}
+}
+
+func TestNewConfigFromEnvOverrides(t *testing.T) {
+	t.Setenv(envPort, "9095")
+	certPath, keyPath := writeSelfSignedKeyPair(t, t.TempDir(), "localhost")
+
+	cfg, err := NewConfigFromEnv(func(cfg Config) Config {
+		return cfg.WithPort(9096).WithKeyPair(certPath, keyPath)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.port != 9096 || cfg.certificatePemFilePath != certPath || cfg.certificatePemPrivKeyFilePath != keyPath {
+		t.Fatalf("configuration '%+v' does not hold the overridden port and key pair", cfg)
+	}
+}
+
+func TestNewStorageConfigFromEnvWithoutCertificate(t *testing.T) {
+	t.Setenv(envStorage, storage.BackendBolt)
+	t.Setenv(envDataDir, t.TempDir())
+
+	storageCfg, err := NewStorageConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if storageCfg.Backend != storage.BackendBolt || len(storageCfg.DataDir) == 0 {
+		t.Fatalf("storage configuration '%+v' does not match the environment", storageCfg)
+	}
+
+	t.Setenv(envStorageMaxConns, "many")
+	_, err = NewStorageConfigFromEnv()
+	if err == nil || !strings.Contains(err.Error(), envStorageMaxConns) {
+		t.Fatalf("returned error '%v' does not mention '%v'", err, envStorageMaxConns)
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gophersland/citizen/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the schema of the LoadConfig files, the same keys are used in YAML and TOML.
+type fileConfig struct {
+	Port       int    `yaml:"port" toml:"port"`
+	UnixSocket string `yaml:"unix_socket" toml:"unix_socket"`
+	TLS        struct {
+		Cert      string `yaml:"cert" toml:"cert"`
+		Key       string `yaml:"key" toml:"key"`
+		Plaintext bool   `yaml:"plaintext" toml:"plaintext"`
+		HTTP2     *bool  `yaml:"http2" toml:"http2"`
+	} `yaml:"tls" toml:"tls"`
+	Timeouts struct {
+		Drain          *fileDuration `yaml:"drain" toml:"drain"`
+		LongLivedDrain *fileDuration `yaml:"long_lived_drain" toml:"long_lived_drain"`
+		LameDuck       *fileDuration `yaml:"lame_duck" toml:"lame_duck"`
+		ReadHeader     *fileDuration `yaml:"read_header" toml:"read_header"`
+		Read           *fileDuration `yaml:"read" toml:"read"`
+		Write          *fileDuration `yaml:"write" toml:"write"`
+		Idle           *fileDuration `yaml:"idle" toml:"idle"`
+	} `yaml:"timeouts" toml:"timeouts"`
+	MaxConns       int    `yaml:"max_conns" toml:"max_conns"`
+	MaxHeaderBytes int    `yaml:"max_header_bytes" toml:"max_header_bytes"`
+	AdminToken     string `yaml:"admin_token" toml:"admin_token"`
+	AdminAddr      string `yaml:"admin_addr" toml:"admin_addr"`
+	Pprof          bool   `yaml:"pprof" toml:"pprof"`
+	Storage        struct {
+		Backend     string `yaml:"backend" toml:"backend"`
+		Path        string `yaml:"path" toml:"path"`
+		DataDir     string `yaml:"data_dir" toml:"data_dir"`
+		DSN         string `yaml:"dsn" toml:"dsn"`
+		MaxConns    int    `yaml:"max_conns" toml:"max_conns"`
+		AutoMigrate bool   `yaml:"auto_migrate" toml:"auto_migrate"`
+	} `yaml:"storage" toml:"storage"`
+	Handlers struct {
+		RateLimit         int          `yaml:"rate_limit" toml:"rate_limit"`
+		MaxBodyBytes      int64        `yaml:"max_body_bytes" toml:"max_body_bytes"`
+		RequestTimeout    fileDuration `yaml:"request_timeout" toml:"request_timeout"`
+		StrictContentType bool         `yaml:"strict_content_type" toml:"strict_content_type"`
+		LenientJSON       bool         `yaml:"lenient_json" toml:"lenient_json"`
+		PrettyJSON        bool         `yaml:"pretty_json" toml:"pretty_json"`
+	} `yaml:"handlers" toml:"handlers"`
+}
+
+// fileDuration reads the durations written like 30s or 1m30s. As in the environment, a timeout left out
+// keeps its default while 0 disables it.
+type fileDuration time.Duration
+
+func (d *fileDuration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = fileDuration(parsed)
+	return nil
+}
+
+// or returns the fallback when the duration is unset.
+func (d *fileDuration) or(fallback time.Duration) time.Duration {
+	if d == nil {
+		return fallback
+	}
+
+	return time.Duration(*d)
+}
+
+// LoadConfig reads the YAML (.yaml, .yml) or TOML (.toml) file at path, unknown keys are rejected so a
+// typo doesn't silently fall back to a default. A sample YAML file:
+//
+//	port: 9093
+//	tls:
+//	  cert: /etc/citizen/server.crt
+//	  key: /etc/citizen/server.key
+//	timeouts:
+//	  drain: 30s
+//	handlers:
+//	  rate_limit: 100
+//
+// The resulting Config is validated before being returned.
+func LoadConfig(path string) (Config, error) {
+	file, err := readConfigFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	err = file.validate()
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid config file %s. %w", path, err)
+	}
+
+	cfg := file.config()
+	err = cfg.Validate()
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid config file %s. %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// LoadStorageConfig reads the storage section of a LoadConfig file, e.g. for the tools migrating the
+// storage on a host without the server certificate. The rest of the file is parsed but not validated.
+func LoadStorageConfig(path string) (storage.Config, error) {
+	file, err := readConfigFile(path)
+	if err != nil {
+		return storage.Config{}, err
+	}
+
+	storageCfg := file.storageConfig()
+	err = storageCfg.Validate()
+	if err != nil {
+		return storage.Config{}, fmt.Errorf("invalid config file %s. %w", path, err)
+	}
+
+	return storageCfg, nil
+}
+
+func readConfigFile(path string) (fileConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("unable to read config file. %s", err.Error())
+	}
+
+	file := fileConfig{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(content), &file)
+		if err == nil && len(meta.Undecoded()) != 0 {
+			err = fmt.Errorf("unknown keys %v", meta.Undecoded())
+		}
+	default:
+		return fileConfig{}, fmt.Errorf("unsupported config file extension '%s', use .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("unable to parse config file %s. %s", path, err.Error())
+	}
+
+	return file, nil
+}
+
+// validate checks what Config.Validate can't tell apart from an unset value, e.g. a negative timeout.
+func (file fileConfig) validate() error {
+	var errs []error
+	durations := []struct {
+		key   string
+		value *fileDuration
+	}{
+		{"timeouts.drain", file.Timeouts.Drain},
+		{"timeouts.long_lived_drain", file.Timeouts.LongLivedDrain},
+		{"timeouts.lame_duck", file.Timeouts.LameDuck},
+		{"timeouts.read_header", file.Timeouts.ReadHeader},
+		{"timeouts.read", file.Timeouts.Read},
+		{"timeouts.write", file.Timeouts.Write},
+		{"timeouts.idle", file.Timeouts.Idle},
+	}
+	for _, duration := range durations {
+		if duration.value != nil && *duration.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", duration.key))
+		}
+	}
+	if file.MaxConns < 0 {
+		errs = append(errs, fmt.Errorf("max_conns must not be negative"))
+	}
+	if file.MaxHeaderBytes < 0 {
+		errs = append(errs, fmt.Errorf("max_header_bytes must not be negative"))
+	}
+	if file.TLS.Plaintext && (len(file.TLS.Cert) != 0 || len(file.TLS.Key) != 0) {
+		errs = append(errs, fmt.Errorf("tls.cert and tls.key can't be set on a plaintext server"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (file fileConfig) config() Config {
+	port := file.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	cfg := NewConfig(port, file.TLS.Cert, file.TLS.Key).
+		WithMaxConns(file.MaxConns).
+		WithMaxHeaderBytes(file.MaxHeaderBytes).
+		WithDecoratorParams(DecoratorParams{
+			RateLimit:        file.Handlers.RateLimit,
+			MaxBodyBytes:     file.Handlers.MaxBodyBytes,
+			RequestTimeoutMs: time.Duration(file.Handlers.RequestTimeout).Milliseconds(),
+		})
+	if len(file.UnixSocket) != 0 {
+		cfg = cfg.WithUnixSocket(file.UnixSocket)
+	}
+	if file.TLS.Plaintext {
+		cfg = cfg.WithPlaintext()
+	}
+	if file.TLS.HTTP2 != nil {
+		cfg = cfg.WithHTTP2(*file.TLS.HTTP2)
+	}
+	cfg = cfg.
+		WithDrainTimeout(file.Timeouts.Drain.or(defaultDrainTimeout)).
+		WithLongLivedDrainTimeout(file.Timeouts.LongLivedDrain.or(defaultLongLivedDrainTimeout)).
+		WithLameDuckDuration(file.Timeouts.LameDuck.or(0)).
+		WithServerTimeouts(ServerTimeouts{
+			ReadHeader: file.Timeouts.ReadHeader.or(defaultServerTimeouts.ReadHeader),
+			Read:       file.Timeouts.Read.or(defaultServerTimeouts.Read),
+			Write:      file.Timeouts.Write.or(defaultServerTimeouts.Write),
+			Idle:       file.Timeouts.Idle.or(defaultServerTimeouts.Idle),
+		})
+	if len(file.AdminToken) != 0 {
+		cfg = cfg.WithAdminToken(file.AdminToken)
+	}
+	if len(file.AdminAddr) != 0 {
+		cfg = cfg.WithAdminAddr(file.AdminAddr)
+	}
+	if file.Pprof {
+		cfg = cfg.WithPprof()
+	}
+	cfg = cfg.WithStorage(file.storageConfig())
+	if file.Handlers.StrictContentType {
+		cfg = cfg.WithStrictContentType()
+	}
+	if file.Handlers.LenientJSON {
+		cfg = cfg.WithLenientJSON()
+	}
+	if file.Handlers.PrettyJSON {
+		cfg = cfg.WithPrettyJSON()
+	}
+
+	return cfg
+}
+
+func (file fileConfig) storageConfig() storage.Config {
+	return storage.Config{
+		Backend:     file.Storage.Backend,
+		Path:        file.Storage.Path,
+		DataDir:     file.Storage.DataDir,
+		DSN:         file.Storage.DSN,
+		MaxConns:    file.Storage.MaxConns,
+		AutoMigrate: file.Storage.AutoMigrate,
+	}
+}
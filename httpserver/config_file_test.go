@@ -0,0 +1,95 @@
+package httpserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophersland/citizen/storage"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	certFile, keyFile := newTestConfig(9093).certificatePemFilePath, newTestConfig(9093).certificatePemPrivKeyFilePath
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{"citizen.yaml", "port: 9094\ntls:\n  cert: " + certFile + "\n  key: " + keyFile + "\ntimeouts:\n  drain: 30s\nhandlers:\n  rate_limit: 100\n"},
+		{"citizen.toml", "port = 9094\n[tls]\ncert = \"" + certFile + "\"\nkey = \"" + keyFile + "\"\n[timeouts]\ndrain = \"30s\"\n[handlers]\nrate_limit = 100\n"},
+	}
+
+	for _, testCase := range testCases {
+		cfg, err := LoadConfig(writeConfigFile(t, testCase.name, testCase.content))
+		if err != nil {
+			t.Fatalf("%s: %v", testCase.name, err)
+		}
+
+		if cfg.port != 9094 || cfg.drainTimeout != 30*time.Second || cfg.decoratorParams.RateLimit != 100 {
+			t.Fatalf("%s: loaded configuration '%+v' does not match the file", testCase.name, cfg)
+		}
+	}
+}
+
+func TestLoadConfigZeroDisablesTimeouts(t *testing.T) {
+	cfg, err := LoadConfig(writeConfigFile(t, "citizen.yaml", "tls:\n  plaintext: true\ntimeouts:\n  read: 0s\n  drain: 0s\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.serverTimeouts.Read != 0 || cfg.drainTimeout != 0 {
+		t.Fatalf("zero read '%v' and drain '%v' timeouts are supposed to be disabled", cfg.serverTimeouts.Read, cfg.drainTimeout)
+	}
+	if cfg.serverTimeouts.Write != defaultServerTimeouts.Write || cfg.longLivedDrainTimeout != defaultLongLivedDrainTimeout {
+		t.Fatalf("omitted write '%v' and long-lived drain '%v' timeouts are supposed to keep their defaults", cfg.serverTimeouts.Write, cfg.longLivedDrainTimeout)
+	}
+}
+
+func TestLoadConfigRejectsInvalidFiles(t *testing.T) {
+	testCases := []struct {
+		name          string
+		content       string
+		expectedError string
+	}{
+		{"unknown.yaml", "port: 9094\nprot: 9095\n", "prot"},
+		{"unknown.toml", "port = 9094\nprot = 9095\n", "prot"},
+		{"negative.yaml", "tls:\n  plaintext: true\ntimeouts:\n  drain: -1s\n", "timeouts.drain must not be negative"},
+		{"duration.yaml", "tls:\n  plaintext: true\ntimeouts:\n  drain: soon\n", "soon"},
+		{"certificate.yaml", "port: 9094\n", "file paths are required"},
+		{"citizen.json", "{}", "unsupported config file extension"},
+	}
+
+	for _, testCase := range testCases {
+		_, err := LoadConfig(writeConfigFile(t, testCase.name, testCase.content))
+		if err == nil || !strings.Contains(err.Error(), testCase.expectedError) {
+			t.Fatalf("%s: returned error '%v' does not contain '%v'", testCase.name, err, testCase.expectedError)
+		}
+	}
+}
+
+func TestLoadStorageConfigWithoutCertificate(t *testing.T) {
+	storageCfg, err := LoadStorageConfig(writeConfigFile(t, "citizen.yaml", "port: 9094\nstorage:\n  backend: postgres\n  dsn: postgres://citizen@localhost:5432/citizen\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if storageCfg.Backend != storage.BackendPostgres || storageCfg.DSN != "postgres://citizen@localhost:5432/citizen" {
+		t.Fatalf("loaded storage configuration '%+v' does not match the file", storageCfg)
+	}
+
+	_, err = LoadStorageConfig(writeConfigFile(t, "citizen.yaml", "storage:\n  backend: postgres\n"))
+	if err == nil || !strings.Contains(err.Error(), "requires a DSN") {
+		t.Fatalf("returned error '%v' does not contain '%v'", err, "requires a DSN")
+	}
+}
@@ -0,0 +1,67 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		cfg           Config
+		expectedError string
+	}{
+		{"valid", newTestConfig(9093), ""},
+		{"port too low", newTestConfig(0), "out of the 1-65535 range"},
+		{"port too high", newTestConfig(65536), "out of the 1-65535 range"},
+		{"unix socket without port", newTestConfig(0).WithUnixSocket("/tmp/citizen.sock"), ""},
+		{"listener without port", newTestConfig(0).WithListener(&net.TCPListener{}), ""},
+		{"missing certificate", NewConfig(9093, "does-not-exist.crt", newTestConfig(9093).certificatePemPrivKeyFilePath), "unable to read TLS file"},
+		{"missing paths", NewConfig(9093, "", ""), "file paths are required"},
+		{"mismatching key pair", NewConfig(9093, newTestConfig(9093).certificatePemFilePath, newTestConfig(9093).certificatePemFilePath), "valid X.509 key pair"},
+		{"autocert with static certificates", newTestConfig(9093).WithAutocert("", "citizen.gophersland.com"), "mutually exclusive"},
+		{"autocert", NewConfig(9093, "", "").WithAutocert("", "citizen.gophersland.com"), ""},
+		{"negative rate limit", newTestConfig(9093).WithDecoratorParams(DecoratorParams{RateLimit: -1}), "rate_limit"},
+		{"plaintext certificate reload", NewConfig(9093, "", "").WithPlaintext().WithCertReloadOnSIGHUP(), "require TLS"},
+		{"plaintext additional key pairs", NewConfig(9093, "", "").WithPlaintext().WithAdditionalKeyPair("extra.crt", "extra.key"), "require TLS"},
+		{"plaintext admin client CNs", NewConfig(9093, "", "").WithPlaintext().WithAdminClientCNs("ops"), "require TLS"},
+		{"admin listener on every interface", newTestConfig(9093).WithAdminAddr(":9095"), "loopback"},
+		{"admin listener on localhost", newTestConfig(9093).WithAdminAddr("localhost:9095"), ""},
+		{"admin client CNs without client CAs", newTestConfig(9093).WithAdminClientCNs("ops"), "client CAs"},
+		{"admin listener behind client CNs", newTestConfig(9093).WithAdminAddr(":9095").WithAdminClientCNs("ops").WithClientCAs(x509.NewCertPool()), ""},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.cfg.Validate()
+		if len(testCase.expectedError) == 0 {
+			if err != nil {
+				t.Fatalf("%s: validation is not supposed to fail. %v", testCase.name, err)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), testCase.expectedError) {
+			t.Fatalf("%s: returned error '%v' does not contain '%v'", testCase.name, err, testCase.expectedError)
+		}
+	}
+}
+
+func TestRunServerValidatesConfig(t *testing.T) {
+	served := false
+	serveReqs := func(ctx context.Context, cfg Config, deps ReqHandlersDependencies) error {
+		served = true
+		return nil
+	}
+
+	err := RunServerImpl(context.Background(), NewConfig(9093, "does-not-exist.crt", "does-not-exist.key"), serveReqs, NewReqHandlersDependencies("test pong"))
+	if err == nil {
+		t.Fatal("running the server with an invalid configuration is supposed to fail")
+	}
+
+	if served {
+		t.Fatal("requests are not supposed to be served with an invalid configuration")
+	}
+}
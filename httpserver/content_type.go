@@ -0,0 +1,31 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// requireContentType answers 415 Unsupported Media Type to the requests with a body of another media type
+// than the given one, parameters such as the charset are tolerated. Bodyless requests go through.
+func requireContentType(mediaType string) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength == 0 || r.Body == nil || r.Body == http.NoBody {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			contentType := r.Header.Get("Content-Type")
+			if requested, _, err := mime.ParseMediaType(contentType); err != nil || requested != mediaType {
+				WriteError(w, CodeUnsupportedMediaType, fmt.Errorf("Content-Type '%s' is not supported, expected '%s'", contentType, mediaType))
+				return
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireContentType(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithStrictContentType(), NewReqHandlersDependencies("test pong"))
+
+	testCases := []struct {
+		name         string
+		req          *http.Request
+		contentType  string
+		expectedCode int
+	}{
+		{"wrong content type", httptest.NewRequest("POST", pingRoute, createPingReq()), "text/plain", http.StatusUnsupportedMediaType},
+		{"missing content type", httptest.NewRequest("POST", pingRoute, createPingReq()), "", http.StatusUnsupportedMediaType},
+		{"JSON with charset", httptest.NewRequest("POST", pingRoute, createPingReq()), "application/json; charset=utf-8", http.StatusOK},
+		{"bodyless GET", httptest.NewRequest("GET", pingRoute+"?value=test+ping+value", nil), "", http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		if len(testCase.contentType) != 0 {
+			testCase.req.Header.Set("Content-Type", testCase.contentType)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, testCase.req)
+
+		if res.Code != testCase.expectedCode {
+			t.Fatalf("%v returned response code '%v', expected '%v'", testCase.name, res.Code, testCase.expectedCode)
+		}
+
+		if testCase.expectedCode == http.StatusUnsupportedMediaType {
+			var errRes errorRes
+			err := json.Unmarshal(res.Body.Bytes(), &errRes)
+			if err != nil {
+				t.Fatalf("%v returned a 415 body '%v' which is not JSON. %v", testCase.name, res.Body.String(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSPreflightMaxAge(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithCORS(10*time.Minute, "https://app.example.com"), NewReqHandlersDependencies("test pong"))
+
+	req := httptest.NewRequest("OPTIONS", pingRoute, nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusNoContent)
+	}
+	if res.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("returned Access-Control-Max-Age header '%v' is not as expected one '600'", res.Header().Get("Access-Control-Max-Age"))
+	}
+	if res.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatalf("returned Access-Control-Allow-Origin header '%v' is not the request origin", res.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	req = httptest.NewRequest("POST", pingRoute, createPingReq())
+	req.Header.Set("Origin", "https://app.example.com")
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+	if _, ok := res.Header()["Access-Control-Max-Age"]; ok {
+		t.Fatal("Access-Control-Max-Age header is not supposed to be set outside of a preflight")
+	}
+}
+
+func TestCORSIgnoresDisallowedOrigin(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithCORS(10*time.Minute, "https://app.example.com"), NewReqHandlersDependencies("test pong"))
+
+	req := httptest.NewRequest("POST", pingRoute, createPingReq())
+	req.Header.Set("Origin", "https://evil.example.com")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if _, ok := res.Header()["Access-Control-Allow-Origin"]; ok {
+		t.Fatal("Access-Control-Allow-Origin header is not supposed to be set for a disallowed origin")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BatchedRequest is a copy of a debounced request, kept after the request itself completed.
+type BatchedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Debounce coalesces the bursts of requests sharing the Key returned for them into a single Process call.
+type Debounce struct {
+	Window  time.Duration
+	Key     func(r *http.Request) string
+	Process func(batch []BatchedRequest)
+}
+
+// debounceBatch answers 202 Accepted right away and collects the requests sharing a key for the window
+// starting with the first of them, process then runs once with the whole batch. Requests keyFn
+// returns an empty key for go to the handler as usual.
+func debounceBatch(window time.Duration, keyFn func(r *http.Request) string, process func([]BatchedRequest)) httpResDecorator {
+	mu := sync.Mutex{}
+	pending := map[string][]BatchedRequest{}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFn(r)
+			if len(key) == 0 {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				WriteError(w, readRequestErrCode(err), fmt.Errorf("unable to read request body. %s", err.Error()))
+				return
+			}
+
+			mu.Lock()
+			batch, started := pending[key]
+			pending[key] = append(batch, BatchedRequest{r.Method, r.URL.Path, r.Header.Clone(), body})
+			mu.Unlock()
+
+			if !started {
+				time.AfterFunc(window, func() {
+					mu.Lock()
+					batch := pending[key]
+					delete(pending, key)
+					mu.Unlock()
+
+					process(batch)
+				})
+			}
+
+			w.WriteHeader(http.StatusAccepted)
+		})
+	}
+}
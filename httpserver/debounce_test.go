@@ -0,0 +1,56 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebounceBatch(t *testing.T) {
+	batches := make(chan []BatchedRequest, 10)
+	keyFn := func(r *http.Request) string { return r.URL.Query().Get("repo") }
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), debounceBatch(100*time.Millisecond, keyFn, func(batch []BatchedRequest) {
+		batches <- batch
+	}))
+
+	for i := 0; i < 5; i++ {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("POST", "/webhook?repo=citizen", strings.NewReader(`{"event": "push"}`)))
+		if res.Code != http.StatusAccepted {
+			t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusAccepted)
+		}
+	}
+
+	select {
+	case batch := <-batches:
+		if len(batch) != 5 {
+			t.Fatalf("processed batch holds %v requests instead of %v", len(batch), 5)
+		}
+		if string(batch[0].Body) != `{"event": "push"}` {
+			t.Fatalf("batched request body '%v' is not as expected", string(batch[0].Body))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("burst was never processed")
+	}
+
+	select {
+	case batch := <-batches:
+		t.Fatalf("burst was processed more than once, extra batch of %v requests", len(batch))
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestDebounceBatchWithoutKey(t *testing.T) {
+	keyFn := func(r *http.Request) string { return "" }
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), debounceBatch(time.Second, keyFn, func(batch []BatchedRequest) {
+		t.Error("requests without a key are not supposed to be batched")
+	}))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+}
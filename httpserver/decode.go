@@ -0,0 +1,133 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// validator is implemented by the request types that have rules json.Decoder can't express,
+// readRequest calls Validate after a successful decode.
+type validator interface {
+	Validate() error
+}
+
+type decodeOptionsCtxKey struct{}
+
+type decodeOptions struct {
+	allowUnknownFields bool
+}
+
+// lenientDecoding makes readRequest ignore unknown JSON fields instead of rejecting the request.
+func lenientDecoding() httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), decodeOptionsCtxKey{}, decodeOptions{allowUnknownFields: true})
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func requestDecodeOptions(r *http.Request) decodeOptions {
+	opts, _ := r.Context().Value(decodeOptionsCtxKey{}).(decodeOptions)
+	return opts
+}
+
+// decodeQuery fills the struct pointed by reqBody from the query parameters named after its JSON fields,
+// for the clients only able to issue a GET, e.g. monitoring probes. Other parameters are ignored.
+func decodeQuery(query url.Values, reqBody interface{}) error {
+	v := reflect.ValueOf(reqBody).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		if !query.Has(name) {
+			continue
+		}
+
+		param := query.Get(name)
+		fieldValue := v.Field(i)
+		switch fieldValue.Kind() {
+		case reflect.String:
+			fieldValue.SetString(param)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(param)
+			if err != nil {
+				return fmt.Errorf("query parameter '%s' must be a boolean", name)
+			}
+			fieldValue.SetBool(b)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(param, 10, fieldValue.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("query parameter '%s' must be an integer", name)
+			}
+			fieldValue.SetInt(n)
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(param, fieldValue.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("query parameter '%s' must be a number", name)
+			}
+			fieldValue.SetFloat(f)
+		default:
+			return fmt.Errorf("query parameter '%s' can't be decoded into a %s", name, fieldValue.Kind())
+		}
+	}
+
+	return nil
+}
+
+// RequestDecodeError locates where a request body stopped being decodable, Field and Expected are only
+// set for a value of the wrong type.
+type RequestDecodeError struct {
+	Offset   int64
+	Field    string
+	Expected string
+	Got      string
+	Err      error
+}
+
+func (e *RequestDecodeError) Error() string {
+	if len(e.Field) != 0 {
+		return fmt.Sprintf("unable to unmarshal request body. field '%s' must be a %s, got %s, at byte offset %d", e.Field, e.Expected, e.Got, e.Offset)
+	}
+
+	return fmt.Sprintf("unable to unmarshal request body. %s, at byte offset %d", e.Err.Error(), e.Offset)
+}
+
+func (e *RequestDecodeError) Unwrap() error {
+	return e.Err
+}
+
+func newRequestDecodeError(err error, body []byte) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &RequestDecodeError{Offset: syntaxErr.Offset, Err: err}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &RequestDecodeError{Offset: typeErr.Offset, Field: typeErr.Field, Expected: typeErr.Type.String(), Got: typeErr.Value, Err: err}
+	}
+
+	// json.Decoder reports a body cut in the middle of a value as io.ErrUnexpectedEOF.
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return &RequestDecodeError{Offset: int64(len(body)), Err: fmt.Errorf("truncated JSON")}
+	}
+
+	return fmt.Errorf("unable to unmarshal request body. %s", err.Error())
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Tiny bodies compress with absurd ratios, the ratio guard only kicks in above this size.
+	minDecompressionRatioCheckBytes = 1024
+)
+
+// decompressRequest transparently inflates gzip request bodies, aborting once the inflated size exceeds
+// maxRatio times the compressed size or the MaxBodyBytes limit.
+func decompressRequest(maxRatio int64, params *liveParams) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			compressed := &countingReader{reader: r.Body}
+			gzipReader, err := gzip.NewReader(compressed)
+			if err != nil {
+				WriteError(w, CodeInvalidRequest, fmt.Errorf("unable to decompress request body. %s", err.Error()))
+				return
+			}
+
+			r.Body = &guardedDecompressor{
+				gzipReader:   gzipReader,
+				body:         r.Body,
+				compressed:   compressed,
+				maxRatio:     maxRatio,
+				maxBodyBytes: params.load().MaxBodyBytes,
+			}
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+type guardedDecompressor struct {
+	gzipReader   *gzip.Reader
+	body         io.Closer
+	compressed   *countingReader
+	decompressed int64
+	maxRatio     int64
+	maxBodyBytes int64
+}
+
+func (gd *guardedDecompressor) Read(p []byte) (int, error) {
+	n, err := gd.gzipReader.Read(p)
+	gd.decompressed += int64(n)
+
+	if gd.maxBodyBytes > 0 && gd.decompressed > gd.maxBodyBytes {
+		return n, fmt.Errorf("decompressed request body exceeds %d bytes", gd.maxBodyBytes)
+	}
+
+	if gd.decompressed > minDecompressionRatioCheckBytes && gd.decompressed > gd.maxRatio*gd.compressed.n {
+		return n, fmt.Errorf("request body decompression ratio exceeds %d", gd.maxRatio)
+	}
+
+	return n, err
+}
+
+func (gd *guardedDecompressor) Close() error {
+	gd.gzipReader.Close()
+	return gd.body.Close()
+}
@@ -0,0 +1,70 @@
+package httpserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecompressRequest(t *testing.T) {
+	reqBody, _ := json.Marshal(pingReq{"test ping value"})
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), decompressRequest(100, newLiveParams(DecoratorParams{})))
+
+	req := httptest.NewRequest("POST", pingRoute, bytes.NewReader(gzipBytes(t, reqBody)))
+	req.Header.Set("Content-Encoding", "gzip")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'. %s", res.Code, http.StatusOK, res.Body.String())
+	}
+}
+
+func TestDecompressRequestRejectsBomb(t *testing.T) {
+	bomb := []byte(`{"value": "` + strings.Repeat("0", 10*1024*1024) + `"}`)
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), decompressRequest(100, newLiveParams(DecoratorParams{})))
+
+	req := httptest.NewRequest("POST", pingRoute, bytes.NewReader(gzipBytes(t, bomb)))
+	req.Header.Set("Content-Encoding", "gzip")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusBadRequest)
+	}
+
+	if !strings.Contains(res.Body.String(), "decompression ratio exceeds 100") {
+		t.Fatalf("returned response '%v' does not report the decompression ratio", res.Body.String())
+	}
+}
+
+func TestDecompressRequestRespectsBodyLimit(t *testing.T) {
+	reqBody, _ := json.Marshal(pingReq{strings.Repeat("a", 2048)})
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), decompressRequest(1000, newLiveParams(DecoratorParams{MaxBodyBytes: 1024})))
+
+	req := httptest.NewRequest("POST", pingRoute, bytes.NewReader(gzipBytes(t, reqBody)))
+	req.Header.Set("Content-Encoding", "gzip")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusBadRequest)
+	}
+}
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	if _, err := gzipWriter.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
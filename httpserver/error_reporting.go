@@ -0,0 +1,59 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// ErrorSink receives the panics and server errors of the handlers, e.g. to forward them to Sentry or Rollbar.
+type ErrorSink interface {
+	Report(r *http.Request, err error)
+}
+
+type noopErrorSink struct{}
+
+func (noopErrorSink) Report(r *http.Request, err error) {}
+
+var NoopErrorSink ErrorSink = noopErrorSink{}
+
+// errorReporter recovers handler panics into a JSON 500, logs them with their stack trace and reports them,
+// together with every 5xx response, to the sink. The sink gets the request with its URL redacted.
+func errorReporter(sink ErrorSink, logger Logger, redactedParams []string) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newStatusRecorder(w)
+
+			defer func() {
+				recovered := recover()
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				if recovered != nil {
+					logPanic(logger, r, recovered, debug.Stack())
+					sink.Report(redactedRequest(r, redactedParams), fmt.Errorf("handler panicked. %v", recovered))
+					if !rec.wroteHeader {
+						WriteError(rec, CodeInternal, errors.New("internal server error"))
+					}
+					return
+				}
+
+				if rec.statusCode >= http.StatusInternalServerError {
+					sink.Report(redactedRequest(r, redactedParams), fmt.Errorf("%s %s responded with status %d", r.Method, r.URL.Path, rec.statusCode))
+				}
+			}()
+
+			handler.ServeHTTP(rec, r)
+		})
+	}
+}
+
+func logPanic(logger Logger, r *http.Request, recovered interface{}, stack []byte) {
+	logger.Error("Handler panicked.", "path", r.URL.Path, "panic", fmt.Sprint(recovered), "request_id", RequestIDFromContext(r.Context()),
+		"stack", string(stack))
+}
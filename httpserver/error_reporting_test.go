@@ -0,0 +1,73 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeErrorSink struct {
+	mu      sync.Mutex
+	reports []string
+}
+
+func (sink *fakeErrorSink) Report(r *http.Request, err error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	sink.reports = append(sink.reports, r.URL.Path+": "+err.Error())
+}
+
+func TestErrorReporterReportsPanic(t *testing.T) {
+	sink := &fakeErrorSink{}
+	panickingHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	res := httptest.NewRecorder()
+	decorateHttpRes(panickingHandler, addJsonHeader(""), errorReporter(sink, NoopLogger, defaultRedactedQueryParams)).ServeHTTP(res, httptest.NewRequest("POST", "/panic", nil))
+
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusInternalServerError)
+	}
+
+	if res.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("returned response header '%v' is not '%v'", res.Header().Get("Content-Type"), "application/json")
+	}
+
+	if len(sink.reports) != 1 || !strings.Contains(sink.reports[0], "/panic: handler panicked. boom") {
+		t.Fatalf("sink received '%v', expected a single panic report", sink.reports)
+	}
+}
+
+func TestErrorReporterReportsServerError(t *testing.T) {
+	sink := &fakeErrorSink{}
+	failingHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, CodeInternal, errors.New("storage unavailable"))
+	})
+
+	res := httptest.NewRecorder()
+	decorateHttpRes(failingHandler, errorReporter(sink, NoopLogger, defaultRedactedQueryParams)).ServeHTTP(res, httptest.NewRequest("POST", "/failing", nil))
+
+	if len(sink.reports) != 1 || !strings.Contains(sink.reports[0], "responded with status 500") {
+		t.Fatalf("sink received '%v', expected a single server error report", sink.reports)
+	}
+}
+
+func TestErrorReporterIgnoresClientErrors(t *testing.T) {
+	sink := &fakeErrorSink{}
+
+	res := httptest.NewRecorder()
+	decorateHttpRes(pingHandlerImpl("test pong"), errorReporter(sink, NoopLogger, defaultRedactedQueryParams)).ServeHTTP(res, httptest.NewRequest("POST", pingRoute, strings.NewReader("{}")))
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusBadRequest)
+	}
+
+	if len(sink.reports) != 0 {
+		t.Fatalf("sink received '%v', client errors are not supposed to be reported", sink.reports)
+	}
+}
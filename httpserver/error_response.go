@@ -0,0 +1,86 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorCode is the stable kind of an error response, clients branch on it rather than on the message
+// which is meant for humans and may change.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest          ErrorCode = "invalid_request"
+	CodeUnauthorized            ErrorCode = "unauthorized"
+	CodeNotFound                ErrorCode = "not_found"
+	CodeMethodNotAllowed        ErrorCode = "method_not_allowed"
+	CodeConflict                ErrorCode = "conflict"
+	CodePayloadTooLarge         ErrorCode = "payload_too_large"
+	CodeUnsupportedMediaType    ErrorCode = "unsupported_media_type"
+	CodeMisdirectedRequest      ErrorCode = "misdirected_request"
+	CodeRateLimited             ErrorCode = "rate_limited"
+	CodeClientClosedRequest     ErrorCode = "client_closed_request"
+	CodeInternal                ErrorCode = "internal"
+	CodeBadGateway              ErrorCode = "bad_gateway"
+	CodeUnavailable             ErrorCode = "unavailable"
+	CodeTimeout                 ErrorCode = "timeout"
+	CodeHTTPVersionNotSupported ErrorCode = "http_version_not_supported"
+)
+
+var errorCodeStatuses = map[ErrorCode]int{
+	CodeInvalidRequest:          http.StatusBadRequest,
+	CodeUnauthorized:            http.StatusUnauthorized,
+	CodeNotFound:                http.StatusNotFound,
+	CodeMethodNotAllowed:        http.StatusMethodNotAllowed,
+	CodeConflict:                http.StatusConflict,
+	CodePayloadTooLarge:         http.StatusRequestEntityTooLarge,
+	CodeUnsupportedMediaType:    http.StatusUnsupportedMediaType,
+	CodeMisdirectedRequest:      http.StatusMisdirectedRequest,
+	CodeRateLimited:             http.StatusTooManyRequests,
+	CodeClientClosedRequest:     statusClientClosedRequest,
+	CodeInternal:                http.StatusInternalServerError,
+	CodeBadGateway:              http.StatusBadGateway,
+	CodeUnavailable:             http.StatusServiceUnavailable,
+	CodeTimeout:                 http.StatusGatewayTimeout,
+	CodeHTTPVersionNotSupported: http.StatusHTTPVersionNotSupported,
+}
+
+// HTTPStatus is the response status of the code, 500 for an unknown one.
+func (code ErrorCode) HTTPStatus() int {
+	status, ok := errorCodeStatuses[code]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+
+	return status
+}
+
+// WriteError answers with the JSON error envelope of err and the status of code. The envelope carries the
+// request ID set by RequestIDMiddleware and, for a RequestDecodeError, the field that failed to decode.
+func WriteError(w http.ResponseWriter, code ErrorCode, err error) error {
+	return writeResponse(w, newErrorRes(w, code, err), code.HTTPStatus())
+}
+
+// writeNegotiatedError is WriteError in the format the client asked for.
+func writeNegotiatedError(w http.ResponseWriter, r *http.Request, code ErrorCode, err error) error {
+	return writeNegotiated(w, r, newErrorRes(w, code, err), code.HTTPStatus())
+}
+
+func newErrorRes(w http.ResponseWriter, code ErrorCode, err error) errorRes {
+	body := errorBody{Code: code, Message: err.Error(), RequestID: w.Header().Get(requestIDHeader)}
+
+	var decodeErr *RequestDecodeError
+	if errors.As(err, &decodeErr) {
+		issue := fmt.Sprintf("invalid JSON at byte offset %d", decodeErr.Offset)
+		if len(decodeErr.Field) != 0 {
+			issue = fmt.Sprintf("must be a %s, got %s", decodeErr.Expected, decodeErr.Got)
+		}
+		body.Details = []ErrorDetail{{Field: decodeErr.Field, Issue: issue}}
+	}
+
+	return errorRes{Error: body}
+}
@@ -0,0 +1,70 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	res := httptest.NewRecorder()
+	res.Header().Set(requestIDHeader, "test-request-id")
+	err := WriteError(res, CodeRateLimited, errors.New("slow down"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Code != http.StatusTooManyRequests {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusTooManyRequests)
+	}
+	expected := `{"error":{"code":"rate_limited","message":"slow down","request_id":"test-request-id"}}` + "\n"
+	if res.Body.String() != expected {
+		t.Fatalf("returned body '%v' is not as expected one '%v'", res.Body.String(), expected)
+	}
+}
+
+func TestErrorEnvelopeOfPing(t *testing.T) {
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+
+	req := httptest.NewRequest("POST", pingRoute, strings.NewReader(`{"value": 42}`))
+	req.Header.Set(requestIDHeader, "test-request-id")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusBadRequest)
+	}
+	errRes := errorRes{}
+	err := json.Unmarshal(res.Body.Bytes(), &errRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errRes.Error.Code != CodeInvalidRequest || errRes.Error.RequestID != "test-request-id" {
+		t.Fatalf("returned error '%+v' does not carry the code and the request ID", errRes.Error)
+	}
+	expectedDetails := []ErrorDetail{{Field: "value", Issue: "must be a string, got number"}}
+	if len(errRes.Error.Details) != 1 || errRes.Error.Details[0] != expectedDetails[0] {
+		t.Fatalf("returned details '%v' are not as expected ones '%v'", errRes.Error.Details, expectedDetails)
+	}
+}
+
+func TestErrorCodeHTTPStatus(t *testing.T) {
+	testCases := []struct {
+		code           ErrorCode
+		expectedStatus int
+	}{
+		{CodeInvalidRequest, http.StatusBadRequest},
+		{CodeNotFound, http.StatusNotFound},
+		{CodeClientClosedRequest, statusClientClosedRequest},
+		{ErrorCode("unknown"), http.StatusInternalServerError},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.code.HTTPStatus() != testCase.expectedStatus {
+			t.Fatalf("status '%v' of code %v is not as expected one '%v'", testCase.code.HTTPStatus(), testCase.code, testCase.expectedStatus)
+		}
+	}
+}
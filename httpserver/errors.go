@@ -0,0 +1,29 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrAddrInUse matches, through errors.Is, the error returned when the server port is already taken.
+var ErrAddrInUse = errors.New("address already in use")
+
+type AddrInUseError struct {
+	Port int
+	Err  error
+}
+
+func (e *AddrInUseError) Error() string {
+	return fmt.Sprintf("port %d is already in use. %s", e.Port, e.Err.Error())
+}
+
+func (e *AddrInUseError) Is(target error) bool {
+	return target == ErrAddrInUse
+}
+
+func (e *AddrInUseError) Unwrap() error {
+	return e.Err
+}
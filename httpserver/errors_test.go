@@ -0,0 +1,27 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestServeReportsAddrInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	err = RunServerImpl(context.Background(), newTestConfig(port), ServeReqsImpl, NewReqHandlersDependencies("test pong"))
+	if !errors.Is(err, ErrAddrInUse) {
+		t.Fatalf("returned error '%v' is not '%v'", err, ErrAddrInUse)
+	}
+
+	var addrInUseErr *AddrInUseError
+	if !errors.As(err, &addrInUseErr) || addrInUseErr.Port != port {
+		t.Fatalf("returned error '%v' does not carry the port %v", err, port)
+	}
+}
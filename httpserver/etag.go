@@ -0,0 +1,98 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// etag buffers the successful GET responses to tag them with a hash of their body, answering with
+// 304 Not Modified when the client already holds that version.
+func etag() httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			buffered := &bufferedResponse{header: http.Header{}, statusCode: http.StatusOK, indent: wantsIndentedJSON(w)}
+			for key, values := range w.Header() {
+				buffered.header[key] = values
+			}
+			handler.ServeHTTP(buffered, r)
+
+			for key, values := range buffered.header {
+				w.Header()[key] = values
+			}
+			if buffered.statusCode < 200 || buffered.statusCode >= 300 {
+				w.WriteHeader(buffered.statusCode)
+				w.Write(buffered.body.Bytes())
+				return
+			}
+
+			hash := sha256.Sum256(buffered.body.Bytes())
+			tag := `"` + hex.EncodeToString(hash[:16]) + `"`
+			w.Header().Set("ETag", tag)
+
+			if matchesETag(r.Header.Get("If-None-Match"), tag) {
+				w.Header().Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.WriteHeader(buffered.statusCode)
+			w.Write(buffered.body.Bytes())
+		})
+	}
+}
+
+func matchesETag(ifNoneMatch string, tag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == tag || candidate == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bufferedResponse holds the whole response until the decorator decides what to send.
+type bufferedResponse struct {
+	header      http.Header
+	body        bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+	indent      bool
+}
+
+func (res *bufferedResponse) indentJSON() bool {
+	return res.indent
+}
+
+func (res *bufferedResponse) Header() http.Header {
+	return res.header
+}
+
+func (res *bufferedResponse) WriteHeader(statusCode int) {
+	if res.wroteHeader {
+		return
+	}
+
+	res.wroteHeader = true
+	res.statusCode = statusCode
+}
+
+func (res *bufferedResponse) Write(b []byte) (int, error) {
+	if !res.wroteHeader {
+		res.WriteHeader(http.StatusOK)
+	}
+
+	return res.body.Write(b)
+}
@@ -0,0 +1,57 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	profile := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, pingRes{"citizen profile"}, http.StatusOK)
+	})
+	group := RouteGroup{Prefix: "/v1", Routes: []Route{{Path: "/profile", Handler: profile, Cacheable: true}}}
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithRouteGroups(group))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", "/v1/profile", nil))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+	tag := res.Header().Get("ETag")
+	if len(tag) == 0 {
+		t.Fatal("returned response has no ETag header")
+	}
+	if res.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("returned Content-Type '%v' is not as expected one 'application/json'", res.Header().Get("Content-Type"))
+	}
+
+	req := httptest.NewRequest("GET", "/v1/profile", nil)
+	req.Header.Set("If-None-Match", tag)
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusNotModified {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusNotModified)
+	}
+	if res.Body.Len() != 0 {
+		t.Fatalf("returned 304 body '%v' is supposed to be empty", res.Body.String())
+	}
+}
+
+func TestETagSkipsErrorsAndNonGet(t *testing.T) {
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), etag())
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", pingRoute, nil))
+	if res.Code != http.StatusBadRequest || len(res.Header().Get("ETag")) != 0 {
+		t.Fatalf("error response '%v' is not supposed to be tagged, ETag '%v'", res.Code, res.Header().Get("ETag"))
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if res.Code != http.StatusOK || len(res.Header().Get("ETag")) != 0 {
+		t.Fatalf("POST response '%v' is not supposed to be tagged, ETag '%v'", res.Code, res.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	tenantHeader  = "X-Tenant-ID"
+	defaultTenant = ""
+)
+
+// weightedAdmission splits the request capacity between tenants proportionally to their weight, so a busy tenant
+// can never take the slots of the others. Tenants without a configured weight share the default tenant "", of
+// weight 1 unless configured, so the tenants are bounded by the weights whatever the requests claim.
+type weightedAdmission struct {
+	capacity    int
+	weights     map[string]int
+	totalWeight int
+
+	mu          sync.Mutex
+	active      map[string]int
+	totalActive int
+}
+
+func newWeightedAdmission(capacity int, weights map[string]int) *weightedAdmission {
+	totalWeight := 0
+	for _, weight := range weights {
+		totalWeight += weight
+	}
+	if _, ok := weights[defaultTenant]; !ok {
+		totalWeight++
+	}
+
+	return &weightedAdmission{
+		capacity:    capacity,
+		weights:     weights,
+		totalWeight: totalWeight,
+		active:      make(map[string]int),
+	}
+}
+
+func (wa *weightedAdmission) tenant(tenant string) string {
+	if _, ok := wa.weights[tenant]; !ok {
+		return defaultTenant
+	}
+
+	return tenant
+}
+
+func (wa *weightedAdmission) share(tenant string) int {
+	weight, ok := wa.weights[tenant]
+	if !ok {
+		weight = 1
+	}
+
+	share := wa.capacity * weight / wa.totalWeight
+	if share < 1 {
+		return 1
+	}
+
+	return share
+}
+
+func (wa *weightedAdmission) admit(tenant string) bool {
+	tenant = wa.tenant(tenant)
+	wa.mu.Lock()
+	defer wa.mu.Unlock()
+
+	if wa.totalActive >= wa.capacity || wa.active[tenant] >= wa.share(tenant) {
+		return false
+	}
+
+	wa.active[tenant]++
+	wa.totalActive++
+	return true
+}
+
+func (wa *weightedAdmission) done(tenant string) {
+	tenant = wa.tenant(tenant)
+	wa.mu.Lock()
+	defer wa.mu.Unlock()
+
+	wa.active[tenant]--
+	wa.totalActive--
+	if wa.active[tenant] <= 0 {
+		delete(wa.active, tenant)
+	}
+}
+
+// The admission is shared by all the routes of a server, the capacity is server-wide.
+func fairQueue(admission *weightedAdmission, tenantOf func(r *http.Request) string) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant := tenantOf(r)
+			if !admission.admit(tenant) {
+				w.Header().Set("Retry-After", "1")
+				WriteError(w, CodeUnavailable, fmt.Errorf("server is saturated, tenant '%s' exceeded its share", tenant))
+				return
+			}
+			defer admission.done(tenant)
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestTenant is the identity of the verified client certificate, otherwise the X-Tenant-ID header of a
+// trusted proxy. The header of any other peer is ignored, anyone could claim the share of another tenant.
+func requestTenant(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) != 0 {
+		identity, _ := clientCertIdentity(r.TLS.PeerCertificates)
+		return identity
+	}
+	if fromTrustedProxy(r) {
+		return r.Header.Get(tenantHeader)
+	}
+
+	return defaultTenant
+}
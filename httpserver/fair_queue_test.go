@@ -0,0 +1,144 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tenantHeaderOf(r *http.Request) string {
+	return r.Header.Get(tenantHeader)
+}
+
+func TestFairQueueAdmissionRatio(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	admitted := map[string]int{}
+	blockingHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		admitted[tenantHeaderOf(r)]++
+		mu.Unlock()
+		<-release
+	})
+	// The default tenant takes 2 of the 10 slots.
+	handler := decorateHttpRes(blockingHandler, fairQueue(newWeightedAdmission(10, map[string]int{"gold": 3, "bronze": 1}), tenantHeaderOf))
+
+	var wg sync.WaitGroup
+	var shedMu sync.Mutex
+	shed := 0
+	for i := 0; i < 20; i++ {
+		for _, tenant := range []string{"gold", "bronze"} {
+			wg.Add(1)
+			go func(tenant string) {
+				defer wg.Done()
+				req := httptest.NewRequest("POST", pingRoute, nil)
+				req.Header.Set(tenantHeader, tenant)
+				res := httptest.NewRecorder()
+				handler.ServeHTTP(res, req)
+				if res.Code == http.StatusServiceUnavailable {
+					shedMu.Lock()
+					shed++
+					shedMu.Unlock()
+				}
+			}(tenant)
+		}
+	}
+
+	for {
+		shedMu.Lock()
+		mu.Lock()
+		settled := shed+admitted["gold"]+admitted["bronze"] == 40
+		mu.Unlock()
+		shedMu.Unlock()
+		if settled {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if admitted["gold"] != 6 || admitted["bronze"] != 2 {
+		t.Fatalf("admitted '%v', expected 6 gold and 2 bronze requests for a 3:1 weight ratio", admitted)
+	}
+
+	if shed != 32 {
+		t.Fatalf("%v requests were shed, expected 32", shed)
+	}
+}
+
+func TestFairQueueIsSharedByTheRoutes(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	group := RouteGroup{Prefix: "/blocking", Routes: []Route{{Path: pingRoute, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})}}}
+	cfg := newTestConfig(0).WithFairQueue(1, nil)
+	handler := newHandler(cfg, NewReqHandlersDependencies("test pong").WithRouteGroups(group).WithLogger(NoopLogger))
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/blocking"+pingRoute, nil))
+		close(done)
+	}()
+	<-entered
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	close(release)
+	<-done
+
+	if res.Code != http.StatusServiceUnavailable {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestFairQueueUnweightedTenantsShareTheDefaultTenant(t *testing.T) {
+	admission := newWeightedAdmission(4, map[string]int{"gold": 3})
+	if !admission.admit("alice") {
+		t.Fatal("first unweighted request is supposed to be admitted")
+	}
+	if admission.admit("bob") {
+		t.Fatal("unweighted tenants are supposed to share the single slot of the default tenant")
+	}
+	if len(admission.active) != 1 {
+		t.Fatalf("'%v' tenants are tracked, expected only the default one", len(admission.active))
+	}
+
+	for i := 0; i < 3; i++ {
+		if !admission.admit("gold") {
+			t.Fatalf("gold request %v is supposed to be admitted within its 3 slots", i)
+		}
+	}
+	admission.done("alice")
+	if !admission.admit("bob") {
+		t.Fatal("default tenant slot is supposed to be free again")
+	}
+}
+
+func TestRequestTenant(t *testing.T) {
+	forged := httptest.NewRequest("POST", pingRoute, nil)
+	forged.Header.Set(tenantHeader, "gold")
+	if tenant := requestTenant(forged); tenant != defaultTenant {
+		t.Fatalf("tenant '%v' of an untrusted peer is not as expected one '%v'", tenant, defaultTenant)
+	}
+
+	proxied := forged.WithContext(context.WithValue(forged.Context(), trustedProxyCtxKey{}, true))
+	if tenant := requestTenant(proxied); tenant != "gold" {
+		t.Fatalf("tenant '%v' of a trusted proxy is not as expected one '%v'", tenant, "gold")
+	}
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "bronze"}}
+	authenticated := httptest.NewRequest("POST", pingRoute, nil)
+	authenticated.Header.Set(tenantHeader, "gold")
+	authenticated.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+	if tenant := requestTenant(authenticated); tenant != "bronze" {
+		t.Fatalf("tenant '%v' of a client certificate is not as expected one '%v'", tenant, "bronze")
+	}
+}
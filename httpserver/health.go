@@ -0,0 +1,126 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	healthRoute = "/health"
+	readyRoute  = "/ready"
+	// The Kubernetes style aliases of the liveness and readiness probes.
+	healthzRoute = "/healthz"
+	readyzRoute  = "/readyz"
+)
+
+// HealthCheck reports whether a dependency, e.g. the database, is usable.
+type HealthCheck func(ctx context.Context) error
+
+// HealthReport is the outcome of the checks, /health runs none as it only tells the process is alive.
+type HealthReport struct {
+	Healthy bool
+	Checks  []HealthCheckResult
+}
+
+// HealthCheckResult is the outcome of a single check, Err is nil when it passed.
+type HealthCheckResult struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// errHealthCheckRedacted replaces the check errors when their details must not leak, e.g. in production.
+var errHealthCheckRedacted = errors.New("check failed")
+
+// HealthFormatter renders a report into the body served by /health and /ready, the status code is
+// 200 or 503 depending on the report regardless of the formatter.
+type HealthFormatter func(report HealthReport) (body []byte, contentType string)
+
+// MinimalHealthFormatter answers with a bare "ok" or "unavailable".
+func MinimalHealthFormatter(report HealthReport) ([]byte, string) {
+	if report.Healthy {
+		return []byte("ok\n"), "text/plain; charset=utf-8"
+	}
+
+	return []byte("unavailable\n"), "text/plain; charset=utf-8"
+}
+
+type detailedHealthRes struct {
+	Status string                   `json:"status"`
+	Checks []detailedHealthCheckRes `json:"checks,omitempty"`
+}
+
+type detailedHealthCheckRes struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// DetailedHealthFormatter answers with JSON listing the status, error and duration of every check.
+func DetailedHealthFormatter(report HealthReport) ([]byte, string) {
+	res := detailedHealthRes{Status: "ok"}
+	if !report.Healthy {
+		res.Status = "unavailable"
+	}
+	for _, check := range report.Checks {
+		checkRes := detailedHealthCheckRes{Name: check.Name, Status: "ok", DurationMs: float64(check.Duration) / float64(time.Millisecond)}
+		if check.Err != nil {
+			checkRes.Status = "failing"
+			checkRes.Error = check.Err.Error()
+		}
+		res.Checks = append(res.Checks, checkRes)
+	}
+
+	body, _ := json.Marshal(res)
+	return append(body, '\n'), "application/json"
+}
+
+func healthHandler(checks map[string]HealthCheck, formatter HealthFormatter, redactErrors bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := runHealthChecks(r.Context(), checks)
+		if redactErrors {
+			for i := range report.Checks {
+				if report.Checks[i].Err != nil {
+					report.Checks[i].Err = errHealthCheckRedacted
+				}
+			}
+		}
+
+		body, contentType := formatter(report)
+		w.Header().Set("Content-Type", contentType)
+		if report.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(body)
+	})
+}
+
+func runHealthChecks(ctx context.Context, checks map[string]HealthCheck) HealthReport {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := HealthReport{Healthy: true}
+	for _, name := range names {
+		start := time.Now()
+		err := checks[name](ctx)
+		report.Checks = append(report.Checks, HealthCheckResult{name, err, time.Since(start)})
+		if err != nil {
+			report.Healthy = false
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,85 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMinimalHealthFormatter(t *testing.T) {
+	deps := NewReqHandlersDependencies("test pong").
+		WithHealthFormatter(MinimalHealthFormatter).
+		WithReadinessCheck("database", func(ctx context.Context) error { return errors.New("connection refused") })
+	handler := newHandler(newTestConfig(9093), deps)
+
+	testCases := []struct {
+		route        string
+		expectedCode int
+		expectedBody string
+	}{
+		{healthRoute, http.StatusOK, "ok\n"},
+		{healthzRoute, http.StatusOK, "ok\n"},
+		{readyRoute, http.StatusServiceUnavailable, "unavailable\n"},
+		{readyzRoute, http.StatusServiceUnavailable, "unavailable\n"},
+	}
+
+	for _, testCase := range testCases {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", testCase.route, nil))
+
+		if res.Code != testCase.expectedCode {
+			t.Fatalf("%v returned response code '%v', expected '%v'", testCase.route, res.Code, testCase.expectedCode)
+		}
+		if res.Body.String() != testCase.expectedBody {
+			t.Fatalf("%v returned body '%v', expected '%v'", testCase.route, res.Body.String(), testCase.expectedBody)
+		}
+	}
+}
+
+func TestDetailedHealthFormatter(t *testing.T) {
+	for _, redacted := range []bool{false, true} {
+		deps := NewReqHandlersDependencies("test pong").
+			WithReadinessCheck("database", func(ctx context.Context) error { return nil }).
+			WithReadinessCheck("queue", func(ctx context.Context) error {
+				time.Sleep(5 * time.Millisecond)
+				return errors.New("broker 10.0.0.7:5672 unreachable")
+			})
+		expectedQueueError := "broker 10.0.0.7:5672 unreachable"
+		if redacted {
+			deps = deps.WithRedactedHealthErrors()
+			expectedQueueError = "check failed"
+		}
+		handler := newHandler(newTestConfig(9093), deps)
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", readyRoute, nil))
+
+		if res.Code != http.StatusServiceUnavailable {
+			t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusServiceUnavailable)
+		}
+
+		healthRes := detailedHealthRes{}
+		err := json.Unmarshal(res.Body.Bytes(), &healthRes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if healthRes.Status != "unavailable" || len(healthRes.Checks) != 2 {
+			t.Fatalf("returned health report '%+v' is not as expected", healthRes)
+		}
+
+		database, queue := healthRes.Checks[0], healthRes.Checks[1]
+		if database.Name != "database" || database.Status != "ok" || len(database.Error) != 0 {
+			t.Fatalf("returned database check '%+v' is not as expected", database)
+		}
+		if queue.Name != "queue" || queue.Status != "failing" || queue.Error != expectedQueueError {
+			t.Fatalf("returned queue check '%+v' is not as expected, redacted: %v", queue, redacted)
+		}
+		if queue.DurationMs < 5 {
+			t.Fatalf("returned queue check duration %vms is shorter than the check itself", queue.DurationMs)
+		}
+	}
+}
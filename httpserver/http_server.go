@@ -4,11 +4,29 @@
 package httpserver
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"mime"
+	"net"
 	"net/http"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/gophersland/citizen/citizen"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/netutil"
 )
 
 const (
@@ -16,110 +34,777 @@ const (
 )
 
 type ReqHandlersDependencies struct {
-	pingRouteResponseMessage string
+	pingRoutePath            string
+	pingRouteResponseMessage func(r *http.Request) string
+	errorSink                ErrorSink
+	routeGroups              []RouteGroup
+	routes                   []Route
+	middlewares              []Middleware
+	inFlightRequests         *InFlightRequests
+	metrics                  Metrics
+	logger                   Logger
+	readinessChecks          map[string]HealthCheck
+	healthFormatter          HealthFormatter
+	redactHealthErrors       bool
+	tracer                   trace.Tracer
+	propagator               propagation.TextMapPropagator
+	citizens                 *citizen.Registry
 }
 
 func NewReqHandlersDependencies(pingRouteResponseMessage string) ReqHandlersDependencies {
 	return ReqHandlersDependencies{
-		pingRouteResponseMessage,
+		pingRoutePath:            pingRoute,
+		pingRouteResponseMessage: staticMessage(pingRouteResponseMessage),
+		errorSink:                NoopErrorSink,
+		logger:                   NewStdLogger(os.Stdout),
+		healthFormatter:          DetailedHealthFormatter,
 	}
 }
 
+// WithPingRoute serves the ping handler on the given path instead of /ping.
+func (deps ReqHandlersDependencies) WithPingRoute(path string) ReqHandlersDependencies {
+	deps.pingRoutePath = path
+	return deps
+}
+
+// WithPingResponseMessage computes the ping response message out of each request.
+func (deps ReqHandlersDependencies) WithPingResponseMessage(message func(r *http.Request) string) ReqHandlersDependencies {
+	deps.pingRouteResponseMessage = message
+	return deps
+}
+
+// WithCitizenRegistry serves the citizen CRUD routes, /citizens and /citizens/{id}, out of the registry.
+// The readiness fails while its storage doesn't answer. Its /citizens/export and /citizens/import routes are
+// served with the admin ones, once Config.WithAdminToken or Config.WithAdminClientCNs is set.
+func (deps ReqHandlersDependencies) WithCitizenRegistry(registry *citizen.Registry) ReqHandlersDependencies {
+	deps.citizens = registry
+	return deps.WithReadinessCheck(citizensStorageCheckName, registry.Ping)
+}
+
+// WithErrorSink reports the handler panics and 5xx responses to the given sink.
+func (deps ReqHandlersDependencies) WithErrorSink(sink ErrorSink) ReqHandlersDependencies {
+	deps.errorSink = sink
+	return deps
+}
+
+// WithRouteGroups mounts additional route groups next to the public routes.
+func (deps ReqHandlersDependencies) WithRouteGroups(groups ...RouteGroup) ReqHandlersDependencies {
+	deps.routeGroups = append(append([]RouteGroup{}, deps.routeGroups...), groups...)
+	return deps
+}
+
+// WithRoutes mounts additional routes next to the public ones, decorated the same way.
+func (deps ReqHandlersDependencies) WithRoutes(routes ...Route) ReqHandlersDependencies {
+	deps.routes = append(append([]Route{}, deps.routes...), routes...)
+	return deps
+}
+
+// WithMiddlewares wraps every request of the server with the middlewares, the first one is the outermost.
+func (deps ReqHandlersDependencies) WithMiddlewares(middlewares ...Middleware) ReqHandlersDependencies {
+	deps.middlewares = append(append([]Middleware{}, deps.middlewares...), middlewares...)
+	return deps
+}
+
+// WithInFlightRequests counts the requests being served into the given counter.
+func (deps ReqHandlersDependencies) WithInFlightRequests(inFlight *InFlightRequests) ReqHandlersDependencies {
+	deps.inFlightRequests = inFlight
+	return deps
+}
+
+// WithMetrics records the requests into the given metrics instead of the Prometheus collectors served on /metrics.
+func (deps ReqHandlersDependencies) WithMetrics(metrics Metrics) ReqHandlersDependencies {
+	deps.metrics = metrics
+	return deps
+}
+
+// WithLogger sends the server messages to the given logger, NoopLogger silences them.
+func (deps ReqHandlersDependencies) WithLogger(logger Logger) ReqHandlersDependencies {
+	deps.logger = logger
+	return deps
+}
+
+// WithTracerProvider starts a span per request out of the given provider, e.g. NewOTLPTracerProvider, and
+// continues the traces of the callers sending the W3C traceparent header.
+func (deps ReqHandlersDependencies) WithTracerProvider(tracerProvider trace.TracerProvider) ReqHandlersDependencies {
+	deps.tracer = tracerProvider.Tracer(tracerName)
+	deps.propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	return deps
+}
+
+// WithReadinessCheck makes /ready answer 503 while the named check fails.
+func (deps ReqHandlersDependencies) WithReadinessCheck(name string, check HealthCheck) ReqHandlersDependencies {
+	checks := map[string]HealthCheck{name: check}
+	for existingName, existingCheck := range deps.readinessChecks {
+		checks[existingName] = existingCheck
+	}
+	deps.readinessChecks = checks
+	return deps
+}
+
+// WithHealthFormatter renders the /health and /ready bodies, DetailedHealthFormatter by default.
+func (deps ReqHandlersDependencies) WithHealthFormatter(formatter HealthFormatter) ReqHandlersDependencies {
+	deps.healthFormatter = formatter
+	return deps
+}
+
+// WithRedactedHealthErrors hides the error details of the failing checks, e.g. in production.
+func (deps ReqHandlersDependencies) WithRedactedHealthErrors() ReqHandlersDependencies {
+	deps.redactHealthErrors = true
+	return deps
+}
+
 type ServeReqs func(ctx context.Context, cfg Config, deps ReqHandlersDependencies) error
 
 var _ ServeReqs = ServeReqsImpl
 
+// RunServerImpl validates the configuration and serves until the ctx is done.
+//
+// Deprecated: use New and Server.Start, a Server can be started and stopped like any other value.
 var RunServerImpl = func(ctx context.Context, cfg Config, serveRequests ServeReqs, deps ReqHandlersDependencies) error {
-	fmt.Println(fmt.Sprintf("Starting GophersLand HTTP server listening on port: %v.", cfg.port))
+	err := cfg.Validate()
+	if err != nil {
+		deps.logger.Error("Invalid server configuration.", "error", err)
+		return fmt.Errorf("invalid server configuration. %s", err.Error())
+	}
+
+	if cfg.listener != nil {
+		deps.logger.Info("Starting GophersLand HTTP server.", "addr", cfg.listener.Addr().String())
+	} else if len(cfg.unixSocketPath) != 0 {
+		deps.logger.Info("Starting GophersLand HTTP server.", "unix_socket", cfg.unixSocketPath)
+	} else {
+		deps.logger.Info("Starting GophersLand HTTP server.", "port", cfg.port)
+	}
 
 	return serveRequests(ctx, cfg, deps)
 }
 
+// ServeReqsImpl serves the requests until the ctx is done, then drains them.
+//
+// Deprecated: use New and Server.Start.
 var ServeReqsImpl = func(ctx context.Context, cfg Config, deps ReqHandlersDependencies) error {
-	http.Handle(pingRoute, decorateHttpRes(pingHandlerImpl(deps.pingRouteResponseMessage), addJsonHeader()))
+	var certManager *autocert.Manager
+	if len(cfg.autocertHosts) != 0 {
+		certManager = newAutocertManager(cfg)
+	}
+
+	var tlsConfig *tls.Config
+	var reloader *certReloader
+	if !cfg.plaintext {
+		var err error
+		if cfg.certReloadOnSIGHUP {
+			reloader, err = newCertReloader(cfg.keyPairFiles())
+			if err != nil {
+				return err
+			}
+			reloader.reloadOnSIGHUP(ctx, deps.logger)
+		}
+
+		tlsConfig, err = buildTLSConfig(cfg, certManager, reloader)
+		if err != nil {
+			return err
+		}
+	}
 
-	server := &http.Server{Addr: fmt.Sprintf(":%d", cfg.port), Handler: nil}
+	if certManager != nil {
+		// Stopped along with the server, even when serving fails on its own, and waited for.
+		acmeCtx, stopAcme := context.WithCancel(ctx)
+		acmeDone := make(chan struct{})
+		go func() {
+			serveAcmeChallenge(acmeCtx, certManager, cfg.drainTimeout, deps.logger)
+			close(acmeDone)
+		}()
+		defer func() {
+			stopAcme()
+			<-acmeDone
+		}()
+	}
 
+	if deps.inFlightRequests == nil {
+		deps = deps.WithInFlightRequests(NewInFlightRequests())
+	}
+	state := newHandlerState(cfg)
+	handler, err := buildHandler(cfg, deps, state)
+	if err != nil {
+		return err
+	}
+	if cfg.misdirectedRequestCheck {
+		leaves, err := certificateLeaves(tlsConfig)
+		if err != nil {
+			return err
+		}
+		currentLeaves := func() []*x509.Certificate { return leaves }
+		if reloader != nil {
+			currentLeaves = reloader.leaves
+		}
+		handler = rejectMisdirected(currentLeaves)(handler)
+	}
+
+	maxHeaderBytes := cfg.maxHeaderBytes
+	if maxHeaderBytes <= 0 {
+		maxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
+
+	// baseCtx outlives ctx through the lame duck period, the requests served meanwhile must not look cancelled.
+	baseCtx, cancelBaseCtx := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelBaseCtx()
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.port),
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		MaxHeaderBytes:    maxHeaderBytes,
+		Protocols:         serverProtocols(cfg),
+		ConnState:         cfg.connState,
+		ReadHeaderTimeout: cfg.serverTimeouts.ReadHeader,
+		ReadTimeout:       cfg.serverTimeouts.Read,
+		WriteTimeout:      cfg.serverTimeouts.Write,
+		IdleTimeout:       cfg.serverTimeouts.Idle,
+		// The requests contexts derive from baseCtx, so the handlers see the drain as a cancellation.
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+	}
+
+	if conns := state.clientCertConns; conns != nil {
+		connState := cfg.connState
+		server.ConnState = func(conn net.Conn, s http.ConnState) {
+			conns.trackConn(conn, s)
+			if connState != nil {
+				connState(conn, s)
+			}
+		}
+		server.ConnContext = withConn
+	}
+
+	if cfg.disableKeepAlives {
+		server.SetKeepAlivesEnabled(false)
+	}
+
+	// The shutdown routine reports back even when serving failed on its own, so no goroutine outlives the call.
+	serveDone := make(chan struct{})
+	shutdownErrs := make(chan error, 1)
 	go func() {
-		<-ctx.Done()
-		fmt.Println("Shutting down the HTTP server...")
-		server.Shutdown(ctx)
+		select {
+		case <-ctx.Done():
+		case <-serveDone:
+			shutdownErrs <- nil
+			return
+		}
+
+		if cfg.lameDuckDuration > 0 {
+			deps.logger.Info("Entering lame duck mode, reporting not ready while still serving.", "duration", cfg.lameDuckDuration)
+			state.lameDuck.Store(true)
+			select {
+			case <-time.After(cfg.lameDuckDuration):
+			case <-serveDone:
+			}
+		}
+
+		deps.logger.Info("Shutting down the HTTP server...")
+		state.shuttingDown.Store(true)
+		cancelBaseCtx()
+		if !state.longLived.drain(cfg.longLivedDrainTimeout) {
+			deps.logger.Error("Long-lived connections were still open after the drain timeout.", "timeout", cfg.longLivedDrainTimeout)
+		}
+
+		// ctx is already done, the drain gets its own deadline.
+		drainCtx, cancel := context.WithTimeout(context.Background(), cfg.drainTimeout)
+		defer cancel()
+		go deps.inFlightRequests.logUntilDrained(deps.logger, inFlightLogInterval, drainCtx.Done())
+
+		err := server.Shutdown(drainCtx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			deps.logger.Error("Requests were still in flight after the drain timeout, closing their connections.", "in_flight", deps.inFlightRequests.InFlight(), "timeout", cfg.drainTimeout)
+			err = errors.Join(ErrDrainTimeout, server.Close())
+		}
+		if err != nil {
+			err = fmt.Errorf("unable to shut down the HTTP server. %w", err)
+		}
+		shutdownErrs <- err
 	}()
 
-	err := server.ListenAndServeTLS(
-		cfg.certificatePemFilePath,
-		cfg.certificatePemPrivKeyFilePath,
-	)
+	listener, err := listen(cfg)
+	if err != nil {
+		close(serveDone)
+		return errors.Join(err, <-shutdownErrs)
+	}
+	stopAdmin := func() error { return nil }
+	if cfg.hasAdminListener() {
+		adminListener, err := listenAdmin(cfg)
+		if err != nil {
+			listener.Close()
+			close(serveDone)
+			return errors.Join(err, <-shutdownErrs)
+		}
+
+		// The admin server keeps answering, e.g. the metrics scrapes, until the public one is drained.
+		adminServer := &http.Server{
+			Handler:           newAdminHandler(cfg, deps, state),
+			MaxHeaderBytes:    maxHeaderBytes,
+			ReadHeaderTimeout: cfg.serverTimeouts.ReadHeader,
+			IdleTimeout:       cfg.serverTimeouts.Idle,
+		}
+		serveAdmin := func() error { return adminServer.Serve(adminListener) }
+		if len(cfg.adminClientCNs) != 0 && tlsConfig != nil {
+			// The client certificates, so the admin client CNs, are only seen over TLS.
+			adminServer.TLSConfig = tlsConfig
+			serveAdmin = func() error { return adminServer.ServeTLS(adminListener, "", "") }
+		}
+		adminErrs := make(chan error, 1)
+		go func() {
+			adminErrs <- serveAdmin()
+		}()
+		stopAdmin = func() error {
+			drainCtx, cancel := context.WithTimeout(context.Background(), cfg.drainTimeout)
+			defer cancel()
+
+			err := adminServer.Shutdown(drainCtx)
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = errors.Join(ErrDrainTimeout, adminServer.Close())
+			}
+			if err != nil {
+				err = fmt.Errorf("unable to shut down the admin HTTP server. %w", err)
+			}
+
+			serveErr := <-adminErrs
+			if serveErr == http.ErrServerClosed {
+				serveErr = nil
+			}
+			if serveErr != nil {
+				serveErr = fmt.Errorf("admin HTTP server failed. %w", serveErr)
+			}
+
+			return errors.Join(serveErr, err)
+		}
+	}
+	if cfg.maxConns > 0 {
+		// Past the limit the connections wait in the accept backlog until one closes.
+		listener = netutil.LimitListener(listener, cfg.maxConns)
+	}
+
+	if cfg.plaintext {
+		err = server.Serve(listener)
+	} else {
+		// The certificates are already loaded into the server TLSConfig.
+		err = server.ServeTLS(listener, "", "")
+	}
+	close(serveDone)
 
 	// Shutting down the server is not something bad ffs Go...
 	if err == http.ErrServerClosed {
-		return nil
+		err = nil
+	}
+
+	// The admin server is drained last, once the public one is.
+	shutdownErr := <-shutdownErrs
+	return errors.Join(err, shutdownErr, stopAdmin())
+}
+
+func newHandler(cfg Config, deps ReqHandlersDependencies) http.Handler {
+	return newHandlerWithState(cfg, deps, newHandlerState(cfg))
+}
+
+// buildHandler is newHandlerWithState reporting the routes http.ServeMux panics on, e.g. two patterns
+// matching the same requests, as an error.
+func buildHandler(cfg Config, deps ReqHandlersDependencies, state *handlerState) (handler http.Handler, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("invalid routes. %v", recovered)
+		}
+	}()
+
+	return newHandlerWithState(cfg, deps, state), nil
+}
+
+// Every server gets its own mux and state so multiple servers can live in the same process.
+func newHandlerWithState(cfg Config, deps ReqHandlersDependencies, state *handlerState) http.Handler {
+	if cfg.lameDuckDuration > 0 {
+		deps = deps.WithReadinessCheck(lameDuckCheckName, lameDuckCheck(&state.lameDuck))
+	}
+	mux := http.NewServeMux()
+
+	groups := append([]RouteGroup{{Routes: publicRoutes(deps)}}, deps.routeGroups...)
+	for _, group := range groups {
+		notFound, methodNotAllowed := group.NotFound, group.MethodNotAllowed
+		if notFound == nil {
+			notFound = notFoundHandler()
+		}
+		if methodNotAllowed == nil {
+			methodNotAllowed = methodNotAllowedHandler()
+		}
+
+		for _, route := range group.Routes {
+			route.Path = group.Prefix + route.Path
+			decorators := append(routeDecorators(cfg, deps, route, state), allowMethods(route.Methods, methodNotAllowed))
+			mux.Handle(route.Path, decorateHttpRes(route.Handler, decorators...))
+		}
+		mux.Handle(group.Prefix+"/", decorateHttpRes(notFound, addJsonHeader(cfg.jsonCharset)))
+	}
+	if !cfg.hasAdminListener() {
+		registerAdminRoutes(mux, cfg, deps, state)
+	}
+
+	var handler http.Handler = mux
+	if cfg.maxRedirects > 0 {
+		handler = limitRedirects(cfg.maxRedirects)(handler)
 	}
+	if deps.inFlightRequests != nil {
+		handler = deps.inFlightRequests.track()(handler)
+	}
+	handler = rejectWhileShuttingDown(&state.shuttingDown, cfg.drainTimeout)(handler)
+	if cfg.extensionNegotiation {
+		handler = negotiateByExtension()(handler)
+	}
+	if cfg.methodOverride {
+		handler = methodOverride(defaultOverridableMethods...)(handler)
+	}
+	if cfg.accessLog != nil {
+		handler = accessLog(cfg.accessLog, time.Now, cfg.redactedQueryParams)(handler)
+	}
+	handler = NewChain(deps.middlewares...).Then(handler)
+	// Inside the request log so a recovered panic is logged as a 500.
+	handler = RecoveryMiddleware(deps.logger)(handler)
+	if !cfg.disableRequestLog {
+		// Inside realIP so the logged remote address is the client one.
+		handler = NewChain(RequestIDMiddleware(), logRequests(deps.logger, cfg.redactedQueryParams)).Then(handler)
+	}
+	if len(cfg.trustedProxies) != 0 {
+		handler = realIP(cfg.trustedProxies)(handler)
+	}
+
+	return handler
+}
 
-	return err
+// newAdminHandler serves the admin routes of the admin listener, away from the public ones.
+func newAdminHandler(cfg Config, deps ReqHandlersDependencies, state *handlerState) http.Handler {
+	mux := http.NewServeMux()
+	registerAdminRoutes(mux, cfg, deps, state)
+
+	return mux
+}
+
+// registerAdminRoutes mounts the metrics, admin, citizen export and import, and debug routes. They share the
+// admin auth, /metrics stays public until it is configured.
+func registerAdminRoutes(mux *http.ServeMux, cfg Config, deps ReqHandlersDependencies, state *handlerState) {
+	adminChain := NewChain()
+	if cfg.serverTiming {
+		adminChain = adminChain.Append(serverTiming())
+	}
+	if cfg.hasAdminAuth() {
+		if state.failedAuths != nil {
+			adminChain = adminChain.Append(authLockout(state.failedAuths))
+		}
+		adminChain = adminChain.Append(requireAdmin(cfg.adminToken, cfg.adminClientCNs))
+	}
+	if len(cfg.metricsRoute) != 0 {
+		mux.Handle(cfg.metricsRoute, adminChain.Then(promhttp.HandlerFor(state.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})))
+	}
+	if cfg.hasAdminAuth() {
+		mux.Handle(decoratorParamsRoute, NewChain(addJsonHeader(cfg.jsonCharset)).Append(adminChain.decorators...).Then(decoratorParamsHandler(state.params)))
+	}
+	if cfg.hasAdminAuth() && deps.citizens != nil {
+		for _, route := range citizenAdminRoutes(deps.citizens, deps.logger) {
+			mux.Handle(route.Path, adminChain.Append(allowMethods(route.Methods, methodNotAllowedHandler())).Then(route.Handler))
+		}
+	}
+	if cfg.pprof {
+		registerPprof(mux, adminChain)
+	}
+}
+
+// handlerState is shared by the routes of a single server.
+type handlerState struct {
+	params      *liveParams
+	rateLimiter *rateLimiter
+	registry    *prometheus.Registry
+	metrics     Metrics
+	longLived   *longLivedConns
+	// failedAuths is only set when the auth lockout is enabled.
+	failedAuths *failedAuthStore
+	// fairQueue is only set when the fair queueing is enabled.
+	fairQueue *weightedAdmission
+	// clientCertConns is only set when the connections per client certificate are limited.
+	clientCertConns *clientCertConns
+	lameDuck        atomic.Bool
+	shuttingDown    atomic.Bool
+}
+
+func newHandlerState(cfg Config) *handlerState {
+	registry := prometheus.NewRegistry()
+
+	params := newLiveParams(cfg.decoratorParams)
+	state := &handlerState{
+		params:      params,
+		rateLimiter: newRateLimiter(params, time.Now),
+		registry:    registry,
+		metrics:     newPrometheusMetrics(registry, cfg.latencyBuckets),
+		longLived:   newLongLivedConns(),
+	}
+	if len(cfg.metricsRoute) != 0 {
+		registerProcessMetrics(registry)
+	}
+	if cfg.authLockoutMaxFailures > 0 {
+		state.failedAuths = newFailedAuthStore(cfg.authLockoutMaxFailures, cfg.authLockoutCooldown, time.Now)
+	}
+	if cfg.fairQueueCapacity > 0 {
+		state.fairQueue = newWeightedAdmission(cfg.fairQueueCapacity, cfg.fairQueueWeights)
+	}
+	if cfg.maxConnsPerClientCert > 0 {
+		state.clientCertConns = newClientCertConns(cfg.maxConnsPerClientCert)
+	}
+
+	return state
+}
+
+// The decorators are listed outermost first, addJsonHeader leads so even the error responses of the
+// decorators after it are JSON.
+func routeDecorators(cfg Config, deps ReqHandlersDependencies, route Route, state *handlerState) []httpResDecorator {
+	metrics := state.metrics
+	if deps.metrics != nil {
+		metrics = deps.metrics
+	}
+
+	decorators := []httpResDecorator{addJsonHeader(cfg.jsonCharset)}
+	if deps.tracer != nil {
+		decorators = append(decorators, traceRequests(deps.tracer, deps.propagator, route.Path, cfg.redactedQueryParams))
+	}
+	decorators = append(decorators, instrument(metrics, route.Path))
+	if cfg.prettyJSON {
+		decorators = append(decorators, prettyJSON())
+	}
+	if cfg.responseCompression {
+		decorators = append(decorators, compressResponse())
+	}
+	decorators = append(decorators, errorReporter(deps.errorSink, deps.logger, cfg.redactedQueryParams))
+	if len(cfg.allowedProtocolVersions) != 0 {
+		// Validate already rejected the unparsable versions.
+		versions, _ := parseProtocolVersions(cfg.allowedProtocolVersions)
+		decorators = append(decorators, allowProtocolVersions(versions))
+	}
+	if route.Deprecated {
+		decorators = append(decorators, deprecation(route, deps.logger))
+	}
+	if cfg.serverTiming {
+		decorators = append(decorators, serverTiming())
+	}
+	// Ahead of the auth, the preflights carry no credentials.
+	if len(cfg.corsAllowedOrigins) != 0 {
+		decorators = append(decorators, cors(cfg.corsAllowedOrigins, cfg.corsMaxAge, route.Methods))
+	}
+	if route.MaxAuthAge > 0 {
+		// Only the routes authenticating their callers lock them out.
+		if state.failedAuths != nil {
+			decorators = append(decorators, authLockout(state.failedAuths))
+		}
+		decorators = append(decorators, requireFreshAuth(route.MaxAuthAge, time.Now))
+	}
+	if cfg.lenientJSON {
+		decorators = append(decorators, lenientDecoding())
+	}
+	if cfg.hstsMaxAge > 0 {
+		decorators = append(decorators, hsts(cfg.hstsMaxAge, cfg.hstsIncludeSubdomains, cfg.hstsPreload))
+	}
+	if cfg.securityHeaders {
+		decorators = append(decorators, securityHeaders(cfg.contentSecurityPolicy))
+	}
+	if state.clientCertConns != nil {
+		decorators = append(decorators, limitConnsPerClientCert(state.clientCertConns))
+	}
+	decorators = append(decorators, rateLimit(state.rateLimiter))
+	if state.fairQueue != nil {
+		decorators = append(decorators, fairQueue(state.fairQueue, requestTenant))
+	}
+	decorators = append(decorators, limitRequestBody(state.params))
+	if cfg.maxDecompressionRatio > 0 {
+		decorators = append(decorators, decompressRequest(cfg.maxDecompressionRatio, state.params))
+	}
+	if route.EncryptedPayload {
+		decorators = append(decorators, decryptJWE(cfg.jwePrivateKey))
+	}
+	if cfg.strictContentType {
+		decorators = append(decorators, requireContentType("application/json"))
+	}
+	if route.Debounce != nil {
+		decorators = append(decorators, debounceBatch(route.Debounce.Window, route.Debounce.Key, route.Debounce.Process))
+	}
+	if timeout := cfg.routeTimeouts[route.Path]; timeout > 0 {
+		decorators = append(decorators, withTimeout(timeout, cfg.jsonCharset))
+	} else if !route.LongLived {
+		decorators = append(decorators, withLiveTimeout(state.params, cfg.jsonCharset))
+	}
+	if route.DownstreamSLA > 0 {
+		decorators = append(decorators, enforceDownstreamSLA(route.DownstreamSLA, deps.logger, metrics, route.Path))
+	}
+	if route.Cacheable {
+		decorators = append(decorators, etag())
+	}
+	if route.LongLived {
+		decorators = append(decorators, state.longLived.track())
+	}
+	if route.BufferResponse {
+		decorators = append(decorators, bufferResponse())
+	}
+
+	return decorators
 }
 
 func pingHandlerImpl(pingRouteResponseMessage string) http.Handler {
+	return pingHandler(staticMessage(pingRouteResponseMessage), NoopLogger)
+}
+
+// pingHandler echoes the value of a POST JSON body or, for the load balancer checks and the curl
+// one-liners, of a GET /ping?value=... query with the same response.
+func pingHandler(pingRouteResponseMessage func(r *http.Request) string, logger Logger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		pingReq := pingReq{}
 		err := readRequest(r, &pingReq)
-		if err != nil {
-			writeResponse(w, pingRes{"", err.Error()}, http.StatusBadRequest)
+		if isClientAborted(err) {
+			// Nobody is left to read the response.
+			logWriteErr(logger, r, err)
 			return
 		}
-
-		if len(pingReq.Value) == 0 {
-			writeResponse(w, pingRes{"", fmt.Sprintf("ping request value must be at least 1 char")}, http.StatusBadRequest)
+		if err != nil {
+			logWriteErr(logger, r, writeNegotiatedError(w, r, readRequestErrCode(err), err))
 			return
 		}
 
-		writeResponse(w, pingRes{fmt.Sprintf("request: %s; response: %s", pingReq.Value, pingRouteResponseMessage), ""}, http.StatusOK)
+		logWriteErr(logger, r, writeNegotiated(w, r, pingRes{fmt.Sprintf("request: %s; response: %s", pingReq.Value, pingRouteResponseMessage(r))}, http.StatusOK))
 	})
 }
 
-type httpResDecorator func(http.Handler) http.Handler
+func staticMessage(message string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return message
+	}
+}
+
+type httpResDecorator = Middleware
 
+// decorateHttpRes wraps the handler so the decorators run in the listed order, the first one is the outermost.
 func decorateHttpRes(handler http.Handler, decorators ...httpResDecorator) http.Handler {
-	for _, decorator := range decorators {
-		handler = decorator(handler)
+	for i := len(decorators) - 1; i >= 0; i-- {
+		handler = decorators[i](handler)
 	}
 
 	return handler
 }
 
-func addJsonHeader() httpResDecorator {
+// addJsonHeader sets the JSON Content-Type, with the given charset parameter unless empty.
+func addJsonHeader(charset string) httpResDecorator {
+	contentType := jsonContentType(charset)
+
 	return func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Type", contentType)
 			handler.ServeHTTP(w, r)
 		})
 	}
 }
 
+// jsonContentType is the Content-Type of the JSON responses, with the charset of Config.WithJSONCharset.
+func jsonContentType(charset string) string {
+	if len(charset) == 0 {
+		return "application/json"
+	}
+
+	return "application/json; charset=" + charset
+}
+
 func readRequest(r *http.Request, reqBody interface{}) error {
+	defer timePhase(r.Context(), "decode")()
+
 	reqBodyJson, err := ioutil.ReadAll(r.Body)
 	defer r.Body.Close()
 	if err != nil {
-		return fmt.Errorf("unable to read request body. %s", err.Error())
+		if ctxErr := r.Context().Err(); ctxErr != nil {
+			err = errors.Join(err, ctxErr)
+		}
+		return fmt.Errorf("unable to read request body. %w", err)
 	}
 
-	err = json.Unmarshal(reqBodyJson, reqBody)
-	if err != nil {
-		return fmt.Errorf("unable to unmarshal request body. %s", err.Error())
+	if isStructPtr(reqBody) && isQueryRequest(r, reqBodyJson) {
+		err = decodeQuery(r.URL.Query(), reqBody)
+		if err != nil {
+			return err
+		}
+	} else {
+		if isStructPtr(reqBody) && !isJsonObject(reqBodyJson) {
+			return fmt.Errorf("request body must be a non-empty JSON object")
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(reqBodyJson))
+		if !requestDecodeOptions(r).allowUnknownFields {
+			decoder.DisallowUnknownFields()
+		}
+
+		err = decoder.Decode(reqBody)
+		if err != nil {
+			return newRequestDecodeError(err, reqBodyJson)
+		}
+	}
+
+	if v, ok := reqBody.(validator); ok {
+		err = v.Validate()
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func writeResponse(w http.ResponseWriter, res interface{}, statusCode int) {
-	jsonRes, jsonMarshalErr := json.Marshal(res)
-	if jsonMarshalErr != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("unable to marshal response. %s", jsonMarshalErr.Error())))
-		return
+// readRequestErrCode is a 499 for clients gone while sending, a 413 for bodies cut off by http.MaxBytesReader and a 400 for anything else.
+func readRequestErrCode(err error) ErrorCode {
+	if isClientAborted(err) {
+		return CodeClientClosedRequest
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return CodePayloadTooLarge
+	}
+
+	return CodeInvalidRequest
+}
+
+// isQueryRequest tells the requests carrying their input in the query string, a GET or a bodyless request with query parameters.
+func isQueryRequest(r *http.Request, body []byte) bool {
+	return r.Method == http.MethodGet || (len(bytes.TrimSpace(body)) == 0 && len(r.URL.RawQuery) != 0)
+}
+
+func isStructPtr(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+}
+
+func isJsonObject(body []byte) bool {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) != 0 && body[0] == '{'
+}
+
+func writeResponse(w http.ResponseWriter, res interface{}, statusCode int) error {
+	return writeResponseAs(w, formatJson, res, statusCode)
+}
+
+// writeResponseAs reports the marshal and write errors, a response failing to marshal is replaced by
+// a 500 error in the same format so the body still matches the Content-Type.
+func writeResponseAs(w http.ResponseWriter, format responseFormat, res interface{}, statusCode int) error {
+	indentJSON := wantsIndentedJSON(w)
+	encodedRes, contentType, marshalErr := marshalResponse(format, res, indentJSON)
+	if marshalErr != nil {
+		marshalErr = fmt.Errorf("unable to marshal response. %w", marshalErr)
+		encodedRes, contentType, _ = marshalResponse(format, newErrorRes(w, CodeInternal, marshalErr), indentJSON)
+		statusCode = http.StatusInternalServerError
 	}
 
+	// A Content-Type already set for the same media type, e.g. by addJsonHeader, keeps its parameters.
+	if existing, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); existing != contentType {
+		w.Header().Set("Content-Type", contentType)
+	}
 	w.WriteHeader(statusCode)
-	w.Write(jsonRes)
-	w.Write([]byte("\n"))
+	_, err := w.Write(append(encodedRes, '\n'))
+	if err != nil {
+		return errors.Join(marshalErr, fmt.Errorf("unable to write response. %w", err))
+	}
+
+	return marshalErr
 }
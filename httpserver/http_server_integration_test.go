@@ -1,78 +1,174 @@
 package httpserver
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
 func TestHttpServerLifeCycle(t *testing.T) {
-	ctx, closeServer := context.WithCancel(context.Background())
-	cfg := NewConfig(
-		9093,
-		fmt.Sprintf("%s/src/github.com/gophersland/citizen/httpserver/localhost.crt", os.Getenv("GOPATH")),
-		fmt.Sprintf("%s/src/github.com/gophersland/citizen/httpserver/localhost.key", os.Getenv("GOPATH")),
-	)
+	t.Parallel()
+	testCases := []struct {
+		name string
+		cfg  Config
+	}{
+		{"HTTPS", newTestConfig(0)},
+		{"HTTP", NewConfig(0, "", "").WithPlaintext()},
+	}
 
-	go func() {
-		reqHandlersDependencies := NewReqHandlersDependencies("test pong")
-		err := RunServerImpl(ctx, cfg, ServeReqsImpl, reqHandlersDependencies)
+	for _, testCase := range testCases {
+		baseURL, cleanup := newTestServer(t, testCase.cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+
+		resp, err := newHttpClient().Post(baseURL+pingRoute, "application/json", createPingReq())
 		if err != nil {
+			cleanup()
 			t.Fatal(err)
 		}
-	}()
 
-	time.Sleep(time.Second * 2)
+		var pingRes pingRes
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		cleanup()
+		err = json.Unmarshal(body, &pingRes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(pingRes.Message) == 0 {
+			t.Fatalf("%v: returned response is not suppose to be empty", testCase.name)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%v: returned response code '%v' is not as expected one '%v'", testCase.name, resp.StatusCode, http.StatusOK)
+		}
+
+		if resp.Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("%v: returned response header '%v' is not '%v'", testCase.name, resp.Header.Get("Content-Type"), "application/json")
+		}
+
+		if (resp.TLS != nil) != !testCase.cfg.plaintext {
+			t.Fatalf("%v: response is not served over the expected scheme", testCase.name)
+		}
+	}
+}
+
+func TestPingOverGetAndPost(t *testing.T) {
+	t.Parallel()
+	baseURL, cleanup := newTestServer(t, newTestConfig(0), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	defer cleanup()
 
-	req, err := http.NewRequest("POST", createURL(cfg, pingRoute), createPingReq())
+	getResp, err := newHttpClient().Get(baseURL + pingRoute + "?value=test+ping+value")
 	if err != nil {
-		closeServer()
 		t.Fatal(err)
 	}
+	getBody, _ := ioutil.ReadAll(getResp.Body)
+	getResp.Body.Close()
 
-	resp, err := newHttpClient().Do(req)
+	postResp, err := newHttpClient().Post(baseURL+pingRoute, "application/json", createPingReq())
 	if err != nil {
-		closeServer()
 		t.Fatal(err)
 	}
-	defer resp.Body.Close()
+	postBody, _ := ioutil.ReadAll(postResp.Body)
+	postResp.Body.Close()
 
-	var pingRes pingRes
-	body, _ := ioutil.ReadAll(resp.Body)
-	err = json.Unmarshal(body, &pingRes)
+	if getResp.StatusCode != http.StatusOK || postResp.StatusCode != http.StatusOK {
+		t.Fatalf("returned response codes '%v' and '%v' are not as expected one '%v'", getResp.StatusCode, postResp.StatusCode, http.StatusOK)
+	}
+	if !bytes.Equal(getBody, postBody) {
+		t.Fatalf("GET response '%s' is not the same as the POST one '%s'", getBody, postBody)
+	}
+}
+
+// newTestServer serves on an ephemeral port, or on the cfg unix socket, so the tests using it can run in
+// parallel, and returns once the server completes the handshakes. The cleanup shuts the server down and waits for it.
+func newTestServer(t *testing.T, cfg Config, deps ReqHandlersDependencies) (baseURL string, cleanup func()) {
+	if len(cfg.unixSocketPath) == 0 {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg = cfg.WithListener(listener)
+	}
+
+	server, err := New(cfg, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.Start(context.Background())
 	if err != nil {
-		closeServer()
 		t.Fatal(err)
 	}
 
-	if len(pingRes.Error) != 0 {
-		closeServer()
-		t.Fatal(pingRes.Error)
+	addr := server.Addr()
+	host := addr.String()
+	if addr.Network() == "unix" {
+		host = "localhost"
 	}
+	baseURL = "https://" + host
+	if cfg.plaintext {
+		baseURL = "http://" + host
+	}
+
+	for start := time.Now(); !answers(addr, cfg.plaintext); time.Sleep(10 * time.Millisecond) {
+		select {
+		case <-server.Done():
+			t.Fatalf("server stopped before accepting connections. %v", server.Shutdown())
+		default:
+		}
 
-	if len(pingRes.Message) == 0 {
-		closeServer()
-		t.Fatal("returned response is not suppose to be empty")
+		if time.Since(start) > 5*time.Second {
+			server.Shutdown()
+			t.Fatalf("server is not answering on %s", addr)
+		}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		closeServer()
-		t.Fatalf("returned response code '%v' is not as expected one '%v'", resp.StatusCode, http.StatusOK)
+	return baseURL, func() {
+		if err := server.Shutdown(); err != nil {
+			t.Error(err)
+		}
 	}
+}
 
-	if resp.Header.Get("Content-Type") != "application/json" {
-		closeServer()
-		t.Fatalf("returned response header '%v' is not '%v'", resp.Header.Get("Content-Type"), "application/json")
+// answers tells whether the server completes a TLS handshake, or answers a plaintext request, without
+// needing a client certificate or holding a slot of the connection limit past the call.
+func answers(addr net.Addr, plaintext bool) bool {
+	conn, err := net.DialTimeout(addr.Network(), addr.String(), time.Second)
+	if err != nil {
+		return false
 	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
 
-	closeServer()
+	if !plaintext {
+		return tls.Client(conn, &tls.Config{InsecureSkipVerify: true}).Handshake() == nil
+	}
+
+	req, err := http.NewRequest("GET", "http://localhost"+healthRoute, nil)
+	if err != nil {
+		return false
+	}
+	req.Close = true
+	err = req.Write(conn)
+	if err != nil {
+		return false
+	}
+	res, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+
+	return true
 }
 
 func createPingReq() *bytes.Reader {
@@ -80,13 +176,144 @@ func createPingReq() *bytes.Reader {
 	return bytes.NewReader(reqBodyJson)
 }
 
-func createURL(cfg Config, route string) string {
-	return fmt.Sprintf("https://%s:%d%s", "localhost", cfg.port, route)
-}
-
 func newHttpClient() *http.Client {
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
 	return &http.Client{Transport: tr}
 }
+
+func newTestConfig(port int) Config {
+	return NewConfig(port, "localhost.crt", "localhost.key")
+}
+
+func TestMaxHeaderBytes(t *testing.T) {
+	baseURL, cleanup := newTestServer(t, newTestConfig(0).WithMaxHeaderBytes(1024), NewReqHandlersDependencies("test pong"))
+	defer cleanup()
+
+	// net/http tolerates 4096 bytes on top of MaxHeaderBytes.
+	req, err := http.NewRequest("POST", baseURL+pingRoute, createPingReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Oversized", strings.Repeat("a", 8*1024))
+
+	resp, err := newHttpClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", resp.StatusCode, http.StatusRequestHeaderFieldsTooLarge)
+	}
+
+	resp, err = newHttpClient().Post(baseURL+pingRoute, "application/json", createPingReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestServerContextPropagatesIntoHandlers(t *testing.T) {
+	entered, unblocked := make(chan struct{}), make(chan struct{})
+	group := RouteGroup{Prefix: "/blocking", Routes: []Route{{Path: pingRoute, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-r.Context().Done()
+		close(unblocked)
+	})}}}
+	baseURL, cleanup := newTestServer(t, newTestConfig(0), NewReqHandlersDependencies("test pong").WithRouteGroups(group).WithLogger(NoopLogger))
+
+	go newHttpClient().Post(baseURL+"/blocking"+pingRoute, "application/json", nil)
+	<-entered
+
+	closed := make(chan struct{})
+	go func() {
+		cleanup()
+		close(closed)
+	}()
+
+	select {
+	case <-unblocked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler blocked on its request context was not unblocked by the shutdown")
+	}
+	<-closed
+}
+
+func TestKeepAlivesDisabled(t *testing.T) {
+	var newConns int64
+	cfg := newTestConfig(0).WithKeepAlives(false).WithConnState(func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&newConns, 1)
+		}
+	})
+	baseURL, cleanup := newTestServer(t, cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	defer cleanup()
+
+	client := newHttpClient()
+	for i := 0; i < 2; i++ {
+		res, err := client.Post(baseURL+pingRoute, "application/json", createPingReq())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		// net/http consumes the "Connection: close" header into res.Close.
+		if !res.Close {
+			t.Fatal("returned response is missing the 'Connection: close' header")
+		}
+	}
+
+	// newTestServer's readiness probe opens one connection of its own.
+	if count := atomic.LoadInt64(&newConns); count != 3 {
+		t.Fatalf("server saw %v new connections instead of %v", count, 3)
+	}
+}
+
+func TestServerIsClosedOnceRunReturns(t *testing.T) {
+	baseURL, cleanup := newTestServer(t, newTestConfig(0), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+
+	client := newHttpClient()
+	res, err := client.Post(baseURL+pingRoute, "application/json", createPingReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	cleanup()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(baseURL, "https://"))
+	if err == nil {
+		conn.Close()
+		t.Fatal("server is still accepting connections after its shutdown")
+	}
+}
+
+func TestServeErrorDoesNotWaitForCancellation(t *testing.T) {
+	occupied, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer occupied.Close()
+	port := occupied.Addr().(*net.TCPAddr).Port
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- ServeReqsImpl(context.Background(), newTestConfig(port), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	}()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrAddrInUse) {
+			t.Fatalf("returned error '%v' is not as expected one '%v'", err, ErrAddrInUse)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ServeReqsImpl didn't return after failing to listen")
+	}
+}
@@ -0,0 +1,209 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPingRejectsNonObjectJson(t *testing.T) {
+	for _, body := range []string{`["test ping value"]`, `42`, `"test ping value"`, ``} {
+		res := httptest.NewRecorder()
+		pingHandlerImpl("test pong").ServeHTTP(res, httptest.NewRequest("POST", pingRoute, strings.NewReader(body)))
+
+		if res.Code != http.StatusBadRequest {
+			t.Fatalf("body '%v' returned response code '%v', expected '%v'", body, res.Code, http.StatusBadRequest)
+		}
+
+		var errRes errorRes
+		err := json.Unmarshal(res.Body.Bytes(), &errRes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if errRes.Error.Message != "request body must be a non-empty JSON object" {
+			t.Fatalf("body '%v' returned error '%v' which is not as expected", body, errRes.Error.Message)
+		}
+	}
+}
+
+func TestDecorateHttpResOrder(t *testing.T) {
+	var sequence []string
+	recordingDecorator := func(name string) httpResDecorator {
+		return func(handler http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sequence = append(sequence, name+" before")
+				handler.ServeHTTP(w, r)
+				sequence = append(sequence, name+" after")
+			})
+		}
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sequence = append(sequence, "handler")
+	})
+
+	decorateHttpRes(handler, recordingDecorator("logging"), recordingDecorator("auth"), recordingDecorator("json")).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", pingRoute, nil))
+
+	expected := []string{"logging before", "auth before", "json before", "handler", "json after", "auth after", "logging after"}
+	if strings.Join(sequence, ", ") != strings.Join(expected, ", ") {
+		t.Fatalf("decorators ran in order '%v', expected '%v'", sequence, expected)
+	}
+}
+
+func TestPingRejectsUnknownFields(t *testing.T) {
+	body := `{"value": "test ping value", "unexpected": true}`
+
+	res := httptest.NewRecorder()
+	pingHandlerImpl("test pong").ServeHTTP(res, httptest.NewRequest("POST", pingRoute, strings.NewReader(body)))
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusBadRequest)
+	}
+
+	if !strings.Contains(res.Body.String(), `unknown field \"unexpected\"`) {
+		t.Fatalf("returned response '%v' does not name the unknown field", res.Body.String())
+	}
+
+	res = httptest.NewRecorder()
+	decorateHttpRes(pingHandlerImpl("test pong"), lenientDecoding()).ServeHTTP(res, httptest.NewRequest("POST", pingRoute, strings.NewReader(body)))
+	if res.Code != http.StatusOK {
+		t.Fatalf("lenient decoding returned response code '%v', expected '%v'", res.Code, http.StatusOK)
+	}
+}
+
+func TestPingValidatesRequest(t *testing.T) {
+	res := httptest.NewRecorder()
+	pingHandlerImpl("test pong").ServeHTTP(res, httptest.NewRequest("POST", pingRoute, strings.NewReader(`{"value": ""}`)))
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusBadRequest)
+	}
+
+	var errRes errorRes
+	err := json.Unmarshal(res.Body.Bytes(), &errRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errRes.Error.Message != "ping request value must be at least 1 char" {
+		t.Fatalf("returned error '%v' is not the validation error", errRes.Error.Message)
+	}
+}
+
+func TestWriteResponseMarshalError(t *testing.T) {
+	res := httptest.NewRecorder()
+	err := writeResponse(res, map[string]interface{}{"callback": func() {}}, http.StatusOK)
+
+	if err == nil {
+		t.Fatal("writing a response that can't be marshaled is supposed to fail")
+	}
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusInternalServerError)
+	}
+	if res.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("returned Content-Type '%v' is not as expected one 'application/json'", res.Header().Get("Content-Type"))
+	}
+
+	errRes := errorRes{}
+	err = json.Unmarshal(res.Body.Bytes(), &errRes)
+	if err != nil {
+		t.Fatalf("returned body '%v' is not JSON. %v", res.Body.String(), err)
+	}
+}
+
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestWriteResponseWriteError(t *testing.T) {
+	err := writeResponse(failingWriter{httptest.NewRecorder()}, pingRes{"pong"}, http.StatusOK)
+
+	if err == nil || !strings.Contains(err.Error(), "connection reset by peer") {
+		t.Fatalf("returned error '%v' does not report the failed write", err)
+	}
+}
+
+func TestPingReadsQueryAndBodyAlike(t *testing.T) {
+	testCases := []struct {
+		name string
+		req  *http.Request
+	}{
+		{"GET with query", httptest.NewRequest("GET", pingRoute+"?value=test+ping+value", nil)},
+		{"POST with body", httptest.NewRequest("POST", pingRoute, createPingReq())},
+	}
+
+	for _, testCase := range testCases {
+		res := httptest.NewRecorder()
+		pingHandlerImpl("test pong").ServeHTTP(res, testCase.req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("%v returned response code '%v', expected '%v'", testCase.name, res.Code, http.StatusOK)
+		}
+
+		var pingRes pingRes
+		err := json.Unmarshal(res.Body.Bytes(), &pingRes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pingRes.Message != "request: test ping value; response: test pong" {
+			t.Fatalf("%v returned message '%v' which is not as expected", testCase.name, pingRes.Message)
+		}
+	}
+}
+
+func TestPingValidatesQuery(t *testing.T) {
+	res := httptest.NewRecorder()
+	pingHandlerImpl("test pong").ServeHTTP(res, httptest.NewRequest("GET", pingRoute+"?value=", nil))
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusBadRequest)
+	}
+
+	var errRes errorRes
+	err := json.Unmarshal(res.Body.Bytes(), &errRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errRes.Error.Message != "ping request value must be at least 1 char" {
+		t.Fatalf("returned error '%v' is not as expected", errRes.Error.Message)
+	}
+}
+
+func TestPingLocatesMalformedJson(t *testing.T) {
+	testCases := []struct {
+		body          string
+		expectedError string
+	}{
+		{`{"value": "test ping value"`, "unable to unmarshal request body. truncated JSON, at byte offset 27"},
+		{`{"value": "test" x}`, "invalid character 'x' after object key:value pair, at byte offset 18"},
+		{`{"value": 42}`, "unable to unmarshal request body. field 'value' must be a string, got number, at byte offset 12"},
+	}
+
+	for _, testCase := range testCases {
+		res := httptest.NewRecorder()
+		pingHandlerImpl("test pong").ServeHTTP(res, httptest.NewRequest("POST", pingRoute, strings.NewReader(testCase.body)))
+
+		if res.Code != http.StatusBadRequest {
+			t.Fatalf("body '%v' returned response code '%v', expected '%v'", testCase.body, res.Code, http.StatusBadRequest)
+		}
+
+		var errRes errorRes
+		err := json.Unmarshal(res.Body.Bytes(), &errRes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(errRes.Error.Message, testCase.expectedError) {
+			t.Fatalf("body '%v' returned error '%v' which does not contain '%v'", testCase.body, errRes.Error.Message, testCase.expectedError)
+		}
+	}
+}
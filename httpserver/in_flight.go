@@ -0,0 +1,97 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDrainTimeout = 10 * time.Second
+	inFlightLogInterval = time.Second
+)
+
+// InFlightRequests counts the requests being served, pass it to the server with
+// ReqHandlersDependencies.WithInFlightRequests to watch a shutdown drain.
+type InFlightRequests struct {
+	count int64
+}
+
+func NewInFlightRequests() *InFlightRequests {
+	return &InFlightRequests{}
+}
+
+func (inFlight *InFlightRequests) InFlight() int64 {
+	return atomic.LoadInt64(&inFlight.count)
+}
+
+func (inFlight *InFlightRequests) track() httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&inFlight.count, 1)
+			defer atomic.AddInt64(&inFlight.count, -1)
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// logUntilDrained prints the in-flight requests count every interval until it drops to zero or done is closed.
+func (inFlight *InFlightRequests) logUntilDrained(logger Logger, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if inFlight.InFlight() == 0 {
+				return
+			}
+			logger.Info("Waiting for the in-flight requests to complete...", "in_flight", inFlight.InFlight())
+		}
+	}
+}
+
+// rejectWhileShuttingDown answers the requests arriving once the shutdown began with 503, so load balancers
+// stop sending traffic, while the in-flight ones complete.
+func rejectWhileShuttingDown(shuttingDown *atomic.Bool, retryAfter time.Duration) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !shuttingDown.Load() {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			w.Header().Set("Connection", "close")
+			WriteError(w, CodeUnavailable, errors.New("server is shutting down"))
+		})
+	}
+}
+
+// ErrDrainTimeout is returned by the shutdown when requests were still in flight after the drain timeout,
+// their connections got closed.
+var ErrDrainTimeout = errors.New("requests were still in flight after the drain timeout")
+
+const lameDuckCheckName = "lame_duck"
+
+var errLameDuck = errors.New("server is shutting down")
+
+// lameDuckCheck fails the readiness once the server entered the lame duck mode.
+func lameDuckCheck(lameDuck *atomic.Bool) HealthCheck {
+	return func(ctx context.Context) error {
+		if lameDuck.Load() {
+			return errLameDuck
+		}
+		return nil
+	}
+}
@@ -0,0 +1,141 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInFlightRequestsCount(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	group := RouteGroup{Prefix: "/slow", Routes: []Route{{Path: pingRoute, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})}}}
+	inFlight := NewInFlightRequests()
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithRouteGroups(group).WithInFlightRequests(inFlight))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/slow"+pingRoute, nil))
+	}()
+
+	<-entered
+	if inFlight.InFlight() != 1 {
+		t.Fatalf("in-flight requests count '%v' is not as expected one '%v'", inFlight.InFlight(), 1)
+	}
+
+	close(release)
+	<-done
+	if inFlight.InFlight() != 0 {
+		t.Fatalf("in-flight requests count '%v' is not as expected one '%v'", inFlight.InFlight(), 0)
+	}
+}
+
+func TestShutdownDrainIsBounded(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	group := RouteGroup{Prefix: "/slow", Routes: []Route{{Path: pingRoute, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})}}}
+	inFlight := NewInFlightRequests()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := newTestConfig(9093).WithDrainTimeout(200 * time.Millisecond).WithListener(listener)
+	server, err := New(cfg, NewReqHandlersDependencies("test pong").WithRouteGroups(group).WithInFlightRequests(inFlight).WithLogger(NoopLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go newHttpClient().Post("https://"+server.Addr().String()+"/slow"+pingRoute, "application/json", nil)
+	<-entered
+	if inFlight.InFlight() != 1 {
+		t.Fatalf("in-flight requests count '%v' is not as expected one '%v'", inFlight.InFlight(), 1)
+	}
+
+	start := time.Now()
+	err = server.Shutdown()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("shutdown took %v despite the %v drain timeout", elapsed, cfg.drainTimeout)
+	}
+	if !errors.Is(err, ErrDrainTimeout) {
+		t.Fatalf("returned shutdown error '%v' is not as expected one '%v'", err, ErrDrainTimeout)
+	}
+}
+
+func TestRejectWhileShuttingDown(t *testing.T) {
+	state := newHandlerState(newTestConfig(9093))
+	handler := newHandlerWithState(newTestConfig(9093).WithDrainTimeout(30*time.Second), NewReqHandlersDependencies("test pong"), state)
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	state.shuttingDown.Store(true)
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if res.Code != http.StatusServiceUnavailable {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusServiceUnavailable)
+	}
+	if res.Header().Get("Retry-After") != "30" {
+		t.Fatalf("returned Retry-After header '%v' is not as expected one '30'", res.Header().Get("Retry-After"))
+	}
+}
+
+func TestLameDuckReportsNotReadyWhileServing(t *testing.T) {
+	cfg := newTestConfig(0).WithLameDuckDuration(time.Second)
+	baseURL, cleanup := newTestServer(t, cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+
+	closed := make(chan struct{})
+	start := time.Now()
+	go func() {
+		defer close(closed)
+		cleanup()
+	}()
+
+	// The shutdown signal flips the readiness asynchronously.
+	var readyCode int
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		res, err := newHttpClient().Get(baseURL + readyRoute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		readyCode = res.StatusCode
+		if readyCode == http.StatusServiceUnavailable {
+			break
+		}
+	}
+	if readyCode != http.StatusServiceUnavailable {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", readyCode, http.StatusServiceUnavailable)
+	}
+
+	res, err := newHttpClient().Post(baseURL+pingRoute, "application/json", createPingReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.StatusCode, http.StatusOK)
+	}
+
+	<-closed
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("server drained after %v, before the end of the lame duck period", elapsed)
+	}
+}
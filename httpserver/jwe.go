@@ -0,0 +1,58 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+var (
+	jweKeyAlgorithms      = []jose.KeyAlgorithm{jose.RSA_OAEP, jose.RSA_OAEP_256, jose.ECDH_ES, jose.ECDH_ES_A128KW, jose.ECDH_ES_A256KW}
+	jweContentEncryptions = []jose.ContentEncryption{jose.A128GCM, jose.A256GCM, jose.A128CBC_HS256, jose.A256CBC_HS512}
+)
+
+// decryptJWE requires the request body to be a compact JWE encrypted for the given private key and hands
+// the plaintext over to the handler. Anything else is rejected with 400.
+func decryptJWE(privateKey crypto.PrivateKey) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if privateKey == nil {
+				WriteError(w, CodeInternal, errors.New("route requires an encrypted payload but no JWE private key is configured"))
+				return
+			}
+
+			body, err := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				WriteError(w, readRequestErrCode(err), fmt.Errorf("unable to read request body. %s", err.Error()))
+				return
+			}
+
+			encrypted, err := jose.ParseEncrypted(strings.TrimSpace(string(body)), jweKeyAlgorithms, jweContentEncryptions)
+			if err != nil {
+				WriteError(w, CodeInvalidRequest, fmt.Errorf("request body must be a compact JWE. %s", err.Error()))
+				return
+			}
+
+			plaintext, err := encrypted.Decrypt(privateKey)
+			if err != nil {
+				WriteError(w, CodeInvalidRequest, fmt.Errorf("unable to decrypt request body. %s", err.Error()))
+				return
+			}
+
+			r.Body = ioutil.NopCloser(bytes.NewReader(plaintext))
+			r.ContentLength = int64(len(plaintext))
+			r.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
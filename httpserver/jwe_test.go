@@ -0,0 +1,51 @@
+package httpserver
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+func TestEncryptedPayload(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := RouteGroup{Prefix: "/pii", Routes: []Route{{Path: pingRoute, Handler: pingHandlerImpl("test pong"), EncryptedPayload: true}}}
+	handler := newHandler(newTestConfig(9093).WithJWEPrivateKey(key), NewReqHandlersDependencies("test pong").WithRouteGroups(group))
+
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: jose.RSA_OAEP_256, Key: &key.PublicKey}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := encrypter.Encrypt([]byte(`{"value": "test ping value"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := encrypted.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", "/pii"+pingRoute, strings.NewReader(compact)))
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'. %v", res.Code, http.StatusOK, res.Body.String())
+	}
+	if !strings.Contains(res.Body.String(), "request: test ping value") {
+		t.Fatalf("returned body '%v' doesn't echo the decrypted value", res.Body.String())
+	}
+
+	for _, body := range []string{`{"value": "test ping value"}`, compact[:len(compact)-10] + "AAAAAAAAAA"} {
+		res = httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("POST", "/pii"+pingRoute, strings.NewReader(body)))
+		if res.Code != http.StatusBadRequest {
+			t.Fatalf("body '%v' returned response code '%v', expected '%v'", body, res.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+const (
+	unixSocketPerm = 0660
+)
+
+func listen(cfg Config) (net.Listener, error) {
+	if cfg.listener != nil {
+		return cfg.listener, nil
+	}
+
+	if len(cfg.unixSocketPath) != 0 {
+		return listenUnix(cfg.unixSocketPath)
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.port))
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return nil, &AddrInUseError{Port: cfg.port, Err: err}
+	}
+
+	return listener, err
+}
+
+// listenAdmin binds the admin listener of Config.WithAdminAddr.
+func listenAdmin(cfg Config) (net.Listener, error) {
+	if cfg.adminListener != nil {
+		return cfg.adminListener, nil
+	}
+
+	listener, err := net.Listen("tcp", cfg.adminAddr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on the admin address %s. %w", cfg.adminAddr, err)
+	}
+
+	return listener, nil
+}
+
+// isLoopbackAddr tells whether the host:port addr only listens on the loopback interface, an empty host
+// listens on all of them.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listenUnix removes the socket file a previous, crashed, run might have left behind. Anything else than
+// a socket at that path is never removed.
+func listenUnix(socketPath string) (net.Listener, error) {
+	fileInfo, err := os.Lstat(socketPath)
+	if err == nil {
+		if fileInfo.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("unable to listen on unix socket. %s exists and is not a socket", socketPath)
+		}
+
+		err = os.Remove(socketPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to remove stale unix socket. %s", err.Error())
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(socketPath, unixSocketPerm)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("unable to set unix socket permissions. %s", err.Error())
+	}
+
+	return listener, nil
+}
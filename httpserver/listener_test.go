@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeOnUnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "citizen.sock")
+	err := ioutil.WriteFile(socketPath, nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listen(newTestConfig(9093).WithUnixSocket(socketPath))
+	if err == nil {
+		t.Fatal("listening is not supposed to remove a regular file")
+	}
+
+	os.Remove(socketPath)
+	staleListener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staleListener.(*net.UnixListener).SetUnlinkOnClose(false)
+	staleListener.Close()
+
+	cfg := newTestConfig(9093).WithUnixSocket(socketPath)
+	_, cleanup := newTestServer(t, cfg, NewReqHandlersDependencies("test pong"))
+	defer cleanup()
+
+	fileInfo, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileInfo.Mode().Perm() != unixSocketPerm {
+		t.Fatalf("unix socket permissions '%v' are not as expected '%v'", fileInfo.Mode().Perm(), os.FileMode(unixSocketPerm))
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+
+	resp, err := client.Post("https://localhost"+pingRoute, "application/json", createPingReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestMaxConnsDelaysExtraConnection(t *testing.T) {
+	t.Parallel()
+	baseURL, cleanup := newTestServer(t, newTestConfig(0).WithMaxConns(1), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	defer cleanup()
+	addr := strings.TrimPrefix(baseURL, "https://")
+	// The readiness probe of newTestServer may still hold the only slot.
+	time.Sleep(100 * time.Millisecond)
+
+	first, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extra, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer extra.Close()
+	handshakeDone := make(chan error, 1)
+	go func() {
+		handshakeDone <- tls.Client(extra, &tls.Config{InsecureSkipVerify: true}).Handshake()
+	}()
+
+	select {
+	case err := <-handshakeDone:
+		t.Fatalf("extra connection was served while the limit was reached. %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	first.Close()
+	select {
+	case err := <-handshakeDone:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("extra connection was not served after the first one closed")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	decoratorParamsRoute = "/admin/decorator-params"
+)
+
+// DecoratorParams are the decorator settings that can be changed at runtime through the admin endpoint.
+type DecoratorParams struct {
+	// RateLimit is the number of requests per second the server accepts, 0 means unlimited.
+	RateLimit int `json:"rate_limit" xml:"rate_limit"`
+	// MaxBodyBytes caps the request body size, 0 means unlimited.
+	MaxBodyBytes int64 `json:"max_body_bytes" xml:"max_body_bytes"`
+	// RequestTimeoutMs bounds the routes without a timeout of their own nor a long-lived stream, 0 means unbounded.
+	RequestTimeoutMs int64 `json:"request_timeout_ms" xml:"request_timeout_ms"`
+}
+
+func (p DecoratorParams) validate() error {
+	if p.RateLimit < 0 {
+		return fmt.Errorf("rate_limit must not be negative")
+	}
+
+	if p.MaxBodyBytes < 0 {
+		return fmt.Errorf("max_body_bytes must not be negative")
+	}
+
+	if p.RequestTimeoutMs < 0 {
+		return fmt.Errorf("request_timeout_ms must not be negative")
+	}
+
+	return nil
+}
+
+// liveParams lets the decorators read their settings per request while the admin endpoint swaps them atomically.
+type liveParams struct {
+	value atomic.Value
+}
+
+func newLiveParams(params DecoratorParams) *liveParams {
+	lp := &liveParams{}
+	lp.store(params)
+
+	return lp
+}
+
+func (lp *liveParams) load() DecoratorParams {
+	return lp.value.Load().(DecoratorParams)
+}
+
+func (lp *liveParams) store(params DecoratorParams) {
+	lp.value.Store(params)
+}
+
+func decoratorParamsHandler(params *liveParams) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeNegotiated(w, r, params.load(), http.StatusOK)
+		case http.MethodPut, http.MethodPost:
+			newParams := DecoratorParams{}
+			err := readRequest(r, &newParams)
+			if err != nil {
+				writeNegotiatedError(w, r, readRequestErrCode(err), err)
+				return
+			}
+
+			err = newParams.validate()
+			if err != nil {
+				writeNegotiatedError(w, r, CodeInvalidRequest, err)
+				return
+			}
+
+			params.store(newParams)
+			writeNegotiated(w, r, newParams, http.StatusOK)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			writeNegotiatedError(w, r, CodeMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		}
+	})
+}
+
+// rateLimiter is shared by all the routes of a server, the limit is server-wide.
+type rateLimiter struct {
+	params      *liveParams
+	now         func() time.Time
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+func newRateLimiter(params *liveParams, now func() time.Time) *rateLimiter {
+	return &rateLimiter{params: params, now: now}
+}
+
+func (l *rateLimiter) allow() bool {
+	limit := l.params.load().RateLimit
+	if limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.windowStart) >= time.Second {
+		l.windowStart = now
+		l.count = 0
+	}
+
+	if l.count >= limit {
+		return false
+	}
+
+	l.count++
+	return true
+}
+
+func rateLimit(limiter *rateLimiter) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.allow() {
+				w.Header().Set("Retry-After", "1")
+				writeNegotiatedError(w, r, CodeRateLimited, errors.New("rate limit exceeded"))
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitRequestBody rejects a too large declared Content-Length with 413 before reading anything, bodies without
+// one, e.g. chunked, are cut off by http.MaxBytesReader while being read.
+func limitRequestBody(params *liveParams) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			maxBodyBytes := params.load().MaxBodyBytes
+			if maxBodyBytes <= 0 {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			if r.ContentLength > maxBodyBytes {
+				WriteError(w, CodePayloadTooLarge, fmt.Errorf("request body of %d bytes exceeds the %d bytes limit", r.ContentLength, maxBodyBytes))
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHotSwap(t *testing.T) {
+	params := newLiveParams(DecoratorParams{RateLimit: 3})
+
+	var nowNano int64 = time.Now().UnixNano()
+	now := func() time.Time { return time.Unix(0, atomic.LoadInt64(&nowNano)) }
+	nextWindow := func() { atomic.AddInt64(&nowNano, int64(time.Second)) }
+
+	mux := http.NewServeMux()
+	mux.Handle(pingRoute, decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), rateLimit(newRateLimiter(params, now))))
+	mux.Handle(decoratorParamsRoute, decorateHttpRes(decoratorParamsHandler(params), addJsonHeader(""), requireAdmin("secret", nil)))
+
+	if accepted := sendConcurrentPings(mux, 10); accepted != 3 {
+		t.Fatalf("%v requests were accepted, expected the initial rate limit of 3", accepted)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sendConcurrentPings(mux, 10)
+	}()
+
+	reqBody, _ := json.Marshal(DecoratorParams{RateLimit: 7})
+	req := httptest.NewRequest("PUT", decoratorParamsRoute, bytes.NewReader(reqBody))
+	req.Header.Set("Authorization", "Bearer secret")
+	res := httptest.NewRecorder()
+	mux.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+	wg.Wait()
+
+	nextWindow()
+	if accepted := sendConcurrentPings(mux, 10); accepted != 7 {
+		t.Fatalf("%v requests were accepted, expected the updated rate limit of 7", accepted)
+	}
+}
+
+func TestRateLimitIsSharedByTheRoutes(t *testing.T) {
+	group := RouteGroup{Prefix: "/other", Routes: []Route{{Path: pingRoute, Handler: pingHandlerImpl("other pong")}}}
+	cfg := newTestConfig(0).WithDecoratorParams(DecoratorParams{RateLimit: 3})
+	handler := newHandler(cfg, NewReqHandlersDependencies("test pong").WithRouteGroups(group).WithLogger(NoopLogger))
+
+	accepted := 0
+	for _, route := range []string{pingRoute, "/other" + pingRoute, pingRoute, "/other" + pingRoute} {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("POST", route, createPingReq()))
+		if res.Code == http.StatusOK {
+			accepted++
+		}
+	}
+
+	if accepted != 3 {
+		t.Fatalf("%v requests were accepted across the routes, expected the server-wide rate limit of 3", accepted)
+	}
+}
+
+func TestRequestTimeoutHotSwap(t *testing.T) {
+	group := RouteGroup{Prefix: "/slow", Routes: []Route{{Path: pingRoute, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		}
+	})}}}
+	cfg := newTestConfig(0)
+	state := newHandlerState(cfg)
+	handler := newHandlerWithState(cfg, NewReqHandlersDependencies("test pong").WithRouteGroups(group).WithLogger(NoopLogger), state)
+
+	testCases := []struct {
+		requestTimeoutMs int64
+		code             int
+	}{
+		{0, http.StatusOK},
+		{20, http.StatusServiceUnavailable},
+		{1000, http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		state.params.store(DecoratorParams{RequestTimeoutMs: testCase.requestTimeoutMs})
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", "/slow"+pingRoute, nil))
+		if res.Code != testCase.code {
+			t.Fatalf("%vms: returned response code '%v' is not as expected one '%v'", testCase.requestTimeoutMs, res.Code, testCase.code)
+		}
+	}
+}
+
+func TestDecoratorParamsHandlerRequiresToken(t *testing.T) {
+	params := newLiveParams(DecoratorParams{RateLimit: 3})
+	handler := decorateHttpRes(decoratorParamsHandler(params), addJsonHeader(""), requireAdmin("secret", nil))
+
+	reqBody, _ := json.Marshal(DecoratorParams{RateLimit: 100})
+	req := httptest.NewRequest("PUT", decoratorParamsRoute, bytes.NewReader(reqBody))
+	req.Header.Set("Authorization", "Bearer wrong")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusUnauthorized {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusUnauthorized)
+	}
+
+	if params.load().RateLimit != 3 {
+		t.Fatalf("rate limit was changed to '%v' without a valid token", params.load().RateLimit)
+	}
+}
+
+func TestLimitRequestBody(t *testing.T) {
+	params := newLiveParams(DecoratorParams{MaxBodyBytes: 5})
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), limitRequestBody(params))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if res.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	params.store(DecoratorParams{MaxBodyBytes: 1024})
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+}
+
+func sendConcurrentPings(handler http.Handler, count int) int {
+	var accepted int64
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+			if res.Code == http.StatusOK {
+				atomic.AddInt64(&accepted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return int(accepted)
+}
+
+type failingReader struct {
+	t *testing.T
+}
+
+func (fr failingReader) Read(p []byte) (int, error) {
+	fr.t.Error("request body is not supposed to be read")
+	return 0, io.EOF
+}
+
+func TestLimitRequestBodyRejectsDeclaredLengthEarly(t *testing.T) {
+	params := newLiveParams(DecoratorParams{MaxBodyBytes: 1024})
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), limitRequestBody(params))
+
+	req := httptest.NewRequest("POST", pingRoute, failingReader{t})
+	req.ContentLength = 4096
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestLimitRequestBodyRejectsChunkedBodyWhileReading(t *testing.T) {
+	params := newLiveParams(DecoratorParams{MaxBodyBytes: 1024})
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), limitRequestBody(params))
+
+	reqBody, _ := json.Marshal(pingReq{strings.Repeat("a", 4096)})
+	req := httptest.NewRequest("POST", pingRoute, bytes.NewReader(reqBody))
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusRequestEntityTooLarge)
+	}
+}
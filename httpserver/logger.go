@@ -0,0 +1,127 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Logger receives the server messages with their fields as alternating keys and values,
+// e.g. logger.Info("server started", "port", 443).
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+type stdLogger struct {
+	logger *log.Logger
+	debug  bool
+}
+
+// NewStdLogger writes "LEVEL message key=value ..." lines to out through the standard log package, the debug ones are dropped.
+func NewStdLogger(out io.Writer) Logger {
+	return stdLogger{logger: log.New(out, "", log.LstdFlags)}
+}
+
+// NewStdDebugLogger is NewStdLogger keeping the debug lines.
+func NewStdDebugLogger(out io.Writer) Logger {
+	return stdLogger{logger: log.New(out, "", log.LstdFlags), debug: true}
+}
+
+func (l stdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	if l.debug {
+		l.logger.Println(formatLogLine("DEBUG", msg, keysAndValues))
+	}
+}
+
+func (l stdLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Println(formatLogLine("INFO", msg, keysAndValues))
+}
+
+func (l stdLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.logger.Println(formatLogLine("ERROR", msg, keysAndValues))
+}
+
+func formatLogLine(level string, msg string, keysAndValues []interface{}) string {
+	line := strings.Builder{}
+	line.WriteString(level + " " + msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			fmt.Fprintf(&line, " %v=%q", keysAndValues[i], "MISSING")
+			break
+		}
+		fmt.Fprintf(&line, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+	}
+
+	return line.String()
+}
+
+// LogLevel is the lowest level a JSON logger writes.
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelError
+)
+
+// ParseLogLevel reads debug, info or error, case insensitive.
+func ParseLogLevel(level string) (LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return LogLevelDebug, nil
+	case "info":
+		return LogLevelInfo, nil
+	case "error":
+		return LogLevelError, nil
+	}
+
+	return 0, fmt.Errorf("unknown log level '%s', use debug, info or error", level)
+}
+
+func (level LogLevel) slogLevel() slog.Level {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelError:
+		return slog.LevelError
+	}
+
+	return slog.LevelInfo
+}
+
+type jsonLogger struct {
+	logger *slog.Logger
+}
+
+// NewJSONLogger writes one JSON object per line to out, with the time, level, msg and the fields as keys,
+// e.g. for a log collector. The lines below level are dropped.
+func NewJSONLogger(out io.Writer, level LogLevel) Logger {
+	return jsonLogger{logger: slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: level.slogLevel()}))}
+}
+
+func (l jsonLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.logger.Debug(msg, keysAndValues...)
+}
+
+func (l jsonLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Info(msg, keysAndValues...)
+}
+
+func (l jsonLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.logger.Error(msg, keysAndValues...)
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Debug(msg string, keysAndValues ...interface{}) {}
+func (noopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (noopLogger) Error(msg string, keysAndValues ...interface{}) {}
+
+var NoopLogger Logger = noopLogger{}
@@ -0,0 +1,77 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStdLoggerFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	NewStdLogger(out).Error("Request failed.", "path", "/ping", "status", 500, "orphan")
+
+	if !strings.HasSuffix(out.String(), `ERROR Request failed. path=/ping status=500 orphan="MISSING"`+"\n") {
+		t.Fatalf("logged line '%v' is not as expected", out.String())
+	}
+}
+
+func TestStdLoggerDropsDebugByDefault(t *testing.T) {
+	out := &bytes.Buffer{}
+	NewStdLogger(out).Debug("Client aborted the request.")
+
+	if out.Len() != 0 {
+		t.Fatalf("logged output '%v' is not supposed to hold debug lines", out.String())
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewJSONLogger(out, LogLevelInfo)
+	logger.Debug("Client aborted the request.")
+	logger.Error("Request failed.", "path", "/ping", "status", 500, "error", errors.New("boom"))
+
+	line := map[string]interface{}{}
+	err := json.Unmarshal(out.Bytes(), &line)
+	if err != nil {
+		t.Fatalf("logged output '%v' is not a single JSON line. %v", out.String(), err)
+	}
+
+	expected := map[string]interface{}{"level": "ERROR", "msg": "Request failed.", "path": "/ping", "status": float64(500), "error": "boom"}
+	for key, value := range expected {
+		if line[key] != value {
+			t.Fatalf("logged field '%v' value '%v' is not as expected one '%v'", key, line[key], value)
+		}
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	level, err := ParseLogLevel("DEBUG")
+	if err != nil || level != LogLevelDebug {
+		t.Fatalf("parsed level '%v' is not as expected one '%v'. %v", level, LogLevelDebug, err)
+	}
+
+	_, err = ParseLogLevel("verbose")
+	if err == nil {
+		t.Fatal("parsing an unknown level is supposed to fail")
+	}
+}
+
+func TestRunServerLogsStartupBanner(t *testing.T) {
+	out := &bytes.Buffer{}
+	deps := NewReqHandlersDependencies("test pong").WithLogger(NewStdLogger(out))
+	serveReqs := func(ctx context.Context, cfg Config, deps ReqHandlersDependencies) error {
+		return nil
+	}
+
+	err := RunServerImpl(context.Background(), newTestConfig(9093), serveReqs, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "INFO Starting GophersLand HTTP server. port=9093") {
+		t.Fatalf("logged output '%v' does not hold the startup banner", out.String())
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLongLivedDrainTimeout = 5 * time.Second
+)
+
+type shutdownSignalCtxKey struct{}
+
+// shutdownSignal is closed once the server starts shutting down, long-lived handlers (SSE, WebSocket)
+// must then finish their stream gracefully. Outside of a long-lived route it never fires.
+func shutdownSignal(ctx context.Context) <-chan struct{} {
+	signal, _ := ctx.Value(shutdownSignalCtxKey{}).(chan struct{})
+	return signal
+}
+
+// longLivedConns tracks the streaming handlers, http.Server.Shutdown can't drain them as they never go idle.
+type longLivedConns struct {
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	active       sync.WaitGroup
+}
+
+func newLongLivedConns() *longLivedConns {
+	return &longLivedConns{shutdown: make(chan struct{})}
+}
+
+func (l *longLivedConns) track() httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l.active.Add(1)
+			defer l.active.Done()
+
+			// The streams outlive the server write timeout by design, best effort as not every writer supports it.
+			http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+			handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), shutdownSignalCtxKey{}, l.shutdown)))
+		})
+	}
+}
+
+// drain broadcasts the shutdown signal and waits up to timeout for the streaming handlers to return,
+// it reports whether they all did.
+func (l *longLivedConns) drain(timeout time.Duration) bool {
+	l.shutdownOnce.Do(func() { close(l.shutdown) })
+
+	drained := make(chan struct{})
+	go func() {
+		l.active.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
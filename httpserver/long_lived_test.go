@@ -0,0 +1,82 @@
+package httpserver
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLongLivedConnsDrain(t *testing.T) {
+	longLived := newLongLivedConns()
+	signaled := make(chan struct{})
+	sseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-shutdownSignal(r.Context()):
+			close(signaled)
+			fmt.Fprint(w, "event: shutdown\ndata: bye\n\n")
+		case <-r.Context().Done():
+		}
+	})
+	server := httptest.NewServer(decorateHttpRes(sseHandler, longLived.track()))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "data: hello\n" {
+		t.Fatalf("first event line '%v' is not as expected. %v", line, err)
+	}
+
+	if !longLived.drain(time.Second) {
+		t.Fatal("long-lived handler did not close within the drain timeout")
+	}
+
+	select {
+	case <-signaled:
+	default:
+		t.Fatal("long-lived handler did not receive the shutdown signal")
+	}
+
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) < 2 || lines[1] != "event: shutdown\n" {
+		t.Fatalf("stream ended with '%v', expected the shutdown event", lines)
+	}
+}
+
+func TestLongLivedConnsDrainTimeout(t *testing.T) {
+	longLived := newLongLivedConns()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	stubbornHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})
+
+	go decorateHttpRes(stubbornHandler, longLived.track()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/events", nil))
+	<-entered
+
+	if longLived.drain(50 * time.Millisecond) {
+		t.Fatal("drain is not supposed to succeed while a handler ignores the shutdown signal")
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	methodOverrideHeader = "X-HTTP-Method-Override"
+)
+
+var defaultOverridableMethods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// methodOverride lets clients behind proxies blocking PUT/DELETE tunnel them through POST. It has to wrap
+// the mux so the routing already sees the overridden method.
+func methodOverride(allowedMethods ...string) httpResDecorator {
+	allowed := make(map[string]bool, len(allowedMethods))
+	for _, method := range allowedMethods {
+		allowed[strings.ToUpper(method)] = true
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			override := strings.ToUpper(strings.TrimSpace(r.Header.Get(methodOverrideHeader)))
+			if len(override) == 0 || r.Method != http.MethodPost {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed[override] {
+				WriteError(w, CodeInvalidRequest, fmt.Errorf("method override to %s is not allowed", override))
+				return
+			}
+
+			r.Method = override
+			r.Header.Del(methodOverrideHeader)
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMethodOverride(t *testing.T) {
+	var routedMethod string
+	methodHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routedMethod = r.Method
+	})
+	handler := decorateHttpRes(methodHandler, methodOverride(defaultOverridableMethods...))
+
+	testCases := []struct {
+		method         string
+		override       string
+		expectedCode   int
+		expectedMethod string
+	}{
+		{http.MethodPost, "DELETE", http.StatusOK, http.MethodDelete},
+		{http.MethodPost, "put", http.StatusOK, http.MethodPut},
+		{http.MethodPost, "CONNECT", http.StatusBadRequest, ""},
+		{http.MethodPost, "", http.StatusOK, http.MethodPost},
+		{http.MethodGet, "DELETE", http.StatusOK, http.MethodGet},
+	}
+
+	for _, testCase := range testCases {
+		routedMethod = ""
+		req := httptest.NewRequest(testCase.method, pingRoute, nil)
+		if len(testCase.override) != 0 {
+			req.Header.Set(methodOverrideHeader, testCase.override)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != testCase.expectedCode {
+			t.Fatalf("%s overridden with '%v' returned response code '%v', expected '%v'", testCase.method, testCase.override, res.Code, testCase.expectedCode)
+		}
+
+		if routedMethod != testCase.expectedMethod {
+			t.Fatalf("%s overridden with '%v' was routed as '%v', expected '%v'", testCase.method, testCase.override, routedMethod, testCase.expectedMethod)
+		}
+	}
+}
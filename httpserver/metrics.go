@@ -0,0 +1,149 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	metricsRoute = "/metrics"
+	// Requests carrying this header attach it as exemplar to their latency observation.
+	exemplarRequestIDHeader = requestIDHeader
+)
+
+type Metrics interface {
+	ObserveRequest(r *http.Request, route string, statusCode int, elapsed time.Duration)
+}
+
+// WriteFailureObserver is optionally implemented by the Metrics counting the responses that failed
+// to be fully written, e.g. on a connection reset.
+type WriteFailureObserver interface {
+	ObserveWriteFailure(r *http.Request, route string)
+}
+
+// InFlightObserver is optionally implemented by the Metrics tracking the requests being served per route.
+type InFlightObserver interface {
+	ObserveRequestStarted(r *http.Request, route string)
+	ObserveRequestFinished(r *http.Request, route string)
+}
+
+// DownstreamSLAObserver is optionally implemented by the Metrics counting the requests of the Route.DownstreamSLA
+// routes whose downstream calls exceeded their budget.
+type DownstreamSLAObserver interface {
+	ObserveDownstreamSLABreach(r *http.Request, route string)
+}
+
+type prometheusMetrics struct {
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	writeFailures *prometheus.CounterVec
+	inFlight      *prometheus.GaugeVec
+	slaBreaches   *prometheus.CounterVec
+}
+
+// The collectors are registered on the given, per-server, registry so multiple servers never conflict.
+// Nil buckets fall back to the Prometheus default ones.
+func newPrometheusMetrics(registerer prometheus.Registerer, latencyBuckets []float64) *prometheusMetrics {
+	if latencyBuckets == nil {
+		latencyBuckets = prometheus.DefBuckets
+	}
+
+	metrics := &prometheusMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests by method, route and status code.",
+		}, []string{"method", "path", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method and route.",
+			Buckets: latencyBuckets,
+		}, []string{"method", "path"}),
+		writeFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_response_write_failures_total",
+			Help: "Number of HTTP responses that failed to be fully written by method and route.",
+		}, []string{"method", "path"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests being served by method and route.",
+		}, []string{"method", "path"}),
+		slaBreaches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_downstream_sla_breaches_total",
+			Help: "Number of HTTP requests whose downstream calls exceeded their time budget by method and route.",
+		}, []string{"method", "path"}),
+	}
+	registerer.MustRegister(metrics.requests, metrics.duration, metrics.writeFailures, metrics.inFlight, metrics.slaBreaches)
+
+	return metrics
+}
+
+func (m *prometheusMetrics) ObserveRequest(r *http.Request, route string, statusCode int, elapsed time.Duration) {
+	m.requests.WithLabelValues(methodLabel(r), route, strconv.Itoa(statusCode)).Inc()
+
+	observer := m.duration.WithLabelValues(methodLabel(r), route)
+	requestID := r.Header.Get(exemplarRequestIDHeader)
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && len(requestID) != 0 {
+		exemplarObserver.ObserveWithExemplar(elapsed.Seconds(), prometheus.Labels{"request_id": requestID})
+		return
+	}
+	observer.Observe(elapsed.Seconds())
+}
+
+func (m *prometheusMetrics) ObserveWriteFailure(r *http.Request, route string) {
+	m.writeFailures.WithLabelValues(methodLabel(r), route).Inc()
+}
+
+func (m *prometheusMetrics) ObserveRequestStarted(r *http.Request, route string) {
+	m.inFlight.WithLabelValues(methodLabel(r), route).Inc()
+}
+
+func (m *prometheusMetrics) ObserveRequestFinished(r *http.Request, route string) {
+	m.inFlight.WithLabelValues(methodLabel(r), route).Dec()
+}
+
+func (m *prometheusMetrics) ObserveDownstreamSLABreach(r *http.Request, route string) {
+	m.slaBreaches.WithLabelValues(methodLabel(r), route).Inc()
+}
+
+// methodLabel keeps the series cardinality bounded whatever methods the clients send.
+func methodLabel(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return r.Method
+	default:
+		return "OTHER"
+	}
+}
+
+// registerProcessMetrics adds the Go runtime and process, CPU, memory and file descriptors, collectors.
+func registerProcessMetrics(registerer prometheus.Registerer) {
+	registerer.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// The route pattern, instead of the raw path, is used as label to keep the series cardinality bounded.
+func instrument(metrics Metrics, route string) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newStatusRecorder(w)
+			if observer, ok := metrics.(InFlightObserver); ok {
+				observer.ObserveRequestStarted(r, route)
+				defer observer.ObserveRequestFinished(r, route)
+			}
+
+			handler.ServeHTTP(rec, r)
+
+			metrics.ObserveRequest(r, route, rec.statusCode, time.Since(start))
+			if observer, ok := metrics.(WriteFailureObserver); ok && rec.writeFailed {
+				observer.ObserveWriteFailure(r, route)
+			}
+		})
+	}
+}
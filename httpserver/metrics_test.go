@@ -0,0 +1,181 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong"))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", metricsRoute, nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	expectedSeries := []string{
+		`http_requests_total{method="POST",path="/ping",status="200"} 1`,
+		`http_request_duration_seconds_count{method="POST",path="/ping"} 1`,
+		`http_requests_in_flight{method="POST",path="/ping"} 0`,
+		`go_goroutines `,
+	}
+	for _, series := range expectedSeries {
+		if !strings.Contains(res.Body.String(), series) {
+			t.Fatalf("scraped metrics don't contain '%v'.\n%v", series, res.Body.String())
+		}
+	}
+}
+
+func TestMetricsGroupUnknownMethods(t *testing.T) {
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong"))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("FOO", pingRoute, createPingReq()))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", metricsRoute, nil))
+
+	if !strings.Contains(res.Body.String(), `http_request_duration_seconds_count{method="OTHER",path="/ping"} 1`) {
+		t.Fatalf("scraped metrics don't count the unknown method as OTHER.\n%v", res.Body.String())
+	}
+	if strings.Contains(res.Body.String(), `method="FOO"`) {
+		t.Fatalf("scraped metrics are not supposed to label the raw unknown method.\n%v", res.Body.String())
+	}
+}
+
+func TestMetricsArePerServer(t *testing.T) {
+	first := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong"))
+	second := newHandler(newTestConfig(9094), NewReqHandlersDependencies("test pong"))
+
+	first.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	res := httptest.NewRecorder()
+	second.ServeHTTP(res, httptest.NewRequest("GET", metricsRoute, nil))
+	if strings.Contains(res.Body.String(), `http_requests_total{`) {
+		t.Fatalf("requests served by one server are not supposed to show up in the metrics of another.\n%v", res.Body.String())
+	}
+}
+
+func TestMetricsCustomLatencyBuckets(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithLatencyBuckets([]float64{0.005, 0.05, 0.5}), NewReqHandlersDependencies("test pong"))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", metricsRoute, nil))
+
+	for _, bucket := range []string{`le="0.005"`, `le="0.05"`, `le="0.5"`, `le="+Inf"`} {
+		if !strings.Contains(res.Body.String(), `http_request_duration_seconds_bucket{method="POST",path="/ping",`+bucket+`}`) {
+			t.Fatalf("scraped metrics don't contain the bucket '%v'.\n%v", bucket, res.Body.String())
+		}
+	}
+
+	if strings.Contains(res.Body.String(), `http_request_duration_seconds_bucket{method="POST",path="/ping",le="0.25"}`) {
+		t.Fatalf("scraped metrics are not supposed to contain the default buckets.\n%v", res.Body.String())
+	}
+}
+
+func TestMetricsOpenMetricsExemplar(t *testing.T) {
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong"))
+
+	req := httptest.NewRequest("POST", pingRoute, createPingReq())
+	req.Header.Set(exemplarRequestIDHeader, "test-request-id")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("GET", metricsRoute, nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if !strings.HasPrefix(res.Header().Get("Content-Type"), "application/openmetrics-text") {
+		t.Fatalf("returned content type '%v' is not OpenMetrics", res.Header().Get("Content-Type"))
+	}
+
+	if !strings.Contains(res.Body.String(), `# {request_id="test-request-id"}`) {
+		t.Fatalf("scraped metrics don't contain the request exemplar.\n%v", res.Body.String())
+	}
+}
+
+type shortWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w shortWriter) Write(b []byte) (int, error) {
+	return w.ResponseRecorder.Write(b[:len(b)/2])
+}
+
+func TestMetricsCountPartialWrites(t *testing.T) {
+	state := newHandlerState(newTestConfig(9093))
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), instrument(state.metrics, pingRoute))
+
+	handler.ServeHTTP(shortWriter{httptest.NewRecorder()}, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	res := httptest.NewRecorder()
+	newHandlerWithState(newTestConfig(9093), NewReqHandlersDependencies("test pong"), state).ServeHTTP(res, httptest.NewRequest("GET", metricsRoute, nil))
+
+	series := `http_response_write_failures_total{method="POST",path="/ping"} 1`
+	if !strings.Contains(res.Body.String(), series) {
+		t.Fatalf("scraped metrics don't contain '%v'.\n%v", series, res.Body.String())
+	}
+}
+
+type inFlightMetrics struct {
+	inFlight    int
+	maxInFlight int
+}
+
+func (m *inFlightMetrics) ObserveRequest(r *http.Request, route string, statusCode int, elapsed time.Duration) {
+}
+
+func (m *inFlightMetrics) ObserveRequestStarted(r *http.Request, route string) {
+	m.inFlight++
+	if m.inFlight > m.maxInFlight {
+		m.maxInFlight = m.inFlight
+	}
+}
+
+func (m *inFlightMetrics) ObserveRequestFinished(r *http.Request, route string) {
+	m.inFlight--
+}
+
+func TestMetricsTrackInFlightRequests(t *testing.T) {
+	metrics := &inFlightMetrics{}
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithMetrics(metrics))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	if metrics.maxInFlight != 1 || metrics.inFlight != 0 {
+		t.Fatalf("in-flight requests went up to '%v' and back to '%v', expected 1 and 0", metrics.maxInFlight, metrics.inFlight)
+	}
+}
+
+func TestMetricsRoute(t *testing.T) {
+	testCases := []struct {
+		name         string
+		cfg          Config
+		route        string
+		expectedCode int
+	}{
+		{"default route", newTestConfig(9093), metricsRoute, http.StatusOK},
+		{"moved route", newTestConfig(9093).WithMetricsRoute("/internal/metrics"), "/internal/metrics", http.StatusOK},
+		{"moved away from the default route", newTestConfig(9093).WithMetricsRoute("/internal/metrics"), metricsRoute, http.StatusNotFound},
+		{"disabled", newTestConfig(9093).WithMetricsRoute(""), metricsRoute, http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		res := httptest.NewRecorder()
+		newHandler(testCase.cfg, NewReqHandlersDependencies("test pong")).ServeHTTP(res, httptest.NewRequest("GET", testCase.route, nil))
+
+		if res.Code != testCase.expectedCode {
+			t.Fatalf("%s: returned response code '%v' is not as expected one '%v'", testCase.name, res.Code, testCase.expectedCode)
+		}
+	}
+}
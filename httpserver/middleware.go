@@ -0,0 +1,124 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	// Longer incoming IDs are replaced, they would bloat every log line of the request.
+	maxRequestIDLength = 128
+)
+
+// Middleware wraps a handler, e.g. to log, recover or tag the requests. Compose them with NewChain.
+type Middleware func(http.Handler) http.Handler
+
+// LoggingMiddleware logs every request with its remote address, status, size and duration, the values of
+// the default sensitive query parameters, e.g. token, are logged as ***. The servers already log through
+// it, with the Config.WithRedactedQueryParams ones, unless Config.WithRequestLog disables it.
+func LoggingMiddleware(logger Logger) Middleware {
+	return logRequests(logger, defaultRedactedQueryParams)
+}
+
+func logRequests(logger Logger, redactedParams []string) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newStatusRecorder(w)
+			handler.ServeHTTP(rec, r)
+
+			fields := []interface{}{"method", r.Method, "path", r.URL.Path}
+			if len(r.URL.RawQuery) != 0 {
+				fields = append(fields, "query", redactQuery(r.URL, redactedParams))
+			}
+			fields = append(fields, "status", rec.statusCode, "bytes", rec.bytesWritten, "duration", time.Since(start),
+				"remote_addr", r.RemoteAddr, "request_id", RequestIDFromContext(r.Context()))
+			logger.Info("Request served.", fields...)
+		})
+	}
+}
+
+// RecoveryMiddleware turns a handler panic into a JSON 500 and logs it with its stack trace. The servers
+// already recover the routes through the error reporter and whatever runs around them through this one.
+func RecoveryMiddleware(logger Logger) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newStatusRecorder(w)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				logPanic(logger, r, recovered, debug.Stack())
+				if !rec.wroteHeader {
+					WriteError(rec, CodeInternal, errors.New("internal server error"))
+				}
+			}()
+
+			handler.ServeHTTP(rec, r)
+		})
+	}
+}
+
+type requestIDCtxKey struct{}
+
+// RequestIDMiddleware keeps the X-Request-ID of the request, or generates one, echoes it in the response
+// and exposes it to the handlers through RequestIDFromContext. The server log lines about a request carry it
+// as request_id. An incoming ID that is too long or holds other characters than letters, digits and -_.:
+// is replaced, it ends up in the logs as is.
+func RequestIDMiddleware() Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(requestIDHeader)
+			if !isValidRequestID(requestID) {
+				requestID = newRequestID()
+				// The metrics exemplars read the header.
+				r.Header.Set(requestIDHeader, requestID)
+			}
+
+			w.Header().Set(requestIDHeader, requestID)
+			handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDCtxKey{}, requestID)))
+		})
+	}
+}
+
+// RequestIDFromContext is the ID set by RequestIDMiddleware, empty without it.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return requestID
+}
+
+func isValidRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		isAlphanumeric := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphanumeric && !strings.ContainsRune("-_.:", c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
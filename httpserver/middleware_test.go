@@ -0,0 +1,156 @@
+package httpserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerMiddlewares(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewStdLogger(out)
+	group := RouteGroup{Prefix: "/broken", Routes: []Route{{Path: pingRoute, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})}}}
+	deps := NewReqHandlersDependencies("test pong").
+		WithRouteGroups(group).
+		WithMiddlewares(RequestIDMiddleware(), LoggingMiddleware(logger), RecoveryMiddleware(logger))
+	handler := newHandler(newTestConfig(9093), deps)
+
+	req := httptest.NewRequest("POST", pingRoute, createPingReq())
+	req.Header.Set(requestIDHeader, "test-request-id")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Header().Get(requestIDHeader) != "test-request-id" {
+		t.Fatalf("returned request ID '%v' is not as expected one '%v'", res.Header().Get(requestIDHeader), "test-request-id")
+	}
+	if !strings.Contains(out.String(), "INFO Request served. method=POST path=/ping status=200") || !strings.Contains(out.String(), "request_id=test-request-id") {
+		t.Fatalf("logged output '%v' does not hold the served request", out.String())
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", "/broken"+pingRoute, nil))
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusInternalServerError)
+	}
+	if len(res.Header().Get(requestIDHeader)) != 32 {
+		t.Fatalf("generated request ID '%v' is not as expected", res.Header().Get(requestIDHeader))
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	out := &bytes.Buffer{}
+	handler := NewChain(RecoveryMiddleware(NewStdLogger(out))).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusInternalServerError)
+	}
+	if !strings.Contains(out.String(), "ERROR Handler panicked. path=/ panic=boom") {
+		t.Fatalf("logged output '%v' does not hold the panic", out.String())
+	}
+}
+
+func TestPanicIsLoggedWithStackAndRequestID(t *testing.T) {
+	out := &bytes.Buffer{}
+	group := RouteGroup{Prefix: "/broken", Routes: []Route{{Path: pingRoute, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})}}}
+	panickingMiddleware := func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/outer" {
+				panic("outer boom")
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").
+		WithLogger(NewStdLogger(out)).
+		WithRouteGroups(group).
+		WithMiddlewares(panickingMiddleware))
+
+	tests := []struct {
+		path  string
+		panic string
+	}{
+		{"/broken" + pingRoute, "panic=boom"},
+		{"/outer", "panic=outer boom"},
+	}
+	for _, test := range tests {
+		out.Reset()
+		req := httptest.NewRequest("POST", test.path, nil)
+		req.Header.Set(requestIDHeader, "test-request-id")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != http.StatusInternalServerError {
+			t.Fatalf("returned response code '%v' of %v is not as expected one '%v'", res.Code, test.path, http.StatusInternalServerError)
+		}
+		if !strings.Contains(res.Body.String(), `"error"`) {
+			t.Fatalf("returned body '%v' of %v is not a JSON error", res.Body.String(), test.path)
+		}
+		logged := out.String()
+		if !strings.Contains(logged, test.panic+" request_id=test-request-id stack=") || !strings.Contains(logged, "runtime/debug.Stack") {
+			t.Fatalf("logged output '%v' does not hold the panic of %v with its stack", logged, test.path)
+		}
+		if !strings.Contains(logged, "path="+test.path+" status=500") {
+			t.Fatalf("logged output '%v' is supposed to log %v as a 500", logged, test.path)
+		}
+	}
+}
+
+func TestRequestLogIsOnByDefault(t *testing.T) {
+	out := &bytes.Buffer{}
+	deps := NewReqHandlersDependencies("test pong").WithLogger(NewStdLogger(out))
+
+	res := httptest.NewRecorder()
+	newHandler(newTestConfig(9093), deps).ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	requestID := res.Header().Get(requestIDHeader)
+	if len(requestID) == 0 {
+		t.Fatal("response is supposed to carry a generated request ID")
+	}
+	if !strings.Contains(out.String(), "status=200") || !strings.Contains(out.String(), "remote_addr=192.0.2.1:1234 request_id="+requestID) {
+		t.Fatalf("logged output '%v' does not hold the served request", out.String())
+	}
+
+	out.Reset()
+	newHandler(newTestConfig(9093).WithRequestLog(false), deps).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if out.Len() != 0 {
+		t.Fatalf("logged output '%v' is supposed to be empty with the request log disabled", out.String())
+	}
+}
+
+func TestRequestIDMiddlewareReplacesInvalidIDs(t *testing.T) {
+	var seen string
+	handler := RequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	testCases := []struct {
+		incoming string
+		kept     bool
+	}{
+		{"3f2c9a1e-7b4d-4e8a-9c2f-1a2b3c4d5e6f", true},
+		{"trace:42_a.b", true},
+		{"forged\" level=ERROR", false},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(requestIDHeader, testCase.incoming)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if (seen == testCase.incoming) != testCase.kept || res.Header().Get(requestIDHeader) != seen {
+			t.Fatalf("incoming request ID '%v' turned into '%v', kept is supposed to be %v", testCase.incoming, seen, testCase.kept)
+		}
+	}
+}
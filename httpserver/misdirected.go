@@ -0,0 +1,68 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// rejectMisdirected answers 421 Misdirected Request when a client reuses a TLS connection, e.g. through
+// HTTP/2 connection coalescing, for a host the served certificate doesn't cover, so it opens a new one.
+// The leaves are read per request, the certificates may be reloaded meanwhile.
+func rejectMisdirected(currentLeaves func() []*x509.Certificate) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			leaves := currentLeaves()
+			if r.TLS == nil || len(leaves) == 0 {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			host := r.Host
+			if h, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = h
+			}
+
+			if servedLeaf(leaves, r.TLS.ServerName).VerifyHostname(host) != nil {
+				WriteError(w, CodeMisdirectedRequest, fmt.Errorf("certificate served on this connection is not valid for host '%s'", host))
+				return
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// servedLeaf mirrors the crypto/tls certificate selection, the first certificate matching the SNI wins
+// and the first configured one is the fallback.
+func servedLeaf(leaves []*x509.Certificate, serverName string) *x509.Certificate {
+	for _, leaf := range leaves {
+		if len(serverName) != 0 && leaf.VerifyHostname(serverName) == nil {
+			return leaf
+		}
+	}
+
+	return leaves[0]
+}
+
+func certificateLeaves(tlsConfig *tls.Config) ([]*x509.Certificate, error) {
+	var leaves []*x509.Certificate
+	for _, cert := range tlsConfig.Certificates {
+		leaf := cert.Leaf
+		if leaf == nil {
+			var err error
+			leaf, err = x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse TLS certificate. %s", err.Error())
+			}
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	return leaves, nil
+}
@@ -0,0 +1,84 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestRejectMisdirected(t *testing.T) {
+	tlsConfig, err := buildTLSConfig(newTestConfig(9093), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves, err := certificateLeaves(tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := decorateHttpRes(newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong")), rejectMisdirected(func() []*x509.Certificate { return leaves }))
+
+	testCases := []struct {
+		host         string
+		expectedCode int
+	}{
+		{"localhost:9093", http.StatusOK},
+		{"localhost", http.StatusOK},
+		{"citizen.gophersland.com", http.StatusMisdirectedRequest},
+		{"citizen.gophersland.com:9093", http.StatusMisdirectedRequest},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("POST", pingRoute, createPingReq())
+		req.Host = testCase.host
+		req.TLS = &tls.ConnectionState{ServerName: "localhost"}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != testCase.expectedCode {
+			t.Fatalf("host '%v' returned response code '%v', expected '%v'", testCase.host, res.Code, testCase.expectedCode)
+		}
+	}
+}
+
+func TestRejectMisdirectedAfterReload(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeSelfSignedKeyPair(t, dir, "localhost")
+	reloader, err := newCertReloader([]keyPairFiles{{certPath, keyPath}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := decorateHttpRes(newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong")), rejectMisdirected(reloader.leaves))
+
+	serve := func() int {
+		req := httptest.NewRequest("POST", pingRoute, createPingReq())
+		req.Host = "citizen.gophersland.com"
+		req.TLS = &tls.ConnectionState{ServerName: "citizen.gophersland.com"}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		return res.Code
+	}
+
+	if code := serve(); code != http.StatusMisdirectedRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", code, http.StatusMisdirectedRequest)
+	}
+
+	rotatedCert, rotatedKey := writeSelfSignedKeyPair(t, t.TempDir(), "citizen.gophersland.com")
+	for from, to := range map[string]string{rotatedCert: certPath, rotatedKey: keyPath} {
+		err = os.Rename(from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = reloader.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := serve(); code != http.StatusOK {
+		t.Fatalf("host added by the reload returned response code '%v', expected '%v'", code, http.StatusOK)
+	}
+}
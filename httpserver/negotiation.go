@@ -0,0 +1,114 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type responseFormat int
+
+const (
+	formatJson responseFormat = iota
+	formatXml
+)
+
+var formatsByExtension = map[string]responseFormat{
+	".json": formatJson,
+	".xml":  formatXml,
+}
+
+type responseFormatCtxKey struct{}
+
+// negotiateByExtension strips a known format extension, /ping.xml is routed as /ping and answered in XML.
+// It has to wrap the mux because the path must be stripped before routing.
+func negotiateByExtension() httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ext := path.Ext(r.URL.Path)
+			format, ok := formatsByExtension[ext]
+			if !ok {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			strippedURL := *r.URL
+			strippedURL.Path = r.URL.Path[:len(r.URL.Path)-len(ext)]
+			strippedURL.RawPath = ""
+
+			r = r.WithContext(context.WithValue(r.Context(), responseFormatCtxKey{}, format))
+			r.URL = &strippedURL
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestedFormat prefers an explicit path extension over the Accept header, JSON is the default.
+func requestedFormat(r *http.Request) responseFormat {
+	format, ok := r.Context().Value(responseFormatCtxKey{}).(responseFormat)
+	if ok {
+		return format
+	}
+
+	return formatFromAccept(r.Header.Get("Accept"))
+}
+
+func formatFromAccept(accept string) responseFormat {
+	jsonQuality, xmlQuality := -1.0, -1.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+
+		switch mediaType {
+		case "application/json", "*/*", "application/*":
+			if quality > jsonQuality {
+				jsonQuality = quality
+			}
+		case "application/xml", "text/xml":
+			if quality > xmlQuality {
+				xmlQuality = quality
+			}
+		}
+	}
+
+	if xmlQuality > 0 && xmlQuality > jsonQuality {
+		return formatXml
+	}
+
+	return formatJson
+}
+
+// writeNegotiated is writeResponse in the format the client asked for.
+func writeNegotiated(w http.ResponseWriter, r *http.Request, res interface{}, statusCode int) error {
+	return writeResponseAs(w, requestedFormat(r), res, statusCode)
+}
+
+func marshalResponse(format responseFormat, res interface{}, indentJSON bool) ([]byte, string, error) {
+	if format == formatXml {
+		xmlRes, err := xml.Marshal(res)
+		return xmlRes, "application/xml", err
+	}
+
+	if indentJSON {
+		jsonRes, err := json.MarshalIndent(res, "", "  ")
+		return jsonRes, "application/json", err
+	}
+
+	jsonRes, err := json.Marshal(res)
+	return jsonRes, "application/json", err
+}
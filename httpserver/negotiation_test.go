@@ -0,0 +1,115 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateByJsonExtension(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithExtensionNegotiation(), NewReqHandlersDependencies("test pong"))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute+".json", createPingReq()))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	if res.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("returned response header '%v' is not '%v'", res.Header().Get("Content-Type"), "application/json")
+	}
+
+	var pingRes pingRes
+	err := json.Unmarshal(res.Body.Bytes(), &pingRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pingRes.Message) == 0 {
+		t.Fatal("returned response is not suppose to be empty")
+	}
+}
+
+func TestNegotiateByXmlExtension(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithExtensionNegotiation(), NewReqHandlersDependencies("test pong"))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute+".xml", createPingReq()))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	if res.Header().Get("Content-Type") != "application/xml" {
+		t.Fatalf("returned response header '%v' is not '%v'", res.Header().Get("Content-Type"), "application/xml")
+	}
+
+	var pingRes pingRes
+	err := xml.Unmarshal(res.Body.Bytes(), &pingRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pingRes.Message != "request: test ping value; response: test pong" {
+		t.Fatalf("returned XML message '%v' is not as expected", pingRes.Message)
+	}
+}
+
+func TestExtensionNegotiationDisabledByDefault(t *testing.T) {
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong"))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute+".xml", createPingReq()))
+
+	if res.Code != http.StatusNotFound {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusNotFound)
+	}
+}
+
+func TestNegotiateByAcceptHeader(t *testing.T) {
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong"))
+
+	testCases := []struct {
+		accept              string
+		expectedContentType string
+	}{
+		{"application/xml", "application/xml"},
+		{"text/xml", "application/xml"},
+		{"application/json", "application/json"},
+		{"application/json;q=0.5, application/xml", "application/xml"},
+		{"application/xml;q=0.5, application/json", "application/json"},
+		{"*/*", "application/json"},
+		{"", "application/json"},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("POST", pingRoute, createPingReq())
+		req.Header.Set("Accept", testCase.accept)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Header().Get("Content-Type") != testCase.expectedContentType {
+			t.Fatalf("Accept '%v' returned content type '%v', expected '%v'", testCase.accept, res.Header().Get("Content-Type"), testCase.expectedContentType)
+		}
+
+		var pingRes pingRes
+		if testCase.expectedContentType == "application/xml" {
+			err := xml.Unmarshal(res.Body.Bytes(), &pingRes)
+			if err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			err := json.Unmarshal(res.Body.Bytes(), &pingRes)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if pingRes.Message != "request: test ping value; response: test pong" {
+			t.Fatalf("Accept '%v' returned message '%v' which is not as expected", testCase.accept, pingRes.Message)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type otelMetrics struct {
+	requests      metric.Int64Counter
+	duration      metric.Float64Histogram
+	writeFailures metric.Int64Counter
+}
+
+// NewOtelMetrics records the requests into OpenTelemetry instruments created from the given meter,
+// pass it to the server with ReqHandlersDependencies.WithMetrics.
+func NewOtelMetrics(meter metric.Meter) (Metrics, error) {
+	requests, err := meter.Int64Counter(
+		"http.server.requests",
+		metric.WithDescription("Number of HTTP requests by method, route and status code."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the requests counter. %s", err.Error())
+	}
+
+	duration, err := meter.Float64Histogram(
+		"http.server.request.duration",
+		metric.WithDescription("HTTP request latency by method and route."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the request duration histogram. %s", err.Error())
+	}
+
+	writeFailures, err := meter.Int64Counter(
+		"http.server.response.write_failures",
+		metric.WithDescription("Number of HTTP responses that failed to be fully written by method and route."),
+		metric.WithUnit("{response}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the write failures counter. %s", err.Error())
+	}
+
+	return &otelMetrics{requests: requests, duration: duration, writeFailures: writeFailures}, nil
+}
+
+func (m *otelMetrics) ObserveRequest(r *http.Request, route string, statusCode int, elapsed time.Duration) {
+	ctx := r.Context()
+	method := attribute.String("http.request.method", r.Method)
+	path := attribute.String("http.route", route)
+
+	m.requests.Add(ctx, 1, metric.WithAttributes(method, path, attribute.Int("http.response.status_code", statusCode)))
+	m.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(method, path))
+}
+
+func (m *otelMetrics) ObserveWriteFailure(r *http.Request, route string) {
+	m.writeFailures.Add(r.Context(), 1, metric.WithAttributes(attribute.String("http.request.method", r.Method), attribute.String("http.route", route)))
+}
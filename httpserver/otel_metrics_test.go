@@ -0,0 +1,58 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestOtelMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("citizen")
+	metrics, err := NewOtelMetrics(meter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithMetrics(metrics))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	collected := metricdata.ResourceMetrics{}
+	err = reader.Collect(context.Background(), &collected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := map[string]metricdata.Aggregation{}
+	for _, scope := range collected.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			recorded[m.Name] = m.Data
+		}
+	}
+
+	requests, ok := recorded["http.server.requests"].(metricdata.Sum[int64])
+	if !ok || len(requests.DataPoints) != 1 || requests.DataPoints[0].Value != 1 {
+		t.Fatalf("recorded requests counter '%+v' does not count the single ping request", recorded["http.server.requests"])
+	}
+	status, _ := requests.DataPoints[0].Attributes.Value("http.response.status_code")
+	if status.AsInt64() != http.StatusOK {
+		t.Fatalf("recorded status code '%v' is not as expected one '%v'", status.AsInt64(), http.StatusOK)
+	}
+
+	duration, ok := recorded["http.server.request.duration"].(metricdata.Histogram[float64])
+	if !ok || len(duration.DataPoints) != 1 || duration.DataPoints[0].Count != 1 {
+		t.Fatalf("recorded duration histogram '%+v' does not hold the single ping request", recorded["http.server.request.duration"])
+	}
+	route, _ := duration.DataPoints[0].Attributes.Value("http.route")
+	if route.AsString() != pingRoute {
+		t.Fatalf("recorded route '%v' is not as expected one '%v'", route.AsString(), pingRoute)
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+const (
+	pprofRoute = "/debug/pprof/"
+)
+
+// registerPprof mounts the net/http/pprof handlers on the server mux, decorated by the admin chain.
+func registerPprof(mux *http.ServeMux, adminChain Chain) {
+	mux.Handle(pprofRoute, adminChain.Then(http.HandlerFunc(pprof.Index)))
+	mux.Handle(pprofRoute+"cmdline", adminChain.Then(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle(pprofRoute+"profile", adminChain.Then(http.HandlerFunc(pprof.Profile)))
+	mux.Handle(pprofRoute+"symbol", adminChain.Then(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle(pprofRoute+"trace", adminChain.Then(http.HandlerFunc(pprof.Trace)))
+}
@@ -0,0 +1,157 @@
+package httpserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPprofDisabledByDefault(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithAdminToken("admin-secret"), NewReqHandlersDependencies("test pong"))
+
+	req := httptest.NewRequest("GET", pprofRoute+"goroutine?debug=1", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusNotFound {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusNotFound)
+	}
+}
+
+func TestPprofBehindAdminAuth(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithAdminToken("admin-secret").WithPprof(), NewReqHandlersDependencies("test pong"))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", pprofRoute+"goroutine?debug=1", nil))
+	if res.Code != http.StatusUnauthorized {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusUnauthorized)
+	}
+
+	req := httptest.NewRequest("GET", pprofRoute+"goroutine?debug=1", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+	if !strings.Contains(res.Body.String(), "goroutine profile:") {
+		t.Fatalf("returned response '%v' does not hold the goroutine profile", res.Body.String())
+	}
+}
+
+func TestValidatePprofRequiresAdminAuth(t *testing.T) {
+	err := newTestConfig(9093).WithPprof().Validate()
+	if err == nil {
+		t.Fatal("pprof without admin auth is supposed to be rejected")
+	}
+}
+
+func TestPprofOnAdminListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := newTestConfig(9093).WithListener(listener).WithAdminAddr("127.0.0.1:0").WithPprof()
+	server, err := New(cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	res, err := http.Get("http://" + server.AdminAddr().String() + pprofRoute + "goroutine?debug=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("returned admin response code '%v' is not as expected one '%v'", res.StatusCode, http.StatusOK)
+	}
+
+	for _, route := range []string{pprofRoute, metricsRoute} {
+		res, err = newHttpClient().Get("https://" + server.Addr().String() + route)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Fatalf("returned public response code '%v' for %s is not as expected one '%v'", res.StatusCode, route, http.StatusNotFound)
+		}
+	}
+}
+
+func TestAdminListenerDrainsOnShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := newTestConfig(9093).WithListener(listener).WithAdminAddr("127.0.0.1:0").WithPprof()
+	server, err := New(cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The CPU profile takes a second, the shutdown starts meanwhile.
+	codes := make(chan int, 1)
+	go func() {
+		res, err := http.Get("http://" + server.AdminAddr().String() + pprofRoute + "profile?seconds=1")
+		if err != nil {
+			t.Error(err)
+			codes <- 0
+			return
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		codes <- res.StatusCode
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	err = server.Shutdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := <-codes; code != http.StatusOK {
+		t.Fatalf("returned admin response code '%v' is not as expected one '%v'", code, http.StatusOK)
+	}
+}
+
+func TestAdminServeErrorIsReported(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminListener.Close()
+	cfg := newTestConfig(9093).WithListener(listener)
+	cfg.adminListener = adminListener
+	server, err := New(cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = server.Shutdown()
+	if err == nil || !strings.Contains(err.Error(), "admin HTTP server failed") {
+		t.Fatalf("returned error '%v' does not report the admin server failure", err)
+	}
+}
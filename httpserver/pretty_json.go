@@ -0,0 +1,50 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import "net/http"
+
+// prettyJSON has writeResponse marshal the JSON bodies indented. The writer is only marked, the written bytes
+// are left alone, so the streamed bodies and the compressed ones go through untouched.
+func prettyJSON() httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler.ServeHTTP(&prettyJSONWriter{ResponseWriter: w}, r)
+		})
+	}
+}
+
+type prettyJSONWriter struct {
+	http.ResponseWriter
+}
+
+func (w *prettyJSONWriter) indentJSON() bool {
+	return true
+}
+
+func (w *prettyJSONWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *prettyJSONWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// wantsIndentedJSON looks for the prettyJSON mark through the writers wrapping w, the writers buffering the
+// response carry the mark of the writer they stand in for.
+func wantsIndentedJSON(w http.ResponseWriter) bool {
+	for {
+		if marked, ok := w.(interface{ indentJSON() bool }); ok {
+			return marked.indentJSON()
+		}
+
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = unwrapper.Unwrap()
+	}
+}
@@ -0,0 +1,70 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONCharset(t *testing.T) {
+	testCases := []struct {
+		cfg                 Config
+		expectedContentType string
+	}{
+		{newTestConfig(9093), "application/json"},
+		{newTestConfig(9093).WithJSONCharset("utf-8"), "application/json; charset=utf-8"},
+	}
+
+	for _, testCase := range testCases {
+		res := httptest.NewRecorder()
+		newHandler(testCase.cfg, NewReqHandlersDependencies("test pong")).ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+		if res.Header().Get("Content-Type") != testCase.expectedContentType {
+			t.Fatalf("returned Content-Type '%v' is not as expected one '%v'", res.Header().Get("Content-Type"), testCase.expectedContentType)
+		}
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	res := httptest.NewRecorder()
+	newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong")).ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if strings.Contains(res.Body.String(), "\n  ") {
+		t.Fatalf("returned body '%v' is not supposed to be indented by default", res.Body.String())
+	}
+
+	res = httptest.NewRecorder()
+	newHandler(newTestConfig(9093).WithPrettyJSON(), NewReqHandlersDependencies("test pong")).ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	expected := "{\n  \"message\": \"request: test ping value; response: test pong\"\n}\n"
+	if res.Body.String() != expected {
+		t.Fatalf("returned body '%v' is not as expected indented one '%v'", res.Body.String(), expected)
+	}
+}
+
+func TestPrettyJSONCompressedAndBuffered(t *testing.T) {
+	group := RouteGroup{Prefix: "/cached", Routes: []Route{{Path: pingRoute, Handler: pingHandlerImpl("test pong"), Cacheable: true}}}
+	cfg := newTestConfig(9093).WithPrettyJSON().WithResponseCompression().WithRouteTimeout("/cached"+pingRoute, time.Second)
+	handler := newHandler(cfg, NewReqHandlersDependencies("test pong").WithRouteGroups(group))
+
+	req := httptest.NewRequest("GET", "/cached"+pingRoute+"?value=test+ping+value", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	gzipReader, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(gzipReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\n  \"message\": \"request: test ping value; response: test pong\"\n}\n"
+	if string(body) != expected {
+		t.Fatalf("returned body '%v' is not as expected indented one '%v'", string(body), expected)
+	}
+}
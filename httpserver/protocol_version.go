@@ -0,0 +1,42 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type protocolVersion struct {
+	major, minor int
+}
+
+func parseProtocolVersions(versions []string) ([]protocolVersion, error) {
+	var parsed []protocolVersion
+	for _, version := range versions {
+		major, minor, ok := http.ParseHTTPVersion(version)
+		if !ok {
+			return nil, fmt.Errorf("unable to parse HTTP protocol version '%s'", version)
+		}
+		parsed = append(parsed, protocolVersion{major, minor})
+	}
+
+	return parsed, nil
+}
+
+// allowProtocolVersions answers 505 HTTP Version Not Supported to the requests using a protocol version out of the allowlist.
+func allowProtocolVersions(allowed []protocolVersion) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, version := range allowed {
+				if r.ProtoMajor == version.major && r.ProtoMinor == version.minor {
+					handler.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			WriteError(w, CodeHTTPVersionNotSupported, fmt.Errorf("HTTP protocol version '%s' is not supported", r.Proto))
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowProtocolVersions(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithAllowedProtocolVersions("HTTP/1.1", "HTTP/2.0"), NewReqHandlersDependencies("test pong"))
+
+	testCases := []struct {
+		proto        string
+		major, minor int
+		expectedCode int
+	}{
+		{"HTTP/1.1", 1, 1, http.StatusOK},
+		{"HTTP/2.0", 2, 0, http.StatusOK},
+		{"HTTP/1.0", 1, 0, http.StatusHTTPVersionNotSupported},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("POST", pingRoute, createPingReq())
+		req.Proto, req.ProtoMajor, req.ProtoMinor = testCase.proto, testCase.major, testCase.minor
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != testCase.expectedCode {
+			t.Fatalf("protocol '%v' returned response code '%v', expected '%v'", testCase.proto, res.Code, testCase.expectedCode)
+		}
+	}
+}
+
+func TestValidateAllowedProtocolVersions(t *testing.T) {
+	err := newTestConfig(9093).WithAllowedProtocolVersions("HTTP/one").Validate()
+	if err == nil {
+		t.Fatal("an unparsable protocol version is supposed to be rejected")
+	}
+}
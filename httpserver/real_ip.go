@@ -0,0 +1,105 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type trustedProxyCtxKey struct{}
+
+// realIP rewrites r.RemoteAddr to the originating client IP when the direct peer is one of the trusted
+// proxies, given as CIDRs or single IPs. The forwarded headers of any other peer are ignored, anyone
+// can forge them. The requests of a trusted proxy are marked for fromTrustedProxy.
+func realIP(trustedProxies []string) httpResDecorator {
+	trusted, _ := parseTrustedProxies(trustedProxies)
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peerIP, peerPort, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil || !isTrustedProxy(trusted, net.ParseIP(peerIP)) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			if clientIP := forwardedClientIP(r, trusted); clientIP != nil {
+				r.RemoteAddr = net.JoinHostPort(clientIP.String(), peerPort)
+			}
+			handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), trustedProxyCtxKey{}, true)))
+		})
+	}
+}
+
+// fromTrustedProxy tells whether the direct peer of the request is a trusted proxy, i.e. whether the
+// headers set by the proxy, e.g. X-Auth-Time, can be believed.
+func fromTrustedProxy(r *http.Request) bool {
+	trusted, _ := r.Context().Value(trustedProxyCtxKey{}).(bool)
+	return trusted
+}
+
+// forwardedClientIP picks the rightmost X-Forwarded-For hop that isn't a trusted proxy, the hops left
+// of it were written by the client itself. X-Real-IP is the fallback.
+func forwardedClientIP(r *http.Request, trusted []*net.IPNet) net.IP {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	var leftmost net.IP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			return leftmost
+		}
+		if !isTrustedProxy(trusted, ip) {
+			return ip
+		}
+		leftmost = ip
+	}
+	if leftmost != nil {
+		return leftmost
+	}
+
+	return net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+}
+
+func isTrustedProxy(trusted []*net.IPNet, ip net.IP) bool {
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseTrustedProxies(trustedProxies []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy '%s' is neither an IP nor a CIDR", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse trusted proxy CIDR. %s", err.Error())
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
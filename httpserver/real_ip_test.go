@@ -0,0 +1,46 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	testCases := []struct {
+		name               string
+		remoteAddr         string
+		headers            map[string]string
+		expectedRemoteAddr string
+	}{
+		{"trusted with XFF", "10.0.0.5:40000", map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7, 10.0.0.9"}, "203.0.113.7:40000"},
+		{"trusted with X-Real-IP", "10.0.0.5:40000", map[string]string{"X-Real-IP": "203.0.113.7"}, "203.0.113.7:40000"},
+		{"untrusted with XFF", "192.0.2.10:40000", map[string]string{"X-Forwarded-For": "203.0.113.7"}, "192.0.2.10:40000"},
+		{"trusted without header", "10.0.0.5:40000", map[string]string{}, "10.0.0.5:40000"},
+	}
+
+	for _, testCase := range testCases {
+		var remoteAddr string
+		handler := realIP([]string{"10.0.0.0/8"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			remoteAddr = r.RemoteAddr
+		}))
+
+		req := httptest.NewRequest("GET", pingRoute, nil)
+		req.RemoteAddr = testCase.remoteAddr
+		for key, value := range testCase.headers {
+			req.Header.Set(key, value)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if remoteAddr != testCase.expectedRemoteAddr {
+			t.Fatalf("%v: remote address '%v' is not as expected one '%v'", testCase.name, remoteAddr, testCase.expectedRemoteAddr)
+		}
+	}
+}
+
+func TestTrustedProxiesValidation(t *testing.T) {
+	err := newTestConfig(9093).WithTrustedProxies("10.0.0.0/33").Validate()
+	if err == nil {
+		t.Fatal("an invalid trusted proxy CIDR is supposed to be rejected")
+	}
+}
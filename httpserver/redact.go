@@ -0,0 +1,66 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	redactedValue = "***"
+)
+
+var defaultRedactedQueryParams = []string{"token", "access_token", "api_key", "password"}
+
+// redactURL returns the request URI with the values of the given query parameters replaced by ***,
+// it is what the logging, metrics and tracing decorators must record instead of the raw URL.
+func redactURL(u *url.URL, redactedParams []string) string {
+	if len(u.RawQuery) == 0 || len(redactedParams) == 0 {
+		return u.RequestURI()
+	}
+
+	return u.EscapedPath() + "?" + redactQuery(u, redactedParams)
+}
+
+// redactQuery is the raw query of u with the values of the given parameters replaced by ***.
+func redactQuery(u *url.URL, redactedParams []string) string {
+	if len(u.RawQuery) == 0 || len(redactedParams) == 0 {
+		return u.RawQuery
+	}
+
+	redacted := make(map[string]bool, len(redactedParams))
+	for _, param := range redactedParams {
+		redacted[strings.ToLower(param)] = true
+	}
+
+	pairs := strings.Split(u.RawQuery, "&")
+	for i, pair := range pairs {
+		rawKey := pair
+		if idx := strings.Index(pair, "="); idx >= 0 {
+			rawKey = pair[:idx]
+		}
+
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+
+		if redacted[strings.ToLower(key)] {
+			pairs[i] = rawKey + "=" + redactedValue
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// redactedRequest is a copy of r for the code outside the server, e.g. an ErrorSink, whose URL is redacted.
+func redactedRequest(r *http.Request, redactedParams []string) *http.Request {
+	redacted := r.Clone(r.Context())
+	redacted.URL.RawQuery = redactQuery(r.URL, redactedParams)
+	redacted.RequestURI = redactURL(r.URL, redactedParams)
+
+	return redacted
+}
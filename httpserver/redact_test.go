@@ -0,0 +1,83 @@
+package httpserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRedactURL(t *testing.T) {
+	testCases := []struct {
+		rawURL   string
+		params   []string
+		expected string
+	}{
+		{"/ping?token=secret", []string{"token"}, "/ping?token=***"},
+		{"/ping?value=foo&token=secret&API_KEY=abc", defaultRedactedQueryParams, "/ping?value=foo&token=***&API_KEY=***"},
+		{"/ping?value=foo", []string{"token"}, "/ping?value=foo"},
+		{"/ping?token=secret", nil, "/ping?token=secret"},
+		{"/ping", []string{"token"}, "/ping"},
+	}
+
+	for _, testCase := range testCases {
+		u, err := url.Parse(testCase.rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		redacted := redactURL(u, testCase.params)
+		if redacted != testCase.expected {
+			t.Fatalf("URL '%v' was redacted to '%v', expected '%v'", testCase.rawURL, redacted, testCase.expected)
+		}
+	}
+}
+
+type urlRecordingSink struct {
+	urls []string
+}
+
+func (sink *urlRecordingSink) Report(r *http.Request, err error) {
+	sink.urls = append(sink.urls, r.URL.String(), r.RequestURI)
+}
+
+func TestRecordedURLsAreRedacted(t *testing.T) {
+	out := &bytes.Buffer{}
+	recorder := tracetest.NewSpanRecorder()
+	sink := &urlRecordingSink{}
+	group := RouteGroup{Prefix: "/failing", Routes: []Route{{Path: pingRoute, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})}}}
+	deps := NewReqHandlersDependencies("test pong").
+		WithLogger(NewStdLogger(out)).
+		WithErrorSink(sink).
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))).
+		WithRouteGroups(group)
+	handler := newHandler(newTestConfig(9093), deps)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/failing"+pingRoute+"?value=ok&token=secret", nil))
+
+	if strings.Contains(out.String(), "secret") || !strings.Contains(out.String(), "token=***") {
+		t.Fatalf("logged output '%v' is supposed to hold the redacted token", out.String())
+	}
+	if len(sink.urls) == 0 {
+		t.Fatal("failed request is supposed to be reported to the error sink")
+	}
+	for _, reported := range sink.urls {
+		if strings.Contains(reported, "secret") {
+			t.Fatalf("reported URL '%v' is not redacted", reported)
+		}
+	}
+	for _, span := range recorder.Ended() {
+		for _, attr := range span.Attributes() {
+			if strings.Contains(attr.Value.Emit(), "secret") {
+				t.Fatalf("span attribute '%v' is not redacted", attr.Key)
+			}
+		}
+	}
+}
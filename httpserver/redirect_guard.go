@@ -0,0 +1,113 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The hop count travels with the redirect chain in this query param.
+const redirectHopsParam = "redirect_hops"
+
+// limitRedirects breaks redirect chains: a redirect pointing back to the request URL, or a request that
+// already followed more than maxHops of our redirects, is answered with 400 instead.
+func limitRedirects(maxHops int) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hops, _ := strconv.Atoi(r.URL.Query().Get(redirectHopsParam))
+			if hops > maxHops {
+				WriteError(w, CodeInvalidRequest, fmt.Errorf("redirect loop detected after %d redirects", hops))
+				return
+			}
+
+			handler.ServeHTTP(&redirectGuardWriter{ResponseWriter: w, r: r, hops: hops}, r)
+		})
+	}
+}
+
+type redirectGuardWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	hops        int
+	wroteHeader bool
+	discard     bool
+}
+
+func (w *redirectGuardWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	location := w.Header().Get("Location")
+	if statusCode < 300 || statusCode >= 400 || len(location) == 0 {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	current := requestURL(w.r)
+	target, err := w.r.URL.Parse(location)
+	if err != nil || withoutHops(current.ResolveReference(target)) == withoutHops(current) {
+		w.discard = true
+		w.Header().Del("Location")
+		WriteError(w.ResponseWriter, CodeInvalidRequest, fmt.Errorf("redirect loop detected, %s redirects to itself", w.r.URL.Path))
+		return
+	}
+
+	query := target.Query()
+	query.Set(redirectHopsParam, strconv.Itoa(w.hops+1))
+	target.RawQuery = query.Encode()
+	w.Header().Set("Location", target.String())
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *redirectGuardWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.discard {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *redirectGuardWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok && !w.discard {
+		flusher.Flush()
+	}
+}
+
+func (w *redirectGuardWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// requestURL is the absolute URL the client asked for, the server side r.URL carries neither the scheme nor the host.
+func requestURL(r *http.Request) *url.URL {
+	u := *r.URL
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	u.Host = r.Host
+
+	return &u
+}
+
+// The scheme and host take part, a redirect to https or to the canonical host is not a loop.
+func withoutHops(u *url.URL) string {
+	query := u.Query()
+	query.Del(redirectHopsParam)
+
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + u.Path + "?" + query.Encode()
+}
@@ -0,0 +1,81 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestLimitRedirectsBreaksChain(t *testing.T) {
+	// Redirects back and forth between /a and /b, forever without the guard.
+	group := RouteGroup{Prefix: "/loop", Routes: []Route{
+		{Path: "/a", Handler: http.RedirectHandler("/loop/b", http.StatusFound)},
+		{Path: "/b", Handler: http.RedirectHandler("/loop/a", http.StatusFound)},
+	}}
+	handler := newHandler(newTestConfig(9093).WithMaxRedirects(3), NewReqHandlersDependencies("test pong").WithRouteGroups(group))
+
+	target := "/loop/a"
+	for hop := 0; hop < 10; hop++ {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", target, nil))
+
+		if res.Code == http.StatusBadRequest {
+			if hop != 4 {
+				t.Fatalf("redirect chain was broken after %v hops instead of %v", hop, 4)
+			}
+			return
+		}
+		if res.Code != http.StatusFound {
+			t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusFound)
+		}
+
+		location, err := url.Parse(res.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		target = location.RequestURI()
+	}
+
+	t.Fatal("redirect chain was never broken")
+}
+
+func TestLimitRedirectsRejectsSelfRedirect(t *testing.T) {
+	handler := decorateHttpRes(http.RedirectHandler("/self?b=2&a=1", http.StatusMovedPermanently), limitRedirects(5))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", "/self?a=1&b=2", nil))
+
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusBadRequest)
+	}
+	if len(res.Header().Get("Location")) != 0 {
+		t.Fatalf("returned Location header '%v' is not supposed to be set on a broken loop", res.Header().Get("Location"))
+	}
+}
+
+func TestLimitRedirectsAllowsSchemeAndHostRedirects(t *testing.T) {
+	testCases := []struct {
+		name     string
+		location string
+		url      string
+	}{
+		{"http to https", "https://example.com/self", "http://example.com/self"},
+		{"canonical host", "http://www.example.com/self", "http://example.com/self"},
+	}
+
+	for _, testCase := range testCases {
+		handler := decorateHttpRes(http.RedirectHandler(testCase.location, http.StatusMovedPermanently), limitRedirects(5))
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", testCase.url, nil))
+
+		if res.Code != http.StatusMovedPermanently {
+			t.Fatalf("%v: returned response code '%v' is not as expected one '%v'", testCase.name, res.Code, http.StatusMovedPermanently)
+		}
+		expectedLocation := testCase.location + "?" + redirectHopsParam + "=1"
+		if res.Header().Get("Location") != expectedLocation {
+			t.Fatalf("%v: returned Location header '%v' is not as expected one '%v'", testCase.name, res.Header().Get("Location"), expectedLocation)
+		}
+	}
+}
@@ -3,11 +3,40 @@
 // Use of this source code is governed by an Apache License that can be found in the LICENSE file.
 package httpserver
 
+import "fmt"
+
 type pingReq struct {
-	Value string `json:"value"`
+	Value string `json:"value" xml:"value"`
+}
+
+func (req pingReq) Validate() error {
+	if len(req.Value) == 0 {
+		return fmt.Errorf("ping request value must be at least 1 char")
+	}
+
+	return nil
 }
 
 type pingRes struct {
-	Message string `json:"message"`
-	Error   string `json:"error"`
+	Message string `json:"message" xml:"message"`
+}
+
+// errorRes is the envelope of every error response, built by newErrorRes:
+//
+//	{"error": {"code": "invalid_request", "message": "...", "details": [...], "request_id": "..."}}
+type errorRes struct {
+	Error errorBody `json:"error" xml:"error"`
+}
+
+type errorBody struct {
+	Code      ErrorCode     `json:"code" xml:"code"`
+	Message   string        `json:"message" xml:"message"`
+	Details   []ErrorDetail `json:"details,omitempty" xml:"details>detail,omitempty"`
+	RequestID string        `json:"request_id,omitempty" xml:"request_id,omitempty"`
+}
+
+// ErrorDetail points at the part of the request an error is about, e.g. the field of the wrong type.
+type ErrorDetail struct {
+	Field string `json:"field,omitempty" xml:"field,omitempty"`
+	Issue string `json:"issue" xml:"issue"`
 }
@@ -0,0 +1,158 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// pathWildcard matches the {name} and {name...} wildcards of a pattern, not the {$} end anchor.
+var pathWildcard = regexp.MustCompile(`\{[^{}$.]+(\.\.\.)?\}`)
+
+type Route struct {
+	// Path is a http.ServeMux pattern without the method, e.g. /citizens/{id}, the handler reads the
+	// parameters with r.PathValue. The metrics and traces are labeled with the pattern, not the actual path.
+	Path    string
+	Handler http.Handler
+	// Deprecated routes answer with the RFC 8594 Deprecation header and, when set, the Sunset date.
+	Deprecated bool
+	Sunset     time.Time
+	// LogDeprecatedCalls prints a warning every time a deprecated route is called.
+	LogDeprecatedCalls bool
+	// LongLived routes, e.g. SSE or WebSocket, are signaled through shutdownSignal to close during shutdown.
+	LongLived bool
+	// Methods restricts the route to the given HTTP methods, any method is accepted when empty.
+	Methods []string
+	// MaxAuthAge, when set, requires the caller to have authenticated within this interval, as told by the
+	// X-Auth-Time header of a Config.WithTrustedProxies proxy.
+	MaxAuthAge time.Duration
+	// Cacheable routes tag their GET responses with an ETag and answer conditional requests.
+	Cacheable bool
+	// EncryptedPayload routes only accept JWE request bodies, decrypted with the Config.WithJWEPrivateKey key.
+	EncryptedPayload bool
+	// Debounce, when set, batches the route requests instead of serving them one by one.
+	Debounce *Debounce
+	// BufferResponse routes hold their response until the handler returns, letting it replace the status,
+	// headers and body on a late error through DiscardBufferedResponse.
+	BufferResponse bool
+	// DownstreamSLA, when set, bounds the time the handler spends in its CallDownstream calls, past it the
+	// request is answered with a 504 whatever the handler responds.
+	DownstreamSLA time.Duration
+}
+
+// RouteGroup mounts its routes under Prefix and answers the requests under it matching no route, or using
+// a method a route doesn't accept, with its own handlers instead of the global JSON 404 and 405.
+// Every group needs its own Prefix, the public routes already own the empty one.
+type RouteGroup struct {
+	Prefix           string
+	Routes           []Route
+	NotFound         http.Handler
+	MethodNotAllowed http.Handler
+}
+
+func notFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeNegotiatedError(w, r, CodeNotFound, fmt.Errorf("route %s not found", r.URL.Path))
+	})
+}
+
+func methodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeNegotiatedError(w, r, CodeMethodNotAllowed, fmt.Errorf("method %s not allowed on route %s", r.Method, r.URL.Path))
+	})
+}
+
+// allowMethods hands the requests using a method the route doesn't accept to the notAllowed handler,
+// with the Allow header listing the accepted ones.
+func allowMethods(methods []string, notAllowed http.Handler) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		if len(methods) == 0 {
+			return handler
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, method := range methods {
+				if r.Method == method {
+					handler.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			notAllowed.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validateRoutePattern rejects the paths http.ServeMux would panic on, and the ones carrying a method or
+// a host as the route methods are given apart.
+func validateRoutePattern(path string) (err error) {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("route %s must start with /", path)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("invalid route %s. %v", path, recovered)
+		}
+	}()
+	http.NewServeMux().Handle(path, http.NotFoundHandler())
+
+	return nil
+}
+
+// routeShape is the pattern with its wildcards unnamed, two patterns of the same shape match the same
+// requests, e.g. /citizens/{id} and /citizens/{name}.
+func routeShape(path string) string {
+	return pathWildcard.ReplaceAllString(path, "{$1}")
+}
+
+func publicRoutes(deps ReqHandlersDependencies) []Route {
+	return append(builtinRoutes(deps), deps.routes...)
+}
+
+func builtinRoutes(deps ReqHandlersDependencies) []Route {
+	routes := []Route{
+		{Path: deps.pingRoutePath, Handler: pingHandler(deps.pingRouteResponseMessage, deps.logger), Methods: []string{http.MethodGet, http.MethodPost}},
+		{Path: healthRoute, Handler: healthHandler(nil, deps.healthFormatter, deps.redactHealthErrors)},
+		{Path: readyRoute, Handler: healthHandler(deps.readinessChecks, deps.healthFormatter, deps.redactHealthErrors)},
+		{Path: versionRoute, Handler: versionHandler()},
+	}
+	// The ping route may already be served on one of the aliases, e.g. for the probes of an older deployment.
+	aliases := []Route{
+		{Path: healthzRoute, Handler: healthHandler(nil, deps.healthFormatter, deps.redactHealthErrors)},
+		{Path: readyzRoute, Handler: healthHandler(deps.readinessChecks, deps.healthFormatter, deps.redactHealthErrors)},
+	}
+	for _, alias := range aliases {
+		if alias.Path != deps.pingRoutePath {
+			routes = append(routes, alias)
+		}
+	}
+	if deps.citizens != nil {
+		routes = append(routes, citizenRoutes(deps.citizens, deps.logger)...)
+	}
+
+	return routes
+}
+
+func deprecation(route Route, logger Logger) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			if !route.Sunset.IsZero() {
+				w.Header().Set("Sunset", route.Sunset.UTC().Format(http.TimeFormat))
+			}
+
+			if route.LogDeprecatedCalls {
+				logger.Info("Deprecated route called.", "path", route.Path, "remote_addr", r.RemoteAddr, "request_id", RequestIDFromContext(r.Context()))
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
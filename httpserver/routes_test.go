@@ -0,0 +1,158 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeprecatedRouteHeaders(t *testing.T) {
+	sunset := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	deprecatedRoute := Route{Path: "/old-ping", Handler: pingHandlerImpl("test pong"), Deprecated: true, Sunset: sunset}
+	normalRoute := Route{Path: pingRoute, Handler: pingHandlerImpl("test pong")}
+	state := newHandlerState(NewConfig(9093, "", ""))
+
+	res := httptest.NewRecorder()
+	decorateHttpRes(deprecatedRoute.Handler, routeDecorators(NewConfig(9093, "", ""), NewReqHandlersDependencies("test pong"), deprecatedRoute, state)...).
+		ServeHTTP(res, httptest.NewRequest("POST", deprecatedRoute.Path, createPingReq()))
+
+	if res.Header().Get("Deprecation") != "true" {
+		t.Fatalf("returned Deprecation header '%v' is not 'true'", res.Header().Get("Deprecation"))
+	}
+
+	if res.Header().Get("Sunset") != "Tue, 01 Jan 2030 00:00:00 GMT" {
+		t.Fatalf("returned Sunset header '%v' is not as expected", res.Header().Get("Sunset"))
+	}
+
+	res = httptest.NewRecorder()
+	decorateHttpRes(normalRoute.Handler, routeDecorators(NewConfig(9093, "", ""), NewReqHandlersDependencies("test pong"), normalRoute, state)...).
+		ServeHTTP(res, httptest.NewRequest("POST", normalRoute.Path, createPingReq()))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	for _, header := range []string{"Deprecation", "Sunset"} {
+		if _, ok := res.Header()[header]; ok {
+			t.Fatalf("%v header is not supposed to be set on a route that isn't deprecated", header)
+		}
+	}
+}
+
+func TestRouteGroupCustomNotFound(t *testing.T) {
+	customNotFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, CodeNotFound, errors.New("no such v2 resource"))
+	})
+	group := RouteGroup{
+		Prefix:   "/v2",
+		Routes:   []Route{{Path: pingRoute, Handler: pingHandlerImpl("test pong v2"), Methods: []string{"POST"}}},
+		NotFound: customNotFound,
+	}
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithRouteGroups(group))
+
+	testCases := []struct {
+		path          string
+		expectedError string
+	}{
+		{"/v2/unknown", "no such v2 resource"},
+		{"/unknown", "route /unknown not found"},
+	}
+
+	for _, testCase := range testCases {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", testCase.path, nil))
+
+		if res.Code != http.StatusNotFound {
+			t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusNotFound)
+		}
+
+		errRes := errorRes{}
+		err := json.Unmarshal(res.Body.Bytes(), &errRes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errRes.Error.Message != testCase.expectedError {
+			t.Fatalf("returned error '%v' for %v is not as expected one '%v'", errRes.Error.Message, testCase.path, testCase.expectedError)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", "/v2"+pingRoute, createPingReq()))
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+}
+
+func TestRouteGroupCustomMethodNotAllowed(t *testing.T) {
+	customMethodNotAllowed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, CodeMethodNotAllowed, errors.New("v2 only accepts POST"))
+	})
+	group := RouteGroup{
+		Prefix:           "/v2",
+		Routes:           []Route{{Path: pingRoute, Handler: pingHandlerImpl("test pong v2"), Methods: []string{"POST"}}},
+		MethodNotAllowed: customMethodNotAllowed,
+	}
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithRouteGroups(group))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", "/v2"+pingRoute, nil))
+
+	if res.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusMethodNotAllowed)
+	}
+	if res.Header().Get("Allow") != "POST" {
+		t.Fatalf("returned Allow header '%v' is not as expected one 'POST'", res.Header().Get("Allow"))
+	}
+	if !strings.Contains(res.Body.String(), "v2 only accepts POST") {
+		t.Fatalf("returned body '%v' is not the group's method not allowed response", res.Body.String())
+	}
+}
+
+func TestCustomPingRoute(t *testing.T) {
+	deps := NewReqHandlersDependencies("test pong").
+		WithPingRoute("/healthz").
+		WithPingResponseMessage(func(r *http.Request) string { return "pong from " + r.Header.Get("X-Instance") })
+	handler := newHandler(newTestConfig(9093), deps)
+
+	req := httptest.NewRequest("POST", "/healthz", createPingReq())
+	req.Header.Set("X-Instance", "eu-1")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	pingRes := pingRes{}
+	err := json.Unmarshal(res.Body.Bytes(), &pingRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pingRes.Message != "request: test ping value; response: pong from eu-1" {
+		t.Fatalf("returned message '%v' is not as expected", pingRes.Message)
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+	if res.Code != http.StatusNotFound {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusNotFound)
+	}
+}
+
+func TestPingAllowsGetAndPost(t *testing.T) {
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("DELETE", pingRoute, nil))
+
+	if res.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusMethodNotAllowed)
+	}
+	if res.Header().Get("Allow") != "GET, POST" {
+		t.Fatalf("returned Allow header '%v' is not as expected one '%v'", res.Header().Get("Allow"), "GET, POST")
+	}
+}
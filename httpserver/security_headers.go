@@ -0,0 +1,45 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// An empty contentSecurityPolicy leaves the Content-Security-Policy header out.
+func securityHeaders(contentSecurityPolicy string) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.Header().Set("X-Frame-Options", "DENY")
+			w.Header().Set("Referrer-Policy", "no-referrer")
+			if len(contentSecurityPolicy) != 0 {
+				w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Browsers ignore Strict-Transport-Security received over plaintext, so it is only sent on TLS requests.
+func hsts(maxAge time.Duration, includeSubdomains bool, preload bool) httpResDecorator {
+	value := fmt.Sprintf("max-age=%d", int64(maxAge/time.Second))
+	if includeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if preload {
+		value += "; preload"
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil {
+				w.Header().Set("Strict-Transport-Security", value)
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), securityHeaders("default-src 'none'"))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	expectedHeaders := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "no-referrer",
+		"Content-Security-Policy": "default-src 'none'",
+		"Content-Type":            "application/json",
+	}
+	for header, expected := range expectedHeaders {
+		if res.Header().Get(header) != expected {
+			t.Fatalf("returned response header '%v' is '%v', expected '%v'", header, res.Header().Get(header), expected)
+		}
+	}
+}
+
+func TestSecurityHeadersWithoutContentSecurityPolicy(t *testing.T) {
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), securityHeaders(""))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	if _, ok := res.Header()["Content-Security-Policy"]; ok {
+		t.Fatal("Content-Security-Policy header is not supposed to be set when no policy is configured")
+	}
+}
+
+func TestHstsOnTlsRequest(t *testing.T) {
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), hsts(365*24*time.Hour, true, true))
+
+	req := httptest.NewRequest("POST", pingRoute, createPingReq())
+	req.TLS = &tls.ConnectionState{}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	expected := "max-age=31536000; includeSubDomains; preload"
+	if res.Header().Get("Strict-Transport-Security") != expected {
+		t.Fatalf("returned Strict-Transport-Security header '%v' is not as expected one '%v'", res.Header().Get("Strict-Transport-Security"), expected)
+	}
+}
+
+func TestHstsWithoutOptionalDirectives(t *testing.T) {
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), hsts(time.Hour, false, false))
+
+	req := httptest.NewRequest("POST", pingRoute, createPingReq())
+	req.TLS = &tls.ConnectionState{}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	expected := "max-age=3600"
+	if res.Header().Get("Strict-Transport-Security") != expected {
+		t.Fatalf("returned Strict-Transport-Security header '%v' is not as expected one '%v'", res.Header().Get("Strict-Transport-Security"), expected)
+	}
+}
+
+func TestHstsOnPlaintextRequest(t *testing.T) {
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), hsts(time.Hour, true, false))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	if _, ok := res.Header()["Strict-Transport-Security"]; ok {
+		t.Fatal("Strict-Transport-Security header is not supposed to be sent over plaintext")
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+var errServerStarted = errors.New("server is already started")
+
+// Server is one HTTP server instance with its own mux and lifecycle, several of them can run in the same process.
+type Server struct {
+	cfg  Config
+	deps ReqHandlersDependencies
+
+	mu            sync.Mutex
+	handlers      map[string]map[string]http.Handler
+	middlewares   []Middleware
+	readiness     map[string]HealthCheck
+	listener      net.Listener
+	adminListener net.Listener
+	cancel        context.CancelFunc
+	done          chan struct{}
+	err           error
+}
+
+// New validates the configuration, the server binds nothing until Start.
+func New(cfg Config, deps ReqHandlersDependencies) (*Server, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid server configuration. %s", err.Error())
+	}
+
+	return &Server{cfg: cfg, deps: deps}, nil
+}
+
+// RegisterHandler mounts h on path for the given method next to the public routes, with the same decorators
+// and shutdown handling. The path may hold parameters, e.g. /citizens/{id}, read with r.PathValue("id"),
+// the other methods of a registered path are answered with a 405. It must be called before Start, a path
+// can be registered once per method and must not match the requests of an already served route, the
+// metrics, admin and debug ones included.
+func (s *Server) RegisterHandler(method string, path string, h http.Handler) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done != nil {
+		return errServerStarted
+	}
+
+	err := validateRoutePattern(path)
+	if err != nil {
+		return err
+	}
+	for _, route := range builtinRoutes(s.deps) {
+		if routeShape(route.Path) == routeShape(path) {
+			return fmt.Errorf("route %s is already served by the server", path)
+		}
+	}
+	for registered := range s.handlers {
+		if registered != path && routeShape(registered) == routeShape(path) {
+			return fmt.Errorf("route %s conflicts with the registered route %s", path, registered)
+		}
+	}
+	// The other routes, e.g. of ReqHandlersDependencies.WithRoutes, the route groups or the admin ones, are
+	// only known to the mux.
+	routes := s.registeredRoutes()
+	if s.handlers[path] == nil {
+		routes = append(routes, Route{Path: path, Handler: h})
+	}
+	_, err = buildHandler(s.cfg, s.deps.WithRoutes(routes...), newHandlerState(s.cfg))
+	if err != nil {
+		return err
+	}
+	if s.handlers == nil {
+		s.handlers = map[string]map[string]http.Handler{}
+	}
+	if s.handlers[path] == nil {
+		s.handlers[path] = map[string]http.Handler{}
+	}
+	if _, ok := s.handlers[path][method]; ok {
+		return fmt.Errorf("route %s %s is already registered", method, path)
+	}
+
+	s.handlers[path][method] = h
+	return nil
+}
+
+// RegisterReadinessCheck makes /ready and /readyz answer 503 while the named check fails, e.g. while the
+// storage is unreachable. It must be called before Start, the readiness fails on its own during the shutdown.
+func (s *Server) RegisterReadinessCheck(name string, check HealthCheck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done != nil {
+		return errServerStarted
+	}
+
+	if _, ok := s.readiness[name]; ok {
+		return fmt.Errorf("readiness check %s is already registered", name)
+	}
+	if s.readiness == nil {
+		s.readiness = map[string]HealthCheck{}
+	}
+
+	s.readiness[name] = check
+	return nil
+}
+
+// Use wraps every request of the server with the middlewares, in the order of the calls. It must be called before Start.
+func (s *Server) Use(middlewares ...Middleware) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done != nil {
+		return errServerStarted
+	}
+
+	s.middlewares = append(s.middlewares, middlewares...)
+	return nil
+}
+
+// registeredRoutes turns the registered handlers into one route per path dispatching on the method.
+func (s *Server) registeredRoutes() []Route {
+	var routes []Route
+	for path, byMethod := range s.handlers {
+		routes = append(routes, Route{Path: path, Handler: dispatchByMethod(byMethod), Methods: sortedMethods(byMethod)})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+
+	return routes
+}
+
+// dispatchByMethod relies on allowMethods to answer the other methods with a 405.
+func dispatchByMethod(byMethod map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		byMethod[r.Method].ServeHTTP(w, r)
+	})
+}
+
+func sortedMethods(byMethod map[string]http.Handler) []string {
+	var methods []string
+	for method := range byMethod {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	return methods
+}
+
+// Start binds the listener and serves in the background until the ctx is done or Shutdown is called.
+// Binding errors, e.g. an AddrInUseError, and conflicting routes are returned right away.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done != nil {
+		return errServerStarted
+	}
+
+	deps := s.deps.WithRoutes(s.registeredRoutes()...).WithMiddlewares(s.middlewares...)
+	for name, check := range s.readiness {
+		deps = deps.WithReadinessCheck(name, check)
+	}
+	_, err := buildHandler(s.cfg, deps, newHandlerState(s.cfg))
+	if err != nil {
+		return err
+	}
+
+	listener, err := listen(s.cfg)
+	if err != nil {
+		return err
+	}
+	cfg := s.cfg.WithListener(listener)
+	var adminListener net.Listener
+	if cfg.hasAdminListener() {
+		adminListener, err = listenAdmin(cfg)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		cfg.adminListener = adminListener
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.listener, s.adminListener, s.cancel, s.done = listener, adminListener, cancel, make(chan struct{})
+	go func() {
+		err := RunServerImpl(ctx, cfg, ServeReqsImpl, deps)
+		// Serving may fail before taking over the listeners, e.g. on a TLS error.
+		listener.Close()
+		if adminListener != nil {
+			adminListener.Close()
+		}
+
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		close(s.done)
+	}()
+
+	return nil
+}
+
+// Addr is the address the server listens on, handy with port 0 listeners. It is nil until Start.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Addr()
+}
+
+// AdminAddr is the address of the admin listener, nil until Start or without Config.WithAdminAddr.
+func (s *Server) AdminAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.adminListener == nil {
+		return nil
+	}
+
+	return s.adminListener.Addr()
+}
+
+// Done is closed once the server stopped serving, whether through Shutdown or on its own.
+func (s *Server) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done == nil {
+		return nil
+	}
+
+	return s.done
+}
+
+// Shutdown drains the server within the Config.WithDrainTimeout deadline and returns the serve and drain errors.
+func (s *Server) Shutdown() error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+
+	cancel()
+	<-done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
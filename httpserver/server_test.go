@@ -0,0 +1,317 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTwoServersInOneProcess(t *testing.T) {
+	t.Parallel()
+	var servers []*Server
+	for i := 0; i < 2; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		server, err := New(newTestConfig(9093).WithListener(listener), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = server.Start(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer server.Shutdown()
+
+		if server.Addr().String() != listener.Addr().String() {
+			t.Fatalf("server address '%v' is not as expected one '%v'", server.Addr(), listener.Addr())
+		}
+		servers = append(servers, server)
+	}
+
+	for _, server := range servers {
+		resp, err := newHttpClient().Post("https://"+server.Addr().String()+pingRoute, "application/json", createPingReq())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("returned response code '%v' is not as expected one '%v'", resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	for _, server := range servers {
+		err := server.Shutdown()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case <-server.Done():
+		default:
+			t.Fatal("server is supposed to be done after the shutdown")
+		}
+	}
+}
+
+func TestServerStartTwice(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := New(newTestConfig(9093).WithListener(listener), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Addr() != nil {
+		t.Fatal("server address is supposed to be nil before the start")
+	}
+
+	err = server.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	if server.Start(context.Background()) != errServerStarted {
+		t.Fatal("starting a started server is supposed to fail")
+	}
+	if server.Use(RequestIDMiddleware()) != errServerStarted {
+		t.Fatal("adding a middleware to a started server is supposed to fail")
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	_, err := New(newTestConfig(0), NewReqHandlersDependencies("test pong"))
+	if err == nil {
+		t.Fatal("an invalid configuration is supposed to be rejected")
+	}
+}
+
+func TestServerRegisterHandler(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := New(newTestConfig(9093).WithListener(listener), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{"GET", "POST"} {
+		method := method
+		err = server.RegisterHandler(method, "/citizens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeResponse(w, pingRes{method + " citizens"}, http.StatusOK)
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if server.RegisterHandler("GET", "/citizens", http.NotFoundHandler()) == nil {
+		t.Fatal("registering a route twice for the same method is supposed to fail")
+	}
+	if server.RegisterHandler("GET", pingRoute, http.NotFoundHandler()) == nil {
+		t.Fatal("registering a built-in route is supposed to fail")
+	}
+
+	err = server.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+	if server.RegisterHandler("PUT", "/citizens", http.NotFoundHandler()) != errServerStarted {
+		t.Fatal("registering a route on a started server is supposed to fail")
+	}
+
+	url := "https://" + server.Addr().String() + "/citizens"
+	resp, err := newHttpClient().Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res pingRes
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Message != "GET citizens" || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("returned response '%v' with Content-Type '%v' is not as expected", res, resp.Header.Get("Content-Type"))
+	}
+
+	req, _ := http.NewRequest("DELETE", url, nil)
+	resp, err = newHttpClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, POST" {
+		t.Fatalf("returned response code '%v' with Allow '%v' is not as expected", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+}
+
+func TestServerRegisterHandlerWithPathParameters(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := New(newTestConfig(9093).WithListener(listener), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = server.RegisterHandler("GET", "/citizens/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, pingRes{"citizen " + r.PathValue("id")}, http.StatusOK)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalidRoutes := []string{"/citizens/{name}", "/citizens/{id", "GET /citizens", "citizens"}
+	for _, path := range invalidRoutes {
+		if server.RegisterHandler("DELETE", path, http.NotFoundHandler()) == nil {
+			t.Fatalf("registering the route %v is supposed to fail", path)
+		}
+	}
+
+	err = server.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	resp, err := newHttpClient().Get("https://" + server.Addr().String() + "/citizens/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res pingRes
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Message != "citizen 42" {
+		t.Fatalf("returned message '%v' is not as expected one '%v'", res.Message, "citizen 42")
+	}
+}
+
+func TestServerRegisterHandlerRejectsServedRoutes(t *testing.T) {
+	cfg := newTestConfig(9093).WithAdminToken("admin-secret").WithPprof()
+	deps := NewReqHandlersDependencies("test pong").WithLogger(NoopLogger).
+		WithRoutes(Route{Path: "/orders", Handler: http.NotFoundHandler()}).
+		WithRouteGroups(RouteGroup{Prefix: "/v2", Routes: []Route{{Path: "/items", Handler: http.NotFoundHandler()}}})
+	server, err := New(cfg, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/orders", "/v2/items", metricsRoute, decoratorParamsRoute, pprofRoute} {
+		if server.RegisterHandler("GET", path, http.NotFoundHandler()) == nil {
+			t.Fatalf("registering the already served route %v is supposed to fail", path)
+		}
+	}
+}
+
+func TestServerStartRejectsConflictingRoutes(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	deps := NewReqHandlersDependencies("test pong").WithLogger(NoopLogger).
+		WithRoutes(Route{Path: "/v2/items", Handler: http.NotFoundHandler()}).
+		WithRouteGroups(RouteGroup{Prefix: "/v2", Routes: []Route{{Path: "/items", Handler: http.NotFoundHandler()}}})
+	server, err := New(newTestConfig(9093).WithListener(listener), deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = server.Start(context.Background())
+	if err == nil {
+		server.Shutdown()
+		t.Fatal("starting a server with conflicting routes is supposed to fail")
+	}
+	if server.Done() != nil {
+		t.Fatal("a server failing to start is not supposed to be serving")
+	}
+}
+
+func TestServerRegisterReadinessCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := New(newTestConfig(9093).WithListener(listener), NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = server.RegisterReadinessCheck("storage", func(ctx context.Context) error { return errors.New("storage unreachable") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.RegisterReadinessCheck("storage", func(ctx context.Context) error { return nil }) == nil {
+		t.Fatal("registering a readiness check twice is supposed to fail")
+	}
+
+	err = server.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	for _, route := range []string{readyRoute, readyzRoute} {
+		resp, err := newHttpClient().Get("https://" + server.Addr().String() + route)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("%v returned response code '%v', expected '%v'", route, resp.StatusCode, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestServerClosesSlowHeaderConns(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := newTestConfig(9093).WithListener(listener).WithServerTimeouts(ServerTimeouts{ReadHeader: 100 * time.Millisecond})
+	server, err := New(cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Nothing is sent, the server is supposed to give up on the handshake and the headers.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("read of an idle connection returned '%v', expected the server to close it", err)
+	}
+}
+
+func TestConfigValidatesServerTimeouts(t *testing.T) {
+	err := newTestConfig(9093).WithServerTimeouts(ServerTimeouts{Write: -time.Second}).Validate()
+	if err == nil {
+		t.Fatal("a negative server timeout is supposed to be rejected")
+	}
+}
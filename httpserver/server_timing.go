@@ -0,0 +1,115 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type serverTimingCtxKey struct{}
+
+// serverTimings collects the request phases reported in the Server-Timing header.
+type serverTimings struct {
+	mu     sync.Mutex
+	phases []serverTimingPhase
+}
+
+type serverTimingPhase struct {
+	name     string
+	duration time.Duration
+}
+
+func (timings *serverTimings) add(name string, duration time.Duration) {
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+
+	timings.phases = append(timings.phases, serverTimingPhase{name, duration})
+}
+
+func (timings *serverTimings) header() string {
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+
+	entries := make([]string, 0, len(timings.phases))
+	for _, phase := range timings.phases {
+		entries = append(entries, fmt.Sprintf("%s;dur=%.3f", phase.name, float64(phase.duration)/float64(time.Millisecond)))
+	}
+
+	return strings.Join(entries, ", ")
+}
+
+// timePhase starts timing a request phase, calling the returned func ends it. Outside of the
+// serverTiming decorator it does nothing.
+func timePhase(ctx context.Context, name string) func() {
+	timings, ok := ctx.Value(serverTimingCtxKey{}).(*serverTimings)
+	if !ok {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		timings.add(name, time.Since(start))
+	}
+}
+
+// serverTiming exposes the phases timed with timePhase, plus the whole handler one, in the Server-Timing
+// header. The header is set right before the status is written so the phases ended by then are included.
+func serverTiming() httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timings := &serverTimings{}
+			timingWriter := &serverTimingWriter{ResponseWriter: w, timings: timings, start: time.Now()}
+
+			handler.ServeHTTP(timingWriter, r.WithContext(context.WithValue(r.Context(), serverTimingCtxKey{}, timings)))
+			if !timingWriter.wroteHeader {
+				timingWriter.WriteHeader(http.StatusOK)
+			}
+		})
+	}
+}
+
+type serverTimingWriter struct {
+	http.ResponseWriter
+	timings     *serverTimings
+	start       time.Time
+	wroteHeader bool
+}
+
+func (w *serverTimingWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	w.timings.add("handler", time.Since(w.start))
+	w.Header().Set("Server-Timing", w.timings.header())
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *serverTimingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *serverTimingWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *serverTimingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
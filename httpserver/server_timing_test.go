@@ -0,0 +1,59 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestServerTimingHeader(t *testing.T) {
+	group := RouteGroup{Prefix: "/sensitive", Routes: []Route{{Path: pingRoute, Handler: pingHandlerImpl("test pong"), MaxAuthAge: 15 * time.Minute}}}
+	cfg := newTestConfig(9093).WithServerTiming().WithTrustedProxies("192.0.2.1")
+	handler := newHandler(cfg, NewReqHandlersDependencies("test pong").WithRouteGroups(group))
+
+	req := httptest.NewRequest("POST", "/sensitive"+pingRoute, createPingReq())
+	req.RemoteAddr = "192.0.2.1:51234"
+	req.Header.Set(authTimeHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	expected := regexp.MustCompile(`^auth;dur=\d+\.\d{3}, decode;dur=\d+\.\d{3}, handler;dur=\d+\.\d{3}$`)
+	if !expected.MatchString(res.Header().Get("Server-Timing")) {
+		t.Fatalf("returned Server-Timing header '%v' doesn't hold the auth, decode and handler phases", res.Header().Get("Server-Timing"))
+	}
+}
+
+func TestServerTimingHeaderOfAdminRoutes(t *testing.T) {
+	handler := newHandler(newTestConfig(9093).WithServerTiming().WithAdminToken("secret"), NewReqHandlersDependencies("test pong"))
+
+	req := httptest.NewRequest("GET", decoratorParamsRoute, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+	expected := regexp.MustCompile(`^auth;dur=\d+\.\d{3}, handler;dur=\d+\.\d{3}$`)
+	if !expected.MatchString(res.Header().Get("Server-Timing")) {
+		t.Fatalf("returned Server-Timing header '%v' doesn't hold the auth and handler phases", res.Header().Get("Server-Timing"))
+	}
+}
+
+func TestServerTimingDisabledByDefault(t *testing.T) {
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong"))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	if _, ok := res.Header()["Server-Timing"]; ok {
+		t.Fatal("Server-Timing header is not supposed to be set unless enabled")
+	}
+}
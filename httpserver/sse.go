@@ -0,0 +1,33 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// writeServerSentEvent writes a single SSE message and flushes it, so the client receives it right away
+// instead of when the handler returns.
+func writeServerSentEvent(w http.ResponseWriter, data string) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("unable to stream the event. %T does not implement http.Flusher", w)
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		_, err := fmt.Fprintf(w, "data: %s\n", line)
+		if err != nil {
+			return fmt.Errorf("unable to write the event. %s", err.Error())
+		}
+	}
+	_, err := fmt.Fprint(w, "\n")
+	if err != nil {
+		return fmt.Errorf("unable to write the event. %s", err.Error())
+	}
+
+	flusher.Flush()
+	return nil
+}
@@ -0,0 +1,80 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServerSentEventsAreFlushedThroughDecorators(t *testing.T) {
+	cfg := NewConfig(0, "", "").WithSecurityHeaders("")
+	state := newHandlerState(cfg)
+	route := Route{Path: "/events", LongLived: true}
+	secondEvent := make(chan struct{})
+
+	route.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if err := writeServerSentEvent(w, "first"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		select {
+		case <-secondEvent:
+		case <-time.After(2 * time.Second):
+			t.Error("first event was not received by the client before the handler returned")
+			return
+		}
+
+		if err := writeServerSentEvent(w, "second"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	handler := decorateHttpRes(route.Handler, routeDecorators(cfg, NewReqHandlersDependencies("test pong"), route, state)...)
+	handler = enforceDownstreamSLA(time.Second, NoopLogger, nil, pingRoute)(handler)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	reader := bufio.NewReader(res.Body)
+	expectEvent(t, reader, "first")
+	close(secondEvent)
+	expectEvent(t, reader, "second")
+}
+
+func TestWriteServerSentEventRequiresFlusher(t *testing.T) {
+	err := writeServerSentEvent(struct{ http.ResponseWriter }{httptest.NewRecorder()}, "event")
+	if err == nil {
+		t.Fatal("writing an event through a writer without http.Flusher is supposed to fail")
+	}
+}
+
+func expectEvent(t *testing.T, reader *bufio.Reader, data string) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "data: "+data+"\n" {
+		t.Fatalf("received event '%q' is not as expected one '%q'", line, "data: "+data+"\n")
+	}
+
+	blank, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blank != "\n" {
+		t.Fatalf("event '%v' is not terminated by a blank line", data)
+	}
+}
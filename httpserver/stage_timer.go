@@ -0,0 +1,166 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var errDownstreamBudgetExceeded = errors.New("downstream stage exceeded its time budget")
+
+type stageTimerCtxKey struct{}
+
+// stageTimer splits the request time between the downstream calls and the handler's own work.
+type stageTimer struct {
+	downstreamBudget time.Duration
+	start            time.Time
+
+	mu             sync.Mutex
+	downstream     time.Duration
+	budgetExceeded bool
+}
+
+func (st *stageTimer) remainingDownstreamBudget() time.Duration {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.downstreamBudget - st.downstream
+}
+
+func (st *stageTimer) recordDownstream(elapsed time.Duration, deadlineHit bool) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.downstream += elapsed
+	if deadlineHit || st.downstream > st.downstreamBudget {
+		st.budgetExceeded = true
+	}
+
+	return st.budgetExceeded
+}
+
+func (st *stageTimer) split() (downstream time.Duration, local time.Duration, budgetExceeded bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.downstream, time.Since(st.start) - st.downstream, st.budgetExceeded
+}
+
+// CallDownstream runs fn with a context bounded by what is left of the request downstream budget.
+// Outside of a Route.DownstreamSLA route it simply calls fn.
+func CallDownstream(ctx context.Context, fn func(ctx context.Context) error) error {
+	st, ok := ctx.Value(stageTimerCtxKey{}).(*stageTimer)
+	if !ok {
+		return fn(ctx)
+	}
+
+	remaining := st.remainingDownstreamBudget()
+	if remaining <= 0 {
+		st.recordDownstream(0, true)
+		return errDownstreamBudgetExceeded
+	}
+
+	downstreamCtx, cancel := context.WithTimeout(ctx, remaining)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(downstreamCtx)
+	deadlineHit := downstreamCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+	if st.recordDownstream(time.Since(start), deadlineHit) {
+		return errDownstreamBudgetExceeded
+	}
+
+	return err
+}
+
+// enforceDownstreamSLA answers with 504 Gateway Timeout whenever the handler's downstream calls
+// took longer than budget, regardless of the status the handler wanted to respond with.
+func enforceDownstreamSLA(budget time.Duration, logger Logger, metrics Metrics, route string) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			st := &stageTimer{downstreamBudget: budget, start: time.Now()}
+			slaWriter := &slaResponseWriter{ResponseWriter: w, timer: st}
+
+			handler.ServeHTTP(slaWriter, r.WithContext(context.WithValue(r.Context(), stageTimerCtxKey{}, st)))
+			if !slaWriter.wroteHeader {
+				slaWriter.WriteHeader(http.StatusOK)
+			}
+
+			downstream, local, budgetExceeded := st.split()
+			if observer, ok := metrics.(DownstreamSLAObserver); ok && budgetExceeded {
+				observer.ObserveDownstreamSLABreach(r, route)
+			}
+			keysAndValues := []interface{}{"method", r.Method, "path", r.URL.Path, "downstream", downstream, "budget", budget, "local", local, "request_id", RequestIDFromContext(r.Context())}
+			if budgetExceeded {
+				logger.Error("Request exceeded its downstream budget.", keysAndValues...)
+				return
+			}
+			logger.Debug("Request stages.", keysAndValues...)
+		})
+	}
+}
+
+type slaResponseWriter struct {
+	http.ResponseWriter
+	timer       *stageTimer
+	wroteHeader bool
+	discard     bool
+}
+
+func (w *slaResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	downstream, _, budgetExceeded := w.timer.split()
+	if budgetExceeded {
+		w.discard = true
+		WriteError(w.ResponseWriter, CodeTimeout, fmt.Errorf("downstream stage took %v, exceeding its %v budget", downstream, w.timer.downstreamBudget))
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *slaResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.discard {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *slaResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.discard {
+		return
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *slaResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(w.ResponseWriter)
+}
+
+func (w *slaResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
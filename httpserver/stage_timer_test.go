@@ -0,0 +1,117 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownstreamSLAExceeded(t *testing.T) {
+	var downstreamErr error
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstreamErr = CallDownstream(r.Context(), func(ctx context.Context) error {
+			select {
+			case <-time.After(time.Second):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+
+		WriteError(w, CodeBadGateway, errors.New("downstream failed"))
+	})
+
+	res := httptest.NewRecorder()
+	decorateHttpRes(handler, enforceDownstreamSLA(50*time.Millisecond, NoopLogger, nil, pingRoute)).ServeHTTP(res, httptest.NewRequest("POST", pingRoute, nil))
+
+	if downstreamErr != errDownstreamBudgetExceeded {
+		t.Fatalf("downstream call returned '%v', expected '%v'", downstreamErr, errDownstreamBudgetExceeded)
+	}
+
+	if res.Code != http.StatusGatewayTimeout {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusGatewayTimeout)
+	}
+
+	var errRes errorRes
+	err := json.Unmarshal(res.Body.Bytes(), &errRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(errRes.Error.Message, "downstream stage") {
+		t.Fatalf("returned error '%v' does not attribute the timeout to the downstream stage", errRes.Error.Message)
+	}
+}
+
+func TestDownstreamSLASlowLocalStage(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := CallDownstream(r.Context(), func(ctx context.Context) error { return nil })
+		if err != nil {
+			t.Error(err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		writeResponse(w, pingRes{"done"}, http.StatusOK)
+	})
+
+	res := httptest.NewRecorder()
+	decorateHttpRes(handler, enforceDownstreamSLA(50*time.Millisecond, NoopLogger, nil, pingRoute)).ServeHTTP(res, httptest.NewRequest("POST", pingRoute, nil))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("a slow local stage is not supposed to be reported as a downstream timeout, got '%v'", res.Code)
+	}
+}
+
+func TestDownstreamSLARoute(t *testing.T) {
+	group := RouteGroup{Prefix: "/orders", Routes: []Route{{Path: pingRoute, DownstreamSLA: 20 * time.Millisecond, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		CallDownstream(r.Context(), func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		writeResponse(w, pingRes{"done"}, http.StatusOK)
+	})}}}
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithRouteGroups(group).WithLogger(NoopLogger))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", "/orders"+pingRoute, nil))
+	if res.Code != http.StatusGatewayTimeout {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusGatewayTimeout)
+	}
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", metricsRoute, nil))
+	series := `http_downstream_sla_breaches_total{method="POST",path="/orders/ping"} 1`
+	if !strings.Contains(res.Body.String(), series) {
+		t.Fatalf("scraped metrics don't contain '%v'.\n%v", series, res.Body.String())
+	}
+}
+
+func TestDownstreamSLALogsOnlyTheBreaches(t *testing.T) {
+	var out bytes.Buffer
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = CallDownstream(r.Context(), func(ctx context.Context) error {
+			if r.URL.Path == "/slow" {
+				<-ctx.Done()
+			}
+			return ctx.Err()
+		})
+		writeResponse(w, pingRes{"done"}, http.StatusOK)
+	})
+	slaHandler := decorateHttpRes(handler, enforceDownstreamSLA(50*time.Millisecond, NewStdLogger(&out), nil, pingRoute))
+
+	slaHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", pingRoute, nil))
+	if out.Len() != 0 {
+		t.Fatalf("request within its budget is only supposed to be logged at debug level, got '%v'", out.String())
+	}
+
+	slaHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/slow", nil))
+	if !strings.Contains(out.String(), "exceeded its downstream budget") {
+		t.Fatalf("logged '%v' does not report the exceeded budget", out.String())
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// withTimeout cancels the request context once d elapses and answers with a JSON 503, its charset
+// being the one of addJsonHeader.
+func withTimeout(d time.Duration, charset string) httpResDecorator {
+	timeoutErr := fmt.Errorf("request did not complete within %v", d)
+	contentType := jsonContentType(charset)
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The body is built per request to carry its ID.
+			indentJSON := wantsIndentedJSON(w)
+			timeoutBody, _, _ := marshalResponse(formatJson, newErrorRes(w, CodeUnavailable, timeoutErr), indentJSON)
+			// http.TimeoutHandler only copies the handler headers on success, the timeout response needs its own.
+			w.Header().Set("Content-Type", contentType)
+			// Its writer doesn't unwrap, the prettyJSON mark is carried over.
+			timedHandler := handler
+			if indentJSON {
+				timedHandler = prettyJSON()(handler)
+			}
+			http.TimeoutHandler(timedHandler, d, string(timeoutBody)+"\n").ServeHTTP(w, r)
+		})
+	}
+}
+
+// withLiveTimeout reads the timeout per request, so a change through the admin endpoint applies to the next requests.
+func withLiveTimeout(params *liveParams, charset string) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := time.Duration(params.load().RequestTimeoutMs) * time.Millisecond
+			if timeout <= 0 {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			withTimeout(timeout, charset)(handler).ServeHTTP(w, r)
+		})
+	}
+}
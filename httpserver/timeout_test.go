@@ -0,0 +1,78 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+	})
+	handler := decorateHttpRes(slowHandler, withTimeout(50*time.Millisecond, ""))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	if res.Code != http.StatusServiceUnavailable {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusServiceUnavailable)
+	}
+
+	if res.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("returned response header '%v' is not '%v'", res.Header().Get("Content-Type"), "application/json")
+	}
+
+	var errRes errorRes
+	err := json.Unmarshal(res.Body.Bytes(), &errRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(errRes.Error.Message) == 0 {
+		t.Fatal("returned timeout response is supposed to contain an error")
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("request context was not cancelled after the timeout")
+	}
+}
+
+func TestWithTimeoutFastHandler(t *testing.T) {
+	handler := decorateHttpRes(pingHandlerImpl("test pong"), addJsonHeader(""), withTimeout(time.Second, ""))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	if res.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("returned response header '%v' is not '%v'", res.Header().Get("Content-Type"), "application/json")
+	}
+}
+
+func TestWithTimeoutKeepsTheJSONCharset(t *testing.T) {
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	handler := decorateHttpRes(slowHandler, addJsonHeader("utf-8"), withTimeout(50*time.Millisecond, "utf-8"))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", pingRoute, createPingReq()))
+
+	if res.Code != http.StatusServiceUnavailable {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusServiceUnavailable)
+	}
+
+	if res.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("returned response header '%v' is not '%v'", res.Header().Get("Content-Type"), "application/json; charset=utf-8")
+	}
+}
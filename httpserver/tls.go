@@ -0,0 +1,80 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certManager is only set when the certificates are provisioned with autocert, reloader when the
+// key pair files are reloaded on SIGHUP.
+func buildTLSConfig(cfg Config, certManager *autocert.Manager, reloader *certReloader) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if cfg.tlsConfig != nil {
+		tlsConfig = cfg.tlsConfig.Clone()
+	}
+
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+
+	if cfg.clientCAs != nil {
+		tlsConfig.ClientCAs = cfg.clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if certManager != nil {
+		if cfg.certificate != nil || len(cfg.certificatePemFilePath) != 0 || len(cfg.certificatePemPrivKeyFilePath) != 0 || len(cfg.additionalKeyPairs) != 0 {
+			return nil, fmt.Errorf("autocert and static certificates are mutually exclusive, configure only one of them")
+		}
+
+		tlsConfig.GetCertificate = certManager.GetCertificate
+		if !cfg.disableHTTP2 {
+			tlsConfig.NextProtos = append(tlsConfig.NextProtos, "h2")
+		}
+		tlsConfig.NextProtos = append(tlsConfig.NextProtos, "http/1.1", acme.ALPNProto)
+		return tlsConfig, nil
+	}
+
+	if reloader != nil {
+		tlsConfig.GetCertificate = reloader.getCertificate
+		return tlsConfig, nil
+	}
+
+	if cfg.certificate != nil {
+		tlsConfig.Certificates = append(tlsConfig.Certificates, *cfg.certificate)
+	} else {
+		cert, err := tls.LoadX509KeyPair(cfg.certificatePemFilePath, cfg.certificatePemPrivKeyFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load TLS key pair. %s", err.Error())
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	// crypto/tls picks the certificate matching the client SNI, the first one is the fallback.
+	for _, keyPair := range cfg.additionalKeyPairs {
+		cert, err := tls.LoadX509KeyPair(keyPair.certificatePemFilePath, keyPair.certificatePemPrivKeyFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load TLS key pair %s. %s", keyPair.certificatePemFilePath, err.Error())
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	return tlsConfig, nil
+}
+
+// serverProtocols enables HTTP/2 over TLS unless disabled and, on a plaintext server, h2c when asked for.
+func serverProtocols(cfg Config) *http.Protocols {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(!cfg.plaintext && !cfg.disableHTTP2)
+	protocols.SetUnencryptedHTTP2(cfg.plaintext && cfg.h2c)
+
+	return protocols
+}
@@ -0,0 +1,244 @@
+package httpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSMinVersionDefaultsToTLS12(t *testing.T) {
+	baseURL, cleanup := newTestServer(t, newTestConfig(0), NewReqHandlersDependencies("test pong"))
+	defer cleanup()
+	addr := strings.TrimPrefix(baseURL, "https://")
+
+	_, err := tls.Dial("tcp", addr, &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS11,
+		MaxVersion:         tls.VersionTLS11,
+	})
+	if err == nil {
+		t.Fatal("TLS 1.1 handshake is supposed to be rejected")
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+		MaxVersion:         tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatalf("TLS 1.2 handshake is supposed to succeed. %v", err)
+	}
+	conn.Close()
+}
+
+func TestTLSConfigRestrictsVersion(t *testing.T) {
+	cfg := newTestConfig(0).WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS13})
+	baseURL, cleanup := newTestServer(t, cfg, NewReqHandlersDependencies("test pong"))
+	defer cleanup()
+
+	_, err := tls.Dial("tcp", strings.TrimPrefix(baseURL, "https://"), &tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS12,
+	})
+	if err == nil {
+		t.Fatal("TLS 1.2 handshake is supposed to be rejected when TLS 1.3 is the minimum")
+	}
+}
+
+func TestBuildTLSConfigInvalidKeyPair(t *testing.T) {
+	_, err := buildTLSConfig(NewConfig(9094, "does-not-exist.crt", "does-not-exist.key"), nil, nil)
+	if err == nil {
+		t.Fatal("loading a missing key pair is supposed to fail")
+	}
+}
+
+func TestServeWithInMemoryCertificate(t *testing.T) {
+	fileCfg := newTestConfig(0)
+	certPem, err := ioutil.ReadFile(fileCfg.certificatePemFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPem, err := ioutil.ReadFile(fileCfg.certificatePemPrivKeyFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewConfigFromPEM(0, certPem, keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseURL, cleanup := newTestServer(t, cfg, NewReqHandlersDependencies("test pong"))
+	defer cleanup()
+
+	resp, err := newHttpClient().Post(baseURL+pingRoute, "application/json", createPingReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestNewConfigFromInvalidPEM(t *testing.T) {
+	_, err := NewConfigFromPEM(9094, []byte("not a certificate"), []byte("not a key"))
+	if err == nil {
+		t.Fatal("parsing an invalid PEM key pair is supposed to fail")
+	}
+}
+
+func TestAutocertExcludesStaticCertificates(t *testing.T) {
+	cfg := newTestConfig(9094).WithAutocert("", "citizen.gophersland.com")
+
+	_, err := buildTLSConfig(cfg, newAutocertManager(cfg), nil)
+	if err == nil {
+		t.Fatal("configuring both autocert and static certificates is supposed to fail")
+	}
+}
+
+func TestAutocertProvidesCertificates(t *testing.T) {
+	cfg := NewConfig(9094, "", "").WithAutocert("", "citizen.gophersland.com")
+
+	tlsConfig, err := buildTLSConfig(cfg, newAutocertManager(cfg), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tlsConfig.GetCertificate == nil || len(tlsConfig.Certificates) != 0 {
+		t.Fatal("autocert is supposed to provide the certificates instead of static ones")
+	}
+}
+
+func TestHTTP2OverTLS(t *testing.T) {
+	testCases := []struct {
+		cfg                Config
+		expectedProtoMajor int
+	}{
+		{newTestConfig(0), 2},
+		{newTestConfig(0).WithHTTP2(false), 1},
+	}
+
+	for _, testCase := range testCases {
+		baseURL, cleanup := newTestServer(t, testCase.cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		}}
+		res, err := client.Post(baseURL+pingRoute, "application/json", createPingReq())
+		if err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		client.CloseIdleConnections()
+		cleanup()
+
+		if res.ProtoMajor != testCase.expectedProtoMajor {
+			t.Fatalf("response protocol '%v' is not as expected HTTP/%v", res.Proto, testCase.expectedProtoMajor)
+		}
+	}
+}
+
+func TestH2CPlaintext(t *testing.T) {
+	cfg := NewConfig(0, "", "").WithPlaintext().WithH2C(true)
+	baseURL, cleanup := newTestServer(t, cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	defer cleanup()
+
+	protocols := &http.Protocols{}
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	defer client.CloseIdleConnections()
+
+	res, err := client.Post(baseURL+pingRoute, "application/json", createPingReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.StatusCode, http.StatusOK)
+	}
+	if res.ProtoMajor != 2 {
+		t.Fatalf("response protocol '%v' is not as expected HTTP/2", res.Proto)
+	}
+}
+
+func TestH2CRequiresPlaintext(t *testing.T) {
+	err := newTestConfig(9094).WithH2C(true).Validate()
+	if err == nil {
+		t.Fatal("h2c on a TLS server is supposed to be rejected")
+	}
+}
+
+func TestSNICertificateSelection(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(0)
+	for _, domain := range []string{"a.citizen.test", "b.citizen.test"} {
+		certPath, keyPath := writeSelfSignedKeyPair(t, dir, domain)
+		cfg = cfg.WithAdditionalKeyPair(certPath, keyPath)
+	}
+	baseURL, cleanup := newTestServer(t, cfg, NewReqHandlersDependencies("test pong").WithLogger(NoopLogger))
+	defer cleanup()
+
+	for _, serverName := range []string{"a.citizen.test", "b.citizen.test", "localhost"} {
+		conn, err := tls.Dial("tcp", strings.TrimPrefix(baseURL, "https://"), &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf := conn.ConnectionState().PeerCertificates[0]
+		conn.Close()
+
+		if err := leaf.VerifyHostname(serverName); err != nil {
+			t.Fatalf("certificate served for SNI '%v' doesn't match it. %v", serverName, err)
+		}
+	}
+}
+
+func writeSelfSignedKeyPair(t *testing.T, dir string, domain string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath, keyPath := filepath.Join(dir, domain+".crt"), filepath.Join(dir, domain+".key")
+	err = ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certPath, keyPath
+}
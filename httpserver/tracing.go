@@ -0,0 +1,73 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/gophersland/citizen/httpserver"
+	// serviceName identifies the server spans in the tracing backend.
+	serviceName = "citizen"
+)
+
+// traceRequests starts a server span per request, as a child of the W3C traceparent of the caller when
+// present. The handlers reach the span through the request context, e.g. to propagate it downstream.
+// The query is recorded with the values of the redacted parameters replaced.
+func traceRequests(tracer trace.Tracer, propagator propagation.TextMapPropagator, route string, redactedParams []string) httpResDecorator {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", route),
+					attribute.String("url.path", r.URL.Path),
+				),
+			)
+			defer span.End()
+			if len(r.URL.RawQuery) != 0 {
+				span.SetAttributes(attribute.String("url.query", redactQuery(r.URL, redactedParams)))
+			}
+
+			rec := newStatusRecorder(w)
+			handler.ServeHTTP(rec, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.response.status_code", rec.statusCode))
+			if rec.statusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(rec.statusCode))
+			}
+		})
+	}
+}
+
+// NewOTLPTracerProvider batches the spans to an OTLP/HTTP collector configured through the standard
+// OTEL_EXPORTER_OTLP_* environment variables, e.g. OTEL_EXPORTER_OTLP_ENDPOINT=http://localhost:4318.
+// Pass it to the server with ReqHandlersDependencies.WithTracerProvider and shut it down after the server
+// to flush the last spans.
+func NewOTLPTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the OTLP trace exporter. %s", err.Error())
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe the tracing resource. %s", err.Error())
+	}
+
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
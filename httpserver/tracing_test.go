@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingContinuesCallerTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var handlerSpan trace.SpanContext
+	group := RouteGroup{Prefix: "/traced", Routes: []Route{{Path: pingRoute, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+	})}}}
+	handler := newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong").WithTracerProvider(tracerProvider).WithRouteGroups(group))
+
+	req := httptest.NewRequest("POST", "/traced"+pingRoute, nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("recorded '%v' spans, expected a single one", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "POST /traced/ping" || span.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("recorded span '%v' of kind '%v' is not the server span of the request", span.Name(), span.SpanKind())
+	}
+	if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("recorded span '%v' does not continue the caller trace", span.SpanContext().TraceID())
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Fatal("handler context is supposed to carry the request span")
+	}
+}
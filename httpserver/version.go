@@ -0,0 +1,30 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package httpserver
+
+import "net/http"
+
+const (
+	versionRoute = "/version"
+)
+
+// The build info is set at build time, e.g.
+// go build -ldflags "-X github.com/gophersland/citizen/httpserver.Version=1.2.0 -X github.com/gophersland/citizen/httpserver.Commit=$(git rev-parse HEAD)"
+var (
+	Version   = "unknown"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+type versionRes struct {
+	Version   string `json:"version" xml:"version"`
+	Commit    string `json:"commit" xml:"commit"`
+	BuildTime string `json:"build_time" xml:"build_time"`
+}
+
+func versionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeNegotiated(w, r, versionRes{Version, Commit, BuildTime}, http.StatusOK)
+	})
+}
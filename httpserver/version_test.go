@@ -0,0 +1,31 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionRoute(t *testing.T) {
+	defer func(version, commit, buildTime string) {
+		Version, Commit, BuildTime = version, commit, buildTime
+	}(Version, Commit, BuildTime)
+	Version, Commit, BuildTime = "1.2.0", "f084892", "2030-01-01T00:00:00Z"
+
+	res := httptest.NewRecorder()
+	newHandler(newTestConfig(9093), NewReqHandlersDependencies("test pong")).ServeHTTP(res, httptest.NewRequest("GET", versionRoute, nil))
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("returned response code '%v' is not as expected one '%v'", res.Code, http.StatusOK)
+	}
+
+	versionRes := versionRes{}
+	err := json.Unmarshal(res.Body.Bytes(), &versionRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if versionRes.Version != "1.2.0" || versionRes.Commit != "f084892" || versionRes.BuildTime != "2030-01-01T00:00:00Z" {
+		t.Fatalf("returned build info '%+v' is not as expected", versionRes)
+	}
+}
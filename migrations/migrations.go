@@ -0,0 +1,181 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+
+// Package migrations upgrades the PostgreSQL schema with the versioned SQL files embedded in the binary,
+// sql/<version>_<name>.sql, each one applied once in its own transaction.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lockID is the PostgreSQL advisory lock serializing the nodes migrating the same database at once.
+const lockID = 0x636974697a656e
+
+//go:embed sql/*.sql
+var files embed.FS
+
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+// Latest is the schema version this release expects.
+func Latest() int {
+	all, _ := load(files)
+	if len(all) == 0 {
+		return 0
+	}
+
+	return all[len(all)-1].version
+}
+
+// Apply runs the migrations newer than the database version and records each applied version in the
+// schema_migrations table. It refuses a database already migrated by a newer release, and returns the
+// number of migrations it applied.
+func Apply(ctx context.Context, db *sql.DB) (int, error) {
+	return apply(ctx, db, files)
+}
+
+func apply(ctx context.Context, db *sql.DB, fsys fs.FS) (int, error) {
+	all, err := load(fsys)
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to connect to the database. %s", err.Error())
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID)
+	if err != nil {
+		return 0, fmt.Errorf("unable to lock the schema migrations. %s", err.Error())
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())`)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create the schema_migrations table. %s", err.Error())
+	}
+
+	current, err := version(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if len(all) != 0 && current > all[len(all)-1].version {
+		return 0, fmt.Errorf("database schema version %d is newer than the %d known by this release", current, all[len(all)-1].version)
+	}
+
+	applied := 0
+	for _, m := range all {
+		if m.version <= current {
+			continue
+		}
+
+		err = applyOne(ctx, conn, m)
+		if err != nil {
+			return applied, err
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+func applyOne(ctx context.Context, conn *sql.Conn, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to start migration %d. %s", m.version, err.Error())
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, m.query)
+	if err != nil {
+		return fmt.Errorf("unable to apply migration %d %s. %s", m.version, m.name, err.Error())
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.version)
+	if err != nil {
+		return fmt.Errorf("unable to record migration %d. %s", m.version, err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit migration %d. %s", m.version, err.Error())
+	}
+
+	return nil
+}
+
+// Version is the last migration applied to the database, 0 for a database never migrated.
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to connect to the database. %s", err.Error())
+	}
+	defer conn.Close()
+
+	var exists bool
+	err = conn.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read the schema version. %s", err.Error())
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	return version(ctx, conn)
+}
+
+func version(ctx context.Context, conn *sql.Conn) (int, error) {
+	var current int
+	err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read the schema version. %s", err.Error())
+	}
+
+	return current, nil
+}
+
+// load reads the migrations ordered by version, the file names must start with a unique version number.
+func load(fsys fs.FS) ([]migration, error) {
+	names, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var all []migration
+	seen := map[int]string{}
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+		prefix, _, _ := strings.Cut(base, "_")
+		v, err := strconv.Atoi(prefix)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("migration %s doesn't start with a positive version number", name)
+		}
+		if previous, ok := seen[v]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share the version %d", previous, name, v)
+		}
+		seen[v] = name
+
+		query, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, migration{version: v, name: base, query: string(query)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].version < all[j].version })
+
+	return all, nil
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadOrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0010_add_index.sql":       {Data: []byte("CREATE INDEX ...")},
+		"sql/0002_create_citizens.sql": {Data: []byte("CREATE TABLE ...")},
+	}
+
+	all, err := load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].version != 2 || all[1].version != 10 || all[1].name != "0010_add_index" {
+		t.Fatalf("loaded migrations '%+v' are not ordered by version", all)
+	}
+}
+
+func TestLoadRejectsInvalidNames(t *testing.T) {
+	testCases := []fstest.MapFS{
+		{"sql/create_citizens.sql": {Data: []byte("")}},
+		{"sql/0001_a.sql": {Data: []byte("")}, "sql/1_b.sql": {Data: []byte("")}},
+	}
+
+	for _, fsys := range testCases {
+		_, err := load(fsys)
+		if err == nil {
+			t.Fatalf("loading '%v' is supposed to fail", fsys)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	all, err := load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 || Latest() != all[len(all)-1].version {
+		t.Fatalf("embedded migrations '%+v' don't match the latest version '%v'", all, Latest())
+	}
+}
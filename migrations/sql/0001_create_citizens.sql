@@ -0,0 +1,5 @@
+-- The citizens are stored JSONB encoded, keyed by their ID, see storage.Postgres.
+CREATE TABLE citizens (
+    id     TEXT PRIMARY KEY,
+    record JSONB NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Orders the citizens by name, as storage.Postgres.Query does, out of an index instead of a table sort.
+CREATE INDEX citizens_name ON citizens (((record->>'name') COLLATE "C"), id COLLATE "C");
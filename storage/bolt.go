@@ -0,0 +1,139 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltOpenTimeout bounds the wait for the database file lock, held by another process using the same data directory.
+const boltOpenTimeout = time.Second
+
+// Bolt keeps the records JSON encoded in a bucket of a bbolt database file, they survive restarts without
+// an external database. Only one process at a time can open the file.
+type Bolt[T any] struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+// NewBolt opens, or creates, the database file at path and its bucket.
+func NewBolt[T any](path string, bucket string) (*Bolt[T], error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open bolt database %s. %s", path, err.Error())
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create bolt bucket %s. %s", bucket, err.Error())
+	}
+
+	return &Bolt[T]{db: db, bucket: []byte(bucket)}, nil
+}
+
+func (b *Bolt[T]) Put(ctx context.Context, id string, record T) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal record %s. %s", id, err.Error())
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(b.bucket).Put([]byte(id), value)
+	})
+}
+
+func (b *Bolt[T]) Get(ctx context.Context, id string) (T, error) {
+	var record T
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(b.bucket).Get([]byte(id))
+		if value == nil {
+			return ErrNotFound
+		}
+
+		return json.Unmarshal(value, &record)
+	})
+
+	return record, err
+}
+
+// List relies on bbolt iterating the keys in byte order.
+func (b *Bolt[T]) List(ctx context.Context) ([]T, error) {
+	records := []T{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(b.bucket).ForEach(func(id, value []byte) error {
+			var record T
+			err := json.Unmarshal(value, &record)
+			if err != nil {
+				return fmt.Errorf("unable to unmarshal record %s. %s", id, err.Error())
+			}
+
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (b *Bolt[T]) Delete(ctx context.Context, id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+
+		return bucket.Delete([]byte(id))
+	})
+}
+
+func (b *Bolt[T]) Close() error {
+	return b.db.Close()
+}
+
+// importFile copies the records of the file backend at path into the bucket when it is still empty, in a
+// single transaction so an interrupted import is retried as a whole on the next start.
+func (b *Bolt[T]) importFile(path string) (int, error) {
+	file, err := NewFile[T](path)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucket)
+		if first, _ := bucket.Cursor().First(); first != nil {
+			return nil
+		}
+
+		for id, record := range file.records {
+			value, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("unable to marshal record %s. %s", id, err.Error())
+			}
+			err = bucket.Put([]byte(id), value)
+			if err != nil {
+				return err
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to import storage file %s. %s", path, err.Error())
+	}
+
+	return imported, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File keeps the records in memory and rewrites the whole JSON file, an object of the records by ID, on
+// every change. Meant for a single node with a modest amount of records.
+type File[T any] struct {
+	path string
+
+	mu      sync.RWMutex
+	records map[string]T
+}
+
+// NewFile loads the records of the file at path, a missing file starts empty and is created on the first Put.
+func NewFile[T any](path string) (*File[T], error) {
+	f := &File[T]{path: path, records: map[string]T{}}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read storage file. %s", err.Error())
+	}
+
+	err = json.Unmarshal(content, &f.records)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse storage file %s. %s", path, err.Error())
+	}
+
+	return f, nil
+}
+
+func (f *File[T]) Put(ctx context.Context, id string, record T) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, existed := f.records[id]
+	f.records[id] = record
+	err := f.save()
+	if err != nil {
+		// The memory stays in line with the file.
+		if existed {
+			f.records[id] = previous
+		} else {
+			delete(f.records, id)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (f *File[T]) Get(ctx context.Context, id string) (T, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	record, ok := f.records[id]
+	if !ok {
+		return record, ErrNotFound
+	}
+
+	return record, nil
+}
+
+func (f *File[T]) List(ctx context.Context) ([]T, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return sortedRecords(f.records), nil
+}
+
+func (f *File[T]) Delete(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record, ok := f.records[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(f.records, id)
+	err := f.save()
+	if err != nil {
+		f.records[id] = record
+		return err
+	}
+
+	return nil
+}
+
+func (f *File[T]) Close() error {
+	return nil
+}
+
+// save writes a temporary file renamed over the previous one, a crash never leaves a truncated file.
+func (f *File[T]) save() error {
+	content, err := json.MarshalIndent(f.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal storage records. %s", err.Error())
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create storage file. %s", err.Error())
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err != nil || closeErr != nil {
+		return fmt.Errorf("unable to write storage file. %w", errors.Join(err, closeErr))
+	}
+
+	err = os.Rename(tmp.Name(), f.path)
+	if err != nil {
+		return fmt.Errorf("unable to replace storage file. %s", err.Error())
+	}
+
+	return nil
+}
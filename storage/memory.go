@@ -0,0 +1,78 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Memory keeps the records in a map, they are lost on restart. Meant for the tests and the demos.
+type Memory[T any] struct {
+	mu      sync.RWMutex
+	records map[string]T
+}
+
+func NewMemory[T any]() *Memory[T] {
+	return &Memory[T]{records: map[string]T{}}
+}
+
+func (m *Memory[T]) Put(ctx context.Context, id string, record T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records[id] = record
+	return nil
+}
+
+func (m *Memory[T]) Get(ctx context.Context, id string) (T, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record, ok := m.records[id]
+	if !ok {
+		return record, ErrNotFound
+	}
+
+	return record, nil
+}
+
+func (m *Memory[T]) List(ctx context.Context) ([]T, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return sortedRecords(m.records), nil
+}
+
+func (m *Memory[T]) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.records[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.records, id)
+
+	return nil
+}
+
+func (m *Memory[T]) Close() error {
+	return nil
+}
+
+func sortedRecords[T any](records map[string]T) []T {
+	ids := make([]string, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	sorted := make([]T, 0, len(ids))
+	for _, id := range ids {
+		sorted = append(sorted, records[id])
+	}
+
+	return sorted
+}
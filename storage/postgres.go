@@ -0,0 +1,305 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gophersland/citizen/migrations"
+	// Registers the pgx database/sql driver.
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	defaultPostgresMaxConns = 10
+	postgresConnectTimeout  = 5 * time.Second
+	postgresConnMaxIdleTime = 5 * time.Minute
+)
+
+// postgresIdentifier matches the table and field names, they are formatted into the statements.
+var postgresIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Postgres keeps the records JSONB encoded in a table named after the repository, for the deployments
+// running several nodes against a shared database. The statements are prepared once at open, but the
+// Query ones built out of the query.
+type Postgres[T any] struct {
+	db    *sql.DB
+	table string
+
+	put    *sql.Stmt
+	get    *sql.Stmt
+	list   *sql.Stmt
+	delete *sql.Stmt
+}
+
+// NewPostgres connects to the database of the pgx dsn, e.g. postgres://citizen@localhost:5432/citizen,
+// through a pool of up to maxConns connections, 10 when 0. The table, with an id TEXT primary key and a
+// record JSONB column, is created by the migrations package.
+func NewPostgres[T any](dsn string, table string, maxConns int) (*Postgres[T], error) {
+	if !postgresIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid PostgreSQL table name '%s'", table)
+	}
+
+	db, err := openPostgresDB(dsn, maxConns)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), postgresConnectTimeout)
+	defer cancel()
+	pg, err := preparePostgres[T](ctx, db, table)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return pg, nil
+}
+
+func openPostgresDB(dsn string, maxConns int) (*sql.DB, error) {
+	if maxConns <= 0 {
+		maxConns = defaultPostgresMaxConns
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open PostgreSQL database. %s", err.Error())
+	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+	db.SetConnMaxIdleTime(postgresConnMaxIdleTime)
+
+	return db, nil
+}
+
+func preparePostgres[T any](ctx context.Context, db *sql.DB, table string) (*Postgres[T], error) {
+	var err error
+	pg := &Postgres[T]{db: db, table: table}
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&pg.put, `INSERT INTO %s (id, record) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET record = EXCLUDED.record`},
+		{&pg.get, `SELECT record FROM %s WHERE id = $1`},
+		// The C collation orders the IDs byte-wise, like the other backends.
+		{&pg.list, `SELECT record FROM %s ORDER BY id COLLATE "C"`},
+		{&pg.delete, `DELETE FROM %s WHERE id = $1`},
+	}
+	for _, statement := range statements {
+		*statement.stmt, err = db.PrepareContext(ctx, fmt.Sprintf(statement.query, table))
+		if err != nil {
+			return nil, fmt.Errorf("unable to prepare PostgreSQL statement, is the schema migrated? %s", err.Error())
+		}
+	}
+
+	return pg, nil
+}
+
+func (pg *Postgres[T]) Put(ctx context.Context, id string, record T) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal record %s. %s", id, err.Error())
+	}
+
+	_, err = pg.put.ExecContext(ctx, id, value)
+	if err != nil {
+		return fmt.Errorf("unable to store record %s. %s", id, err.Error())
+	}
+
+	return nil
+}
+
+func (pg *Postgres[T]) Get(ctx context.Context, id string) (T, error) {
+	var record T
+	var value []byte
+	err := pg.get.QueryRowContext(ctx, id).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return record, ErrNotFound
+	}
+	if err != nil {
+		return record, fmt.Errorf("unable to load record %s. %s", id, err.Error())
+	}
+
+	err = json.Unmarshal(value, &record)
+	if err != nil {
+		return record, fmt.Errorf("unable to unmarshal record %s. %s", id, err.Error())
+	}
+
+	return record, nil
+}
+
+func (pg *Postgres[T]) List(ctx context.Context) ([]T, error) {
+	rows, err := pg.list.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list records. %s", err.Error())
+	}
+
+	return scanRecords[T](rows)
+}
+
+// Query filters, orders and pages the records in the database, the total is counted by a second statement.
+func (pg *Postgres[T]) Query(ctx context.Context, query Query) ([]T, int, error) {
+	where, args, err := postgresWhere(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	orderBy, err := postgresOrderBy(query.OrderBy)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	err = pg.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT count(*) FROM %s%s`, pg.table, where), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to count records. %s", err.Error())
+	}
+
+	statement := fmt.Sprintf(`SELECT record FROM %s%s ORDER BY %s LIMIT $%d OFFSET $%d`, pg.table, where, orderBy, len(args)+1, len(args)+2)
+	rows, err := pg.db.QueryContext(ctx, statement, append(args, query.Limit, query.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to query records. %s", err.Error())
+	}
+	records, err := scanRecords[T](rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return records, total, nil
+}
+
+func postgresWhere(query Query) (string, []any, error) {
+	var conditions []string
+	var args []any
+	if len(query.PrefixField) != 0 {
+		if !postgresIdentifier.MatchString(query.PrefixField) {
+			return "", nil, fmt.Errorf("invalid query field '%s'", query.PrefixField)
+		}
+		args = append(args, query.Prefix)
+		conditions = append(conditions, fmt.Sprintf(`starts_with(record->>'%s', $%d)`, query.PrefixField, len(args)))
+	}
+	if len(query.AfterField) != 0 {
+		if !postgresIdentifier.MatchString(query.AfterField) {
+			return "", nil, fmt.Errorf("invalid query field '%s'", query.AfterField)
+		}
+		args = append(args, query.After)
+		conditions = append(conditions, fmt.Sprintf(`(record->>'%s')::timestamptz > $%d`, query.AfterField, len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// postgresOrderBy compares the strings with the C collation, byte-wise like the other backends.
+func postgresOrderBy(order Order) (string, error) {
+	if len(order.Field) == 0 {
+		return `id COLLATE "C"`, nil
+	}
+	if !postgresIdentifier.MatchString(order.Field) {
+		return "", fmt.Errorf("invalid query field '%s'", order.Field)
+	}
+
+	column := fmt.Sprintf(`(record->>'%s') COLLATE "C"`, order.Field)
+	switch {
+	case order.Field == "id":
+		column = `id COLLATE "C"`
+	case order.Time:
+		column = fmt.Sprintf(`(record->>'%s')::timestamptz`, order.Field)
+	}
+	if order.Descending {
+		column += " DESC"
+	}
+
+	return column + `, id COLLATE "C"`, nil
+}
+
+func scanRecords[T any](rows *sql.Rows) ([]T, error) {
+	defer rows.Close()
+
+	records := []T{}
+	for rows.Next() {
+		var value []byte
+		err := rows.Scan(&value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list records. %s", err.Error())
+		}
+
+		var record T
+		err = json.Unmarshal(value, &record)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal record. %s", err.Error())
+		}
+		records = append(records, record)
+	}
+	err := rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list records. %s", err.Error())
+	}
+
+	return records, nil
+}
+
+func (pg *Postgres[T]) Delete(ctx context.Context, id string) error {
+	res, err := pg.delete.ExecContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("unable to delete record %s. %s", id, err.Error())
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to delete record %s. %s", id, err.Error())
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Ping tells whether the database answers, e.g. for a readiness check.
+func (pg *Postgres[T]) Ping(ctx context.Context) error {
+	return pg.db.PingContext(ctx)
+}
+
+// Close closes the connection pool, and the prepared statements with it.
+func (pg *Postgres[T]) Close() error {
+	return pg.db.Close()
+}
+
+// Migrate applies the migrations package to the database of the postgres backend, the other backends have
+// no schema. It returns the number of applied migrations and the resulting schema version.
+func Migrate(ctx context.Context, cfg Config) (applied int, version int, err error) {
+	err = cfg.Validate()
+	if err != nil {
+		return 0, 0, err
+	}
+	if cfg.Backend != BackendPostgres {
+		return 0, 0, nil
+	}
+
+	db, err := openPostgresDB(cfg.DSN, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+
+	applied, err = migrations.Apply(ctx, db)
+	if err != nil {
+		return applied, 0, err
+	}
+	version, err = migrations.Version(ctx, db)
+	if err != nil {
+		return applied, 0, err
+	}
+
+	return applied, version, nil
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// envTestPostgresDSN points the PostgreSQL tests to a disposable database, they are skipped without it.
+const envTestPostgresDSN = "CITIZEN_TEST_POSTGRES_DSN"
+
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv(envTestPostgresDSN)
+	if len(dsn) == 0 {
+		t.Skipf("%s is not set", envTestPostgresDSN)
+	}
+
+	db, err := openPostgresDB(dsn, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE storage_test_records (id TEXT PRIMARY KEY, record JSONB NOT NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec(`DROP TABLE storage_test_records`)
+
+	pg, err := NewPostgres[testRecord](dsn, "storage_test_records", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pg.Close()
+
+	ctx := context.Background()
+	err = pg.Ping(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"b", "a", "B"} {
+		err = pg.Put(ctx, id, testRecord{Name: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := pg.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].Name != "B" || records[1].Name != "a" || records[2].Name != "b" {
+		t.Fatalf("listed records '%v' are not ordered byte-wise by ID", records)
+	}
+	if pg.Delete(ctx, "unknown") != ErrNotFound {
+		t.Fatal("deleting an unknown record is supposed to fail")
+	}
+}
+
+func TestPostgresQuery(t *testing.T) {
+	dsn := os.Getenv(envTestPostgresDSN)
+	if len(dsn) == 0 {
+		t.Skipf("%s is not set", envTestPostgresDSN)
+	}
+
+	db, err := openPostgresDB(dsn, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE storage_test_queried (id TEXT PRIMARY KEY, record JSONB NOT NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec(`DROP TABLE storage_test_queried`)
+
+	type queriedRecord struct {
+		ID       string    `json:"id"`
+		Name     string    `json:"name"`
+		JoinedAt time.Time `json:"joined_at"`
+	}
+	pg, err := NewPostgres[queriedRecord](dsn, "storage_test_queried", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pg.Close()
+
+	ctx := context.Background()
+	joinedAt := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range []string{"gopher", "alice", "gordon", "bob"} {
+		id := string(rune('a' + i))
+		err = pg.Put(ctx, id, queriedRecord{ID: id, Name: name, JoinedAt: joinedAt.AddDate(0, 0, i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		ids   []string
+		total int
+	}{
+		{"defaults to ID order", Query{Limit: 10}, []string{"a", "b", "c", "d"}, 4},
+		{"filters by prefix", Query{PrefixField: "name", Prefix: "go", Limit: 10}, []string{"a", "c"}, 2},
+		{"filters by time", Query{AfterField: "joined_at", After: joinedAt.AddDate(0, 0, 1), Limit: 10}, []string{"c", "d"}, 2},
+		{"orders by field", Query{OrderBy: Order{Field: "name"}, Limit: 10}, []string{"b", "d", "a", "c"}, 4},
+		{"orders descending by time", Query{OrderBy: Order{Field: "joined_at", Time: true, Descending: true}, Limit: 10}, []string{"d", "c", "b", "a"}, 4},
+		{"pages", Query{OrderBy: Order{Field: "name"}, Limit: 2, Offset: 1}, []string{"d", "a"}, 4},
+		{"pages past the end", Query{Limit: 10, Offset: 10}, []string{}, 4},
+	}
+	for _, test := range tests {
+		records, total, err := pg.Query(ctx, test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []string{}
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(test.ids) || total != test.total {
+			t.Fatalf("%v: queried records '%v' of total '%v' are not as expected ones '%v' of total '%v'", test.name, ids, total, test.ids, test.total)
+		}
+	}
+}
+
+func TestPostgresQueryRejectsInvalidFields(t *testing.T) {
+	for _, query := range []Query{
+		{PrefixField: "name'; DROP TABLE citizens; --"},
+		{AfterField: "joined at"},
+		{OrderBy: Order{Field: "Name"}},
+	} {
+		_, _, err := postgresWhere(query)
+		if err == nil {
+			_, err = postgresOrderBy(query.OrderBy)
+		}
+		if err == nil {
+			t.Fatalf("query '%+v' with a field that is not a plain identifier is supposed to be rejected", query)
+		}
+	}
+}
+
+func TestNewPostgresRejectsInvalidTables(t *testing.T) {
+	_, err := NewPostgres[testRecord]("postgres://localhost/citizen", "citizens; DROP TABLE citizens", 0)
+	if err == nil {
+		t.Fatal("a table name that is not a plain identifier is supposed to be rejected")
+	}
+}
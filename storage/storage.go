@@ -0,0 +1,163 @@
+// Copyright 2018 https://gophersland.com
+// All rights reserved.
+// Use of this source code is governed by an Apache License that can be found in the LICENSE file.
+
+// Package storage keeps the records of the server, e.g. the citizens, in memory or on disk.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	BackendMemory   = "memory"
+	BackendFile     = "file"
+	BackendBolt     = "bolt"
+	BackendPostgres = "postgres"
+)
+
+// ErrNotFound matches, through errors.Is, the lookups and deletions of an unknown ID.
+var ErrNotFound = errors.New("record not found")
+
+// Repository stores the records of type T by ID, its implementations are safe for concurrent use.
+type Repository[T any] interface {
+	// Put creates or replaces the record.
+	Put(ctx context.Context, id string, record T) error
+	Get(ctx context.Context, id string) (T, error)
+	// List returns every record ordered by ID.
+	List(ctx context.Context) ([]T, error)
+	Delete(ctx context.Context, id string) error
+	// Close releases the storage, e.g. the database file lock.
+	Close() error
+}
+
+// Config selects the Repository implementation, the in-memory one when Backend is empty.
+type Config struct {
+	Backend string
+	// Path is the file of the file backend. With the bolt backend, its records are imported into a
+	// still empty database, to move a deployment from one backend to the other.
+	Path string
+	// DataDir holds the database files of the bolt backend, one per repository.
+	DataDir string
+	// DSN locates the database of the postgres backend, e.g. postgres://citizen@localhost:5432/citizen.
+	DSN string
+	// MaxConns caps the connection pool of the postgres backend, 10 when 0.
+	MaxConns int
+	// AutoMigrate applies the schema migrations of the postgres backend when opening it, otherwise they
+	// are applied beforehand with Migrate.
+	AutoMigrate bool
+}
+
+// Pinger is implemented by the repositories depending on a server, e.g. PostgreSQL, to tell whether it answers.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Querier is implemented by the repositories selecting a page of records on their side, e.g. PostgreSQL,
+// instead of listing them all.
+type Querier[T any] interface {
+	// Query returns the page of the records matching the query and the count of every matching record.
+	Query(ctx context.Context, query Query) (records []T, total int, err error)
+}
+
+// Query selects a page of the records by their JSON encoded fields, the strings are compared byte-wise.
+type Query struct {
+	// PrefixField, when set, keeps the records whose string field starts with Prefix.
+	PrefixField string
+	Prefix      string
+	// AfterField, when set, keeps the records whose time field is after After.
+	AfterField string
+	After      time.Time
+	// OrderBy, when set, orders the records by the field before their ID.
+	OrderBy Order
+	Limit   int
+	Offset  int
+}
+
+// Order sorts the records by a JSON field, the id one being the record ID. The ties are ordered by ID.
+type Order struct {
+	Field string
+	// Time compares the field as a time instead of a string.
+	Time       bool
+	Descending bool
+}
+
+func (cfg Config) Validate() error {
+	switch cfg.Backend {
+	case "", BackendMemory:
+		return nil
+	case BackendFile:
+		if len(cfg.Path) == 0 {
+			return fmt.Errorf("storage backend %s requires a path", BackendFile)
+		}
+		return nil
+	case BackendBolt:
+		if len(cfg.DataDir) == 0 {
+			return fmt.Errorf("storage backend %s requires a data directory", BackendBolt)
+		}
+		return nil
+	case BackendPostgres:
+		if len(cfg.DSN) == 0 {
+			return fmt.Errorf("storage backend %s requires a DSN", BackendPostgres)
+		}
+		if cfg.MaxConns < 0 {
+			return fmt.Errorf("storage max conns must not be negative")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown storage backend '%s', use %s, %s, %s or %s", cfg.Backend, BackendMemory, BackendFile, BackendBolt, BackendPostgres)
+	}
+}
+
+// Open returns the Repository of the given name, e.g. citizens, selected by cfg. The file one loads its
+// records right away, the bolt one opens its database file, <DataDir>/<name>.db, and the postgres one
+// connects to the <name> table.
+func Open[T any](cfg Config, name string) (Repository[T], error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Backend {
+	case BackendFile:
+		return NewFile[T](cfg.Path)
+	case BackendBolt:
+		return openBolt[T](cfg, name)
+	case BackendPostgres:
+		if cfg.AutoMigrate {
+			_, _, err = Migrate(context.Background(), cfg)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return NewPostgres[T](cfg.DSN, name, cfg.MaxConns)
+	default:
+		return NewMemory[T](), nil
+	}
+}
+
+func openBolt[T any](cfg Config, name string) (Repository[T], error) {
+	err := os.MkdirAll(cfg.DataDir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create data directory. %s", err.Error())
+	}
+
+	repo, err := NewBolt[T](filepath.Join(cfg.DataDir, name+".db"), name)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Path) != 0 {
+		_, err = repo.importFile(cfg.Path)
+		if err != nil {
+			repo.Close()
+			return nil, err
+		}
+	}
+
+	return repo, nil
+}
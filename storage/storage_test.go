@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testRecord struct {
+	Name string `json:"name"`
+}
+
+func TestRepositories(t *testing.T) {
+	file, err := NewFile[testRecord](filepath.Join(t.TempDir(), "records.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bolt, err := NewBolt[testRecord](filepath.Join(t.TempDir(), "records.db"), "records")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+	testCases := []struct {
+		name string
+		repo Repository[testRecord]
+	}{
+		{"memory", NewMemory[testRecord]()},
+		{"file", file},
+		{"bolt", bolt},
+	}
+
+	ctx := context.Background()
+	for _, testCase := range testCases {
+		repo := testCase.repo
+		for _, id := range []string{"b", "a"} {
+			err := repo.Put(ctx, id, testRecord{Name: id})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := repo.Put(ctx, "b", testRecord{Name: "b2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		record, err := repo.Get(ctx, "b")
+		if err != nil || record.Name != "b2" {
+			t.Fatalf("%v returned record '%v' with error '%v', expected the replaced one", testCase.name, record, err)
+		}
+
+		records, err := repo.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 || records[0].Name != "a" || records[1].Name != "b2" {
+			t.Fatalf("%v listed records '%v' which are not ordered by ID", testCase.name, records)
+		}
+
+		err = repo.Delete(ctx, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = repo.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%v lookup of a deleted record returned '%v', expected '%v'", testCase.name, err, ErrNotFound)
+		}
+		if !errors.Is(repo.Delete(ctx, "a"), ErrNotFound) {
+			t.Fatalf("%v deletion of an unknown record is supposed to fail", testCase.name)
+		}
+	}
+}
+
+func TestFileReloadsRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	file, err := NewFile[testRecord](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Put(context.Background(), "a", testRecord{Name: "gopher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFile[testRecord](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := reopened.Get(context.Background(), "a")
+	if err != nil || record.Name != "gopher" {
+		t.Fatalf("reloaded record '%v' with error '%v' is not the stored one", record, err)
+	}
+
+	err = os.WriteFile(path, []byte("not json"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewFile[testRecord](path); err == nil {
+		t.Fatal("opening a corrupted storage file is supposed to fail")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	testCases := []struct {
+		cfg         Config
+		expectedErr bool
+	}{
+		{Config{}, false},
+		{Config{Backend: BackendFile, Path: filepath.Join(t.TempDir(), "records.json")}, false},
+		{Config{Backend: BackendFile}, true},
+		{Config{Backend: BackendBolt, DataDir: t.TempDir()}, false},
+		{Config{Backend: BackendBolt}, true},
+		{Config{Backend: "etcd"}, true},
+	}
+
+	for _, testCase := range testCases {
+		repo, err := Open[testRecord](testCase.cfg, "records")
+		if (err != nil) != testCase.expectedErr {
+			t.Fatalf("opening '%+v' returned error '%v'", testCase.cfg, err)
+		}
+		if repo != nil {
+			repo.Close()
+		}
+	}
+}
+
+func TestBoltImportsFileRecords(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "records.json")
+	file, err := NewFile[testRecord](filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b"} {
+		err = file.Put(ctx, id, testRecord{Name: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := Config{Backend: BackendBolt, Path: filePath, DataDir: t.TempDir()}
+	repo, err := Open[testRecord](cfg, "records")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := repo.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Name != "a" || records[1].Name != "b" {
+		t.Fatalf("imported records '%v' are not the file ones", records)
+	}
+
+	// Once the database holds records, the file is not imported again.
+	err = repo.Delete(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+	repo, err = Open[testRecord](cfg, "records")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if _, err = repo.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("lookup of a deleted record returned '%v', the file is not supposed to be imported twice", err)
+	}
+}